@@ -3,9 +3,15 @@
 package amortization
 
 import (
-	"math"
-
+	"encoding/json"
+	"fmt"
 	"log"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
 )
 
 // MortgagePool defines the behavior for generating amortization tables.
@@ -29,15 +35,60 @@ type LoanInfo struct {
 	Wam  int64   `json:"wam"`  // Weighted Average Maturity in months
 	Wac  float64 `json:"wac"`  // Weighted Average Coupon rate per annum in percentage points (e.g., 6.75)
 	Face float64 `json:"face"` // Mortgage notional/principal amount
+	// For seasoned loans the current face can instead be given as an original
+	// balance and a current pool factor (0 < Factor <= 1); Face = OrigFace * Factor
+	OrigFace float64 `json:"orig_face,omitempty"`
+	Factor   float64 `json:"factor,omitempty"`
+	// WacIsDecimal marks Wac as a decimal rate (e.g., 0.0675) instead of percentage points
+	WacIsDecimal bool `json:"wac_is_decimal,omitempty"`
+	// DayCount names a registered day-count convention (e.g. "ACT/ACT ISDA").
+	// With an OriginationDate, each period accrues the coupon over its actual
+	// dates; otherwise every period accrues one twelfth of the annual coupon.
+	DayCount        string    `json:"day_count,omitempty"`
+	OriginationDate time.Time `json:"origination_date,omitzero"`
+	// FirstPaymentDate, when set, ends a stub first period that accrues over
+	// the actual days from OriginationDate; later periods are regular months
+	FirstPaymentDate time.Time `json:"first_payment_date,omitzero"`
+	// Compounding selects how the coupon becomes a periodic rate; empty means simple
+	Compounding CompoundingConvention `json:"compounding,omitempty"`
+	// PaymentFrequency is how often the loan pays, "monthly" or "biweekly";
+	// empty means monthly. Wam stays in months either way.
+	PaymentFrequency string `json:"payment_frequency,omitempty"`
+	// IntegerCents runs the balance, interest and principal math in int64
+	// cents instead of float64 dollars, so every period reconciles exactly
+	IntegerCents bool `json:"integer_cents,omitempty"`
+	// MonthlyEscrow is the taxes and insurance collected with each payment
+	// while the loan is outstanding. It is passed through to the escrow
+	// account and never reduces the balance.
+	MonthlyEscrow float64 `json:"monthly_escrow,omitempty"`
+	// PayoffThreshold retires the loan in the first period whose ending
+	// balance falls below it, folding the residual into that period's
+	// principal so no trailing periods carry a negligible balance. Zero means
+	// the default of one cent.
+	PayoffThreshold float64 `json:"payoff_threshold,omitempty"`
+	// Tags group loans by cohort, vintage, servicer, etc. (e.g., {"servicer": "XYZ"})
+	Tags map[string]string `json:"tags,omitempty"`
+	PrepayInfo
+	ARMInfo
+	DelinquencyInfo
+	DefaultInfo
 }
 
 type PrepayInfo struct {
-	PrepayCPR float64   `json:"prepay_cpr"`        // prepay CPR in decimals, could be SMM
-	SMMArr    []float64 `json:"smm_arr,omitempty"` // SMM array for prepayment calculations
+	PrepayCPR float64 `json:"prepay_cpr"`        // prepay CPR in decimals, could be SMM
+	SMMArr    Ratios  `json:"smm_arr,omitempty"` // SMM array for prepayment calculations
+	// Penalty charged on prepaid principal, in decimals (e.g., 0.02 for 2%),
+	// during the first PrepayPenaltyMonths periods
+	PrepayPenaltyPct    float64 `json:"prepay_penalty_pct,omitempty"`
+	PrepayPenaltyMonths int64   `json:"prepay_penalty_months,omitempty"`
+	// PrepayModel overrides PrepayCPR with a per-period prepayment model
+	PrepayModel PrepayModel `json:"-"`
+	// SMMCap clamps each period's SMM; zero means the default of 1.0
+	SMMCap float64 `json:"smm_cap,omitempty"`
 }
 
 type DelinquencyInfo struct {
-	StaticDQ bool `json:"static_dq"` // If true amortization uses a roll rate matrix
+	StaticDQ bool `json:"static_dq"` // If true the table carries delinquency curves rolled through the RollRateMatrix
 	// AmortTable AmortizationTable `json:"amort_table,omitempty"` // Associated amortization table
 	// Define the structure for the roll rate matrix
 	// [0.92, 0.01, 0.01, 0.01, 0.01, 0.01, 0.01, 0.01]
@@ -77,14 +128,87 @@ type DelinqArrays struct {
 // AmortizationTable represents a complete loan amortization schedule.
 // It contains all payment components and balances for each period of the loan.
 type AmortizationTable struct {
-	BegBal          []float64    `json:"beg_bal"`           // Beginning balance for each period
-	Interest        []float64    `json:"interest"`          // Interest payment for each period
-	Principal       []float64    `json:"principal"`         // Principal payment for each period
-	SchedBal        []float64    `json:"sched_bal"`         // Scheduled balance after payment
-	PrepayAmountArr []float64    `json:"prepay_amount_arr"` // Prepayment amount for each period
-	EndBal          []float64    `json:"end_bal"`           // Ending balance for each period
-	Period          []int        `json:"period"`            // Period numbers (1, 2, 3, ...)
-	DelinqArrays    DelinqArrays `json:"delinq_arrays"`     // Delinquency performance arrays
+	BegBal          Amounts      `json:"beg_bal"`                 // Beginning balance for each period
+	Interest        Amounts      `json:"interest"`                // Interest payment for each period
+	Principal       Amounts      `json:"principal"`               // Principal payment for each period
+	Payment         Amounts      `json:"payment"`                 // Scheduled payment (interest plus principal, excluding prepayment)
+	SchedBal        Amounts      `json:"sched_bal"`               // Scheduled balance after payment
+	PrepayAmountArr Amounts      `json:"prepay_amount_arr"`       // Prepayment amount for each period
+	EndBal          Amounts      `json:"end_bal"`                 // Ending balance for each period
+	PenaltyArr      Amounts      `json:"penalty_arr,omitempty"`   // Prepayment penalty cashflow for each period
+	FactorArr       Ratios       `json:"factor_arr,omitempty"`    // Ending balance relative to the original face
+	EscrowArr       Amounts      `json:"escrow_arr,omitempty"`    // Escrow collected with each period's payment
+	TotalPayment    Amounts      `json:"total_payment,omitempty"` // Scheduled payment plus escrow, the cash the borrower pays
+	SMMCapped       []int        `json:"smm_capped,omitempty"`    // Periods whose SMM was clamped to the SMM cap
+	Period          []int        `json:"period"`                  // Period numbers (1, 2, 3, ...)
+	PaymentDates    []time.Time  `json:"payment_dates,omitempty"` // Date each period pays, for loans with an origination date
+	DelinqArrays    DelinqArrays `json:"delinq_arrays"`           // Delinquency performance arrays
+
+	// Interest accrues on the whole balance, delinquent or not; Interest is
+	// the accrued amount. With delinquency curves the table also carries what
+	// is collected in cash and the arrears owed by delinquent loans
+	CollectedInterest Amounts `json:"collected_interest,omitempty"` // Interest paid by performing loans plus arrears remitted on cure
+	InterestArrears   Amounts `json:"interest_arrears,omitempty"`   // Accrued interest still owed by delinquent loans at period end
+
+	// Defaults are taken from the scheduled balance ahead of prepayment; the
+	// columns are present only for loans with a default model
+	DefaultAmountArr  Amounts `json:"default_amount_arr,omitempty"` // Balance defaulting in each period
+	LossArr           Amounts `json:"loss_arr,omitempty"`           // Loss on each period's defaults; the rest is recovered
+	CumulativeDefault Amounts `json:"cumulative_default,omitempty"` // Running total of defaulted balance
+	CumulativeLoss    Amounts `json:"cumulative_loss,omitempty"`    // Running total of losses
+
+	// EffectiveMaturity is the period in which prepayments retired the loan
+	// ahead of its stated term, or 0 when it runs the full term
+	EffectiveMaturity int `json:"effective_maturity"`
+	// PeriodsPerYear is the payment frequency of a loan not paying monthly,
+	// 26 for biweekly; zero means monthly
+	PeriodsPerYear int `json:"periods_per_year,omitempty"`
+	// Diagnostics records what the engine resolved while generating the table
+	Diagnostics Diagnostics `json:"diagnostics"`
+}
+
+// Diagnostics describes the assumptions a table was generated under, for
+// explaining surprising numbers
+type Diagnostics struct {
+	MonthlyRate    float64 `json:"monthly_rate"`     // Periodic rate at the initial coupon, in decimals
+	MonthlyPayment float64 `json:"monthly_payment"`  // Level payment at origination, before any rate reset
+	PrepayModel    string  `json:"prepay_model"`     // Prepayment model consulted each period
+	NegAmClamped   bool    `json:"neg_am_clamped"`   // Some period's interest exceeded the payment and its principal was floored at zero
+	TrueUpAdjusted bool    `json:"true_up_adjusted"` // TrueUpBalances changed a period's principal to reconcile rounding
+}
+
+// PeriodRow is a single period of an amortization table, used for the
+// row-major JSON layout.
+type PeriodRow struct {
+	Period       int       `json:"period"`                  // Period number
+	BegBal       float64   `json:"beg_bal"`                 // Beginning balance
+	Interest     float64   `json:"interest"`                // Interest payment
+	Principal    float64   `json:"principal"`               // Principal payment
+	Payment      float64   `json:"payment"`                 // Scheduled payment
+	SchedBal     float64   `json:"sched_bal"`               // Scheduled balance after payment
+	PrepayAmount float64   `json:"prepay_amount"`           // Prepayment amount
+	EndBal       float64   `json:"end_bal"`                 // Ending balance
+	Penalty      float64   `json:"penalty,omitempty"`       // Prepayment penalty
+	Default      float64   `json:"default,omitempty"`       // Balance defaulting
+	Loss         float64   `json:"loss,omitempty"`          // Loss on the defaulted balance
+	Factor       float64   `json:"factor,omitempty"`        // Ending balance relative to the original face
+	Escrow       float64   `json:"escrow,omitempty"`        // Escrow collected with the payment
+	PaymentDate  time.Time `json:"payment_date,omitzero"`   // Date the period pays, for dated loans
+	TotalPayment float64   `json:"total_payment,omitempty"` // Scheduled payment plus escrow
+}
+
+// TableSummary condenses an amortization table into headline statistics for
+// consumers that do not need the per-period columns.
+type TableSummary struct {
+	Periods        int       `json:"periods"`         // Number of periods in the table
+	TotalInterest  float64   `json:"total_interest"`  // Sum of interest over all periods
+	TotalPrincipal float64   `json:"total_principal"` // Sum of scheduled and prepaid principal
+	WAL            float64   `json:"wal"`             // Weighted average life in years
+	FinalEndBal    float64   `json:"final_end_bal"`   // Ending balance of the last period
+	FactorCurve    []float64 `json:"factor_curve"`    // Ending balance as a fraction of the opening balance
+	// PV is the present value of the cashflows, reported by SensitivityGrid
+	// at the base loan's coupon; Summary leaves it zero
+	PV float64 `json:"pv,omitempty"`
 }
 
 // ensure SMM array is not nil
@@ -105,8 +229,7 @@ func (p *PrepayInfo) ConvertCPRToSMM(numMonths int) []float64 {
 	// numPeriods is the number of months
 	if p.PrepayCPR >= 0.0 {
 		log.Println("Converting CPR to SMM array for loan:")
-		// Correct SMM formula: SMM = 1 - (1 - CPR)^(1/12)
-		smm := 1 - math.Pow(1-p.PrepayCPR, 1.0/12.0)
+		smm := SMMFromCPR(p.PrepayCPR)
 
 		// Create SMM array with same value for all periods
 		p.SMMArr = make([]float64, numMonths)
@@ -142,93 +265,57 @@ func (p *PrepayInfo) ConvertCPRToSMM(numMonths int) []float64 {
 //	    Wac:  4.5,        // 4.5% annual rate
 //	    Face: 250000.0,   // $250,000 loan
 //	}
-//	table := GetAmortizationTable(loanInfo)
-// func (l *LoanInfo) GetAmortizationTable() AmortizationTable {
-// 	// 🟢 PRE-ALLOCATE: Avoid dynamic slice growth
-// 	numPeriods := int(l.Wam)
-// 	periods := make([]int, numPeriods)
-// 	begBal := make([]float64, numPeriods)
-// 	schedBal := make([]float64, numPeriods)
-// 	endBal := make([]float64, numPeriods)
-// 	prepayAmountArr := make([]float64, numPeriods)
-// 	interest := make([]float64, numPeriods)
-// 	principal := make([]float64, numPeriods)
-
-// 	// perfArray := make([]float64, numPeriods)
-// 	// dq30Array := make([]float64, numPeriods)
-// 	// dq60Array := make([]float64, numPeriods)
-// 	// dq90Array := make([]float64, numPeriods)
-// 	// dq120Array := make([]float64, numPeriods)
-// 	// dq150Array := make([]float64, numPeriods)
-// 	// dq180Array := make([]float64, numPeriods)
-// 	// defaultArray := make([]float64, numPeriods)
-
-// 	// 🟢 PRE-CALCULATE: Move expensive calculations outside loop
-// 	monthlyRate := l.Wac / 12.0 / 100.0
-
-// 	// 🟢 PRE-CALCULATE: SMM conversion once
-// 	l.ConvertCPRToSMM()
-
-// 	// 🟢 OPTIMIZED: Use simple payment calculation instead of PPmt
-// 	monthlyPayment := calculateMonthlyPayment(l.Face, monthlyRate, float64(l.Wam))
-
-// 	tmp_face := l.Face
-
-// 	transitionArrLen := 8
-
-// 	// initTransition := []float64{1.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0}
-// 	initTransition := make([]float64, transitionArrLen)
-
-// 	// 🟢 OPTIMIZED: Single loop with pre-allocated slices
-// 	for j := 0; j < numPeriods; j++ {
-// 		i := l.Wam - int64(j) // Remaining periods
-
-// 		periods[j] = j + 1
-// 		begBal[j] = roundToCent(tmp_face)
-
-// 		// 🟢 FAST: Simple multiplication instead of expensive PPmt
-// 		interestPayment := tmp_face * monthlyRate
-// 		interest[j] = roundToCent(interestPayment)
-
-// 		// Calculate principal using standard formula
-// 		var principalPayment float64
-// 		if i == 1 {
-// 			// Final payment: all remaining balance
-// 			principalPayment = tmp_face
-// 		} else {
-// 			principalPayment = monthlyPayment - interestPayment
-// 		}
-// 		principal[j] = roundToCent(principalPayment)
-
-// 		currentSchedBal := tmp_face - principalPayment
-// 		schedBal[j] = roundToCent(currentSchedBal)
-
-// 		// Calculate prepayment
-// 		prepayAmount := l.SMMArr[j] * currentSchedBal
-// 		prepayAmountArr[j] = roundToCent(prepayAmount)
-
-// 		// Update remaining balance
-// 		tmp_face = currentSchedBal - prepayAmount
-// 		if tmp_face < 0.0 {
-// 			tmp_face = 0.0
-// 		}
-
-// 		endBal[j] = roundToCent(tmp_face)
-// 	}
+//	table := loanInfo.GetAmortizationTable()
+func (l *LoanInfo) GetAmortizationTable() AmortizationTable {
+	// The rows are generated one period at a time by the same stepper that
+	// backs AmortizationIterator, and collected into columns here
+	return l.newAmortizer().table()
+}
 
-// 	amortTable := AmortizationTable{
-// 		Period:          periods,
-// 		BegBal:          begBal,
-// 		SchedBal:        schedBal,
-// 		PrepayAmountArr: prepayAmountArr,
-// 		Interest:        interest,
-// 		Principal:       principal,
-// 		EndBal:          endBal,
-// 		DelinqArrays:    DelinqArrays{},
-// 	}
+// completeTable fills the columns derived from the balance and cashflow
+// columns: payments, prepayment penalties, factors, escrow, payment dates,
+// delinquency curves and interest collections, cumulative defaults and
+// losses, and the effective maturity
+func (l *LoanInfo) completeTable(a *AmortizationTable) {
+	a.Payment = paymentsOf(a.Interest, a.Principal)
+	a.PenaltyArr = l.penaltyCashflows(a.PrepayAmountArr)
+	if l.biweekly() {
+		a.PeriodsPerYear = l.periodsPerYear()
+	}
+	if l.OrigFace > 0 {
+		a.FactorArr = factorsOf(a.EndBal, l.OrigFace)
+	}
+	if l.MonthlyEscrow > 0 {
+		a.EscrowArr = escrowsOf(a.BegBal, l.escrowPerPeriod())
+		a.TotalPayment = paymentsOf(a.Payment, a.EscrowArr)
+	}
+	if !l.OriginationDate.IsZero() {
+		a.PaymentDates = l.paymentDates(len(a.Period))
+	}
+	if l.StaticDQ {
+		a.DelinqArrays = l.delinquencyCurves(len(a.Period))
+		a.CollectedInterest, a.InterestArrears = l.interestCollections(a.Interest)
+	}
+	if l.hasDefaults() {
+		a.CumulativeDefault = runningTotal(a.DefaultAmountArr)
+		a.CumulativeLoss = runningTotal(a.LossArr)
+	}
 
-// 	return amortTable
-// }
+	// An early payoff is reported on the table; callers decide whether to
+	// log it, since the engine also builds tables for grids and solvers
+	a.EffectiveMaturity = effectiveMaturity(a.EndBal)
+}
+
+// effectiveMaturity returns the first period whose ending balance is zero,
+// or 0 when the balance is only retired in the final period
+func effectiveMaturity(endBal []float64) int {
+	for i := 0; i < len(endBal)-1; i++ {
+		if endBal[i] == 0.0 {
+			return i + 1
+		}
+	}
+	return 0
+}
 
 // func computerRollRate(
 // 	curTransition, perfTransition, dq30Transition, dq60Transition, dq90Transition,
@@ -242,64 +329,643 @@ func (p *PrepayInfo) ConvertCPRToSMM(numMonths int) []float64 {
 // 	return rollRates
 // }
 
-// // 🟢 FAST: Inline rounding function
-// func roundToCent(value float64) float64 {
-// 	return math.Round(value*100) / 100
-// }
+// paymentsOf returns the scheduled payment of each period
+func paymentsOf(interest, principal []float64) []float64 {
+	payments := make([]float64, len(interest))
+	for i := range interest {
+		payments[i] = roundToCent(interest[i] + principal[i])
+	}
+	return payments
+}
 
-// // 🟢 FAST: Standard monthly payment calculation
-// func calculateMonthlyPayment(principal, monthlyRate float64, numPayments float64) float64 {
-// 	if monthlyRate == 0 {
-// 		return principal / numPayments
-// 	}
+// paymentDates returns the date each of the first n periods pays: the end of
+// its accrual period
+func (l *LoanInfo) paymentDates(n int) []time.Time {
+	dates := make([]time.Time, n)
+	for j := range dates {
+		_, dates[j] = l.accrualPeriod(j)
+	}
+	return dates
+}
 
-// 	factor := math.Pow(1+monthlyRate, numPayments)
-// 	return principal * (monthlyRate * factor) / (factor - 1)
-// }
+// escrowsOf returns the escrow collected each period: the periodic amount
+// while a balance is outstanding, nothing once the loan has been retired
+func escrowsOf(begBal []float64, periodic float64) []float64 {
+	escrows := make([]float64, len(begBal))
+	for i, bal := range begBal {
+		if bal > 0 {
+			escrows[i] = roundToCent(periodic)
+		}
+	}
+	return escrows
+}
 
-// // TrueUpBalances adjusts the final period's balances to ensure mathematical consistency
-// func (a *AmortizationTable) TrueUpBalances() {
-// 	if len(a.Principal) == 0 {
-// 		return
-// 	}
+// factorsOf returns each balance as a fraction of base
+func factorsOf(balances []float64, base float64) []float64 {
+	factors := make([]float64, len(balances))
+	for i, bal := range balances {
+		factors[i] = bal / base
+	}
+	return factors
+}
 
-// 	lastIndex := len(a.Principal) - 1
-// 	// Get the last period's values
-// 	lastBegBal := a.BegBal[lastIndex]
-// 	lastPrincipal := a.Principal[lastIndex]
-// 	lastPrepay := a.PrepayAmountArr[lastIndex]
-// 	lastEndBal := a.EndBal[lastIndex]
+// dayCountFraction returns the loan's day-count convention and whether the
+// loan accrues over actual dates
+func (l *LoanInfo) dayCountFraction() (DayCountFraction, bool) {
+	if l.DayCount == "" || l.OriginationDate.IsZero() {
+		return nil, false
+	}
+	return LookupDayCount(l.DayCount)
+}
 
-// 	leftOver := lastBegBal - lastPrincipal - lastPrepay
+// accrualPeriod returns the dates period j (0-based) accrues over. Periods
+// run monthly from OriginationDate, or, when FirstPaymentDate is set, the
+// first period is the stub up to it and later periods run monthly from it.
+func (l *LoanInfo) accrualPeriod(j int) (start, end time.Time) {
+	if l.FirstPaymentDate.IsZero() {
+		return addMonths(l.OriginationDate, j), addMonths(l.OriginationDate, j+1)
+	}
+	if j == 0 {
+		return l.OriginationDate, l.FirstPaymentDate
+	}
+	return addMonths(l.FirstPaymentDate, j-1), addMonths(l.FirstPaymentDate, j)
+}
 
-// 	if math.Abs(leftOver-lastEndBal) < 0.01 {
-// 		return // Already balanced within rounding tolerance
-// 	}
+// periodRater returns the rate accrued in period j (0-based) at the period's
+// coupon: coupons[j], or the loan's coupon when coupons is nil. Undated loans
+// accrue a regular monthly rate every period. Dated loans accrue each period
+// over its actual dates under their day count; a stub first period on a loan
+// without a day count accrues under 30/360.
+func (l *LoanInfo) periodRater(coupons []float64) func(j int) float64 {
+	dayCount, dated := l.dayCountFraction()
+	stub := !l.FirstPaymentDate.IsZero()
+	if stub && !dated {
+		dayCount = thirty360
+	}
 
-// 	// Adjust the final principal payment to balance
-// 	if leftOver != lastEndBal {
-// 		adjustment := leftOver - lastEndBal
-// 		a.Principal[lastIndex] = lastPrincipal + adjustment
-// 		a.EndBal[lastIndex] = 0.0 // Final balance should be zero
-// 	}
-// }
+	return func(j int) float64 {
+		coupon := l.CouponPct()
+		if coupons != nil {
+			coupon = coupons[j]
+		}
+		if dated || (stub && j == 0) {
+			return l.accrualRate(coupon, dayCount(l.accrualPeriod(j)))
+		}
+		return l.regularRate(coupon)
+	}
+}
 
-// // Add validation function
-// func (l *LoanInfo) Validate() error {
-// 	if l.ID == "" {
-// 		return fmt.Errorf("loan ID cannot be empty")
-// 	}
-// 	if l.Wam <= 0 || l.Wam > 480 { // Max 40 years
-// 		return fmt.Errorf("WAM must be between 1 and 480 months, got %d", l.Wam)
-// 	}
-// 	if l.Wac < 0 || l.Wac > 30 { // Reasonable rate limits
-// 		return fmt.Errorf("WAC must be between 0 and 30 percent, got %f", l.Wac)
-// 	}
-// 	if l.Face <= 0 {
-// 		return fmt.Errorf("face value must be positive, got %f", l.Face)
-// 	}
-// 	if l.PrepayCPR < 0 || l.PrepayCPR >= 1 {
-// 		return fmt.Errorf("CPR must be between 0 and 1, got %f", l.PrepayCPR)
-// 	}
-// 	return nil
-// }
+// addMonths advances t by n calendar months, clamping to the last day of the
+// target month (January 31 plus one month is February 28 or 29)
+func addMonths(t time.Time, n int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+// defaultPayoffThreshold is the balance below which a loan is retired when
+// PayoffThreshold is not set
+const defaultPayoffThreshold = 0.01
+
+// payoffThreshold returns the balance below which the loan is retired
+func (l *LoanInfo) payoffThreshold() float64 {
+	if l.PayoffThreshold == 0 {
+		return defaultPayoffThreshold
+	}
+	return l.PayoffThreshold
+}
+
+// smmCap returns the ceiling applied to each period's SMM
+func (p *PrepayInfo) smmCap() float64 {
+	if p.SMMCap == 0 {
+		return 1.0
+	}
+	return p.SMMCap
+}
+
+// penaltyCashflows returns the prepayment penalty owed in each period, or nil
+// when the loan carries no penalty. The penalty is additional cashflow to the
+// investor and does not reduce the balance.
+func (l *LoanInfo) penaltyCashflows(prepayAmounts []float64) []float64 {
+	if l.PrepayPenaltyPct == 0 || l.PrepayPenaltyMonths == 0 {
+		return nil
+	}
+
+	penalties := make([]float64, len(prepayAmounts))
+	penaltyPeriods := l.periodsIn(l.PrepayPenaltyMonths)
+	for j := 0; j < len(prepayAmounts) && int64(j) < penaltyPeriods; j++ {
+		penalties[j] = roundToCent(l.PrepayPenaltyPct * prepayAmounts[j])
+	}
+	return penalties
+}
+
+// 🟢 FAST: Inline rounding function
+func roundToCent(value float64) float64 {
+	return round(value*100) / 100
+}
+
+// 🟢 FAST: Standard monthly payment calculation
+//
+// The annuity factor 1 - (1+r)^-n is evaluated as -expm1(-n*log1p(r)) rather
+// than through math.Pow, which avoids forming the large compounded balance and
+// the cancellation in (factor - 1) for small rates. Across the validated inputs
+// (up to 480 periods at 30% WAC) the result is within 1e-13 relative error of an
+// exact evaluation.
+func calculateMonthlyPayment(principal, monthlyRate float64, numPayments float64) float64 {
+	if monthlyRate == 0 {
+		return principal / numPayments
+	}
+
+	annuity := -math.Expm1(-numPayments * math.Log1p(monthlyRate))
+	return principal * monthlyRate / annuity
+}
+
+// TrueUpBalances re-rolls the balance columns from the rounded principal,
+// default and prepayment amounts so every period reconciles to the cent.
+// Rounding each period independently lets the cashflows drift from the
+// balance they retire (most visibly on zero-coupon loans); the accumulated
+// residual is folded into the principal of the payoff period so the final
+// balance is exactly zero.
+func (a *AmortizationTable) TrueUpBalances() {
+	if len(a.Principal) == 0 {
+		return
+	}
+
+	balance := a.BegBal[0]
+	paidOff := false
+	for i := range a.Principal {
+		a.BegBal[i] = balance
+
+		defaulted := columnAt(a.DefaultAmountArr, i)
+		switch {
+		case paidOff:
+			a.Principal[i] = 0.0
+			a.PrepayAmountArr[i] = 0.0
+			defaulted = 0.0
+		case a.EndBal[i] == 0.0:
+			// Payoff period: retire exactly what remains
+			a.PrepayAmountArr[i] = math.Min(a.PrepayAmountArr[i], balance)
+			defaulted = math.Min(defaulted, roundToCent(balance-a.PrepayAmountArr[i]))
+			a.Principal[i] = roundToCent(balance - a.PrepayAmountArr[i] - defaulted)
+			paidOff = true
+		default:
+			a.Principal[i] = math.Min(a.Principal[i], balance)
+			defaulted = math.Min(defaulted, roundToCent(balance-a.Principal[i]))
+			a.PrepayAmountArr[i] = math.Min(a.PrepayAmountArr[i], roundToCent(balance-a.Principal[i]-defaulted))
+		}
+		if i < len(a.DefaultAmountArr) {
+			a.DefaultAmountArr[i] = defaulted
+			if i < len(a.LossArr) {
+				a.LossArr[i] = math.Min(a.LossArr[i], defaulted)
+			}
+		}
+
+		a.SchedBal[i] = roundToCent(balance - a.Principal[i])
+		balance = roundToCent(a.SchedBal[i] - defaulted - a.PrepayAmountArr[i])
+		a.EndBal[i] = balance
+	}
+}
+
+// TotalInterest returns the sum of interest paid over the life of the table
+func (a *AmortizationTable) TotalInterest() float64 {
+	total := 0.0
+	for _, interest := range a.Interest {
+		total += interest
+	}
+	return roundToCent(total)
+}
+
+// WAL returns the weighted average life in years, weighting each period's
+// principal (scheduled plus prepaid) by the time at which it is returned.
+func (a *AmortizationTable) WAL() float64 {
+	weighted := 0.0
+	totalPrincipal := 0.0
+	for i := range a.Principal {
+		paid := a.Principal[i] + a.PrepayAmountArr[i]
+		weighted += float64(a.Period[i]) * paid
+		totalPrincipal += paid
+	}
+	if totalPrincipal == 0 {
+		return 0
+	}
+	return weighted / totalPrincipal / a.periodsPerYear()
+}
+
+// periodsPerYear returns the number of periods in a year of the table
+func (a *AmortizationTable) periodsPerYear() float64 {
+	if a.PeriodsPerYear > 0 {
+		return float64(a.PeriodsPerYear)
+	}
+	return 12
+}
+
+// FinalEndBal returns the ending balance of the last period
+func (a *AmortizationTable) FinalEndBal() float64 {
+	if len(a.EndBal) == 0 {
+		return 0
+	}
+	return a.EndBal[len(a.EndBal)-1]
+}
+
+// CumulativePrincipal returns the running total of principal returned
+// through each period, scheduled plus prepaid. Over a full table the final
+// value is the opening balance.
+func (a *AmortizationTable) CumulativePrincipal() []float64 {
+	cumulative := make([]float64, len(a.Principal))
+	total := 0.0
+	for i := range a.Principal {
+		total = roundToCent(total + a.Principal[i] + a.PrepayAmountArr[i])
+		cumulative[i] = total
+	}
+	return cumulative
+}
+
+// CumulativeInterest returns the running total of interest paid through each
+// period. The final value is TotalInterest.
+func (a *AmortizationTable) CumulativeInterest() []float64 {
+	return runningTotal(a.Interest)
+}
+
+// Trim drops the trailing periods that begin with a zero balance, which
+// follow an early payoff. The payoff period itself is kept.
+func (a *AmortizationTable) Trim() {
+	n := len(a.Period)
+	for n > 1 && a.BegBal[n-1] == 0.0 {
+		n--
+	}
+	if n == len(a.Period) {
+		return
+	}
+
+	a.Period = a.Period[:n]
+	for _, col := range []*Amounts{
+		&a.BegBal, &a.Interest, &a.Principal, &a.Payment, &a.SchedBal, &a.PrepayAmountArr, &a.EndBal,
+		&a.PenaltyArr, &a.EscrowArr, &a.TotalPayment, &a.DefaultAmountArr, &a.LossArr, &a.CumulativeDefault, &a.CumulativeLoss,
+		&a.CollectedInterest, &a.InterestArrears,
+	} {
+		truncateColumn(col, n)
+	}
+	truncateColumn(&a.FactorArr, n)
+	if len(a.PaymentDates) > n {
+		a.PaymentDates = a.PaymentDates[:n]
+	}
+	for _, col := range []*[]float64{
+		&a.DelinqArrays.PerfArr, &a.DelinqArrays.DQ30Arr, &a.DelinqArrays.DQ60Arr, &a.DelinqArrays.DQ90Arr,
+		&a.DelinqArrays.DQ120Arr, &a.DelinqArrays.DQ150Arr, &a.DelinqArrays.DQ180Arr, &a.DelinqArrays.DefaultArr,
+	} {
+		truncateColumn(col, n)
+	}
+}
+
+// truncateColumn shortens col to n entries when it is longer
+func truncateColumn[S ~[]float64](col *S, n int) {
+	if len(*col) > n {
+		*col = (*col)[:n]
+	}
+}
+
+// Summary returns the headline statistics of the table
+func (a *AmortizationTable) Summary() TableSummary {
+	totalPrincipal := 0.0
+	for i := range a.Principal {
+		totalPrincipal += a.Principal[i] + a.PrepayAmountArr[i]
+	}
+
+	// Factors are relative to the original face when known, else the opening balance
+	factors := a.FactorArr
+	if factors == nil {
+		factors = make([]float64, len(a.EndBal))
+		if len(a.BegBal) > 0 && a.BegBal[0] > 0 {
+			factors = factorsOf(a.EndBal, a.BegBal[0])
+		}
+	}
+
+	return TableSummary{
+		Periods:        len(a.Period),
+		TotalInterest:  a.TotalInterest(),
+		TotalPrincipal: roundToCent(totalPrincipal),
+		WAL:            a.WAL(),
+		FinalEndBal:    a.FinalEndBal(),
+		FactorCurve:    factors,
+	}
+}
+
+// ParCheck discounts the interest, principal, and prepayment cashflows at the
+// coupon wac (in percentage points) and reports the present value along with
+// whether it matches the opening balance, as it must for any correctly
+// amortized loan priced at its own rate. Penalties are excluded. The tolerance
+// is half a cent per period, the most that cent rounding can move each
+// cashflow.
+func (a *AmortizationTable) ParCheck(wac float64) (float64, bool) {
+	if len(a.Period) == 0 {
+		return 0, true
+	}
+
+	periodRate := a.periodDiscountRate(wac)
+	pv := 0.0
+	discount := 1.0
+	for i := range a.Period {
+		discount /= 1 + periodRate
+		pv += (a.Interest[i] + a.Principal[i] + a.PrepayAmountArr[i]) * discount
+	}
+
+	tolerance := 0.005 * float64(len(a.Period))
+	return pv, math.Abs(pv-a.BegBal[0]) <= tolerance
+}
+
+// checkTolerance absorbs the cent rounding applied independently to each column
+const checkTolerance = 0.02
+
+// Check verifies the internal invariants of the table: every column has one
+// entry per period, each period's beginning balance equals the prior ending
+// balance, each ending balance equals the beginning balance less principal,
+// defaults and prepayment, and no balance is negative. The first violation
+// found is returned.
+func (a *AmortizationTable) Check() error {
+	n := len(a.Period)
+	columns := []struct {
+		name   string
+		length int
+	}{
+		{"beg_bal", len(a.BegBal)},
+		{"interest", len(a.Interest)},
+		{"principal", len(a.Principal)},
+		{"sched_bal", len(a.SchedBal)},
+		{"prepay_amount_arr", len(a.PrepayAmountArr)},
+		{"end_bal", len(a.EndBal)},
+	}
+	if a.DefaultAmountArr != nil {
+		columns = append(columns, struct {
+			name   string
+			length int
+		}{"default_amount_arr", len(a.DefaultAmountArr)})
+	}
+	for _, col := range columns {
+		if col.length != n {
+			return fmt.Errorf("column %s has %d entries, expected %d", col.name, col.length, n)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if a.BegBal[i] < 0 {
+			return fmt.Errorf("period %d: negative beginning balance %.2f", a.Period[i], a.BegBal[i])
+		}
+		if a.EndBal[i] < 0 {
+			return fmt.Errorf("period %d: negative ending balance %.2f", a.Period[i], a.EndBal[i])
+		}
+		if i > 0 && math.Abs(a.BegBal[i]-a.EndBal[i-1]) > checkTolerance {
+			return fmt.Errorf("period %d: beginning balance %.2f does not match prior ending balance %.2f",
+				a.Period[i], a.BegBal[i], a.EndBal[i-1])
+		}
+		expected := a.BegBal[i] - a.Principal[i] - a.PrepayAmountArr[i] - columnAt(a.DefaultAmountArr, i)
+		if math.Abs(a.EndBal[i]-expected) > checkTolerance {
+			return fmt.Errorf("period %d: ending balance %.2f does not equal beginning balance less principal, defaults and prepayment %.2f",
+				a.Period[i], a.EndBal[i], expected)
+		}
+	}
+
+	return nil
+}
+
+// Rows returns the table as one PeriodRow per period
+func (a *AmortizationTable) Rows() []PeriodRow {
+	rows := make([]PeriodRow, len(a.Period))
+	for i := range rows {
+		rows[i] = PeriodRow{
+			Period:       a.Period[i],
+			BegBal:       a.BegBal[i],
+			Interest:     a.Interest[i],
+			Principal:    a.Principal[i],
+			SchedBal:     a.SchedBal[i],
+			PrepayAmount: a.PrepayAmountArr[i],
+			EndBal:       a.EndBal[i],
+		}
+		if i < len(a.Payment) {
+			rows[i].Payment = a.Payment[i]
+		}
+		if i < len(a.PenaltyArr) {
+			rows[i].Penalty = a.PenaltyArr[i]
+		}
+		if i < len(a.DefaultAmountArr) {
+			rows[i].Default = a.DefaultAmountArr[i]
+			rows[i].Loss = a.LossArr[i]
+		}
+		if i < len(a.EscrowArr) {
+			rows[i].Escrow = a.EscrowArr[i]
+			rows[i].TotalPayment = a.TotalPayment[i]
+		}
+		if i < len(a.PaymentDates) {
+			rows[i].PaymentDate = a.PaymentDates[i]
+		}
+		if i < len(a.FactorArr) {
+			rows[i].Factor = a.FactorArr[i]
+		}
+	}
+	return rows
+}
+
+// MarshalRows serializes the table row-major, as an array of per-period
+// objects, instead of the default column-major parallel arrays.
+func (a *AmortizationTable) MarshalRows() ([]byte, error) {
+	return json.Marshal(a.Rows())
+}
+
+// TableFields returns the JSON names of the table's columns in declaration
+// order, read from the struct tags so it cannot drift from the struct
+func TableFields() []string {
+	t := reflect.TypeOf(AmortizationTable{})
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// Project returns only the named columns of the table, keyed by JSON name.
+// Columns omitted from the table's JSON because they are empty are returned
+// as null. Unknown names are an error.
+func (a *AmortizationTable) Project(fields []string) (map[string]json.RawMessage, error) {
+	known := make(map[string]bool)
+	for _, name := range TableFields() {
+		known[name] = true
+	}
+
+	encoded, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	var columns map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &columns); err != nil {
+		return nil, err
+	}
+
+	projected := make(map[string]json.RawMessage, len(fields))
+	for _, name := range fields {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown table field %q", name)
+		}
+		if column, ok := columns[name]; ok {
+			projected[name] = column
+		} else {
+			projected[name] = json.RawMessage("null")
+		}
+	}
+	return projected, nil
+}
+
+// decimalWacFaceThreshold is the face above which a sub-1.0 coupon is more
+// likely a decimal rate passed by mistake than a genuine sub-1% loan
+const decimalWacFaceThreshold = 10000.0
+
+// CurrentFace returns the balance to amortize: OrigFace * Factor when a factor
+// is given, otherwise Face
+func (l *LoanInfo) CurrentFace() float64 {
+	if l.Factor != 0 {
+		return l.OrigFace * l.Factor
+	}
+	return l.Face
+}
+
+// ScaleTo returns a copy of the loan with a current face of targetFace and
+// the same term, coupon and prepayment assumptions, for sizing a deal from a
+// representative loan. A seasoned loan keeps its factor and has its original
+// balance scaled to match. Balances and cashflows of the copy's table scale
+// linearly with the face, to the cent.
+func (l LoanInfo) ScaleTo(targetFace float64) LoanInfo {
+	scaled := l
+	if scaled.Factor != 0 {
+		scaled.OrigFace = targetFace / scaled.Factor
+	}
+	scaled.Face = targetFace
+	return scaled
+}
+
+// CouponPct returns the annual coupon in percentage points, converting Wac
+// when it was supplied as a decimal
+func (l *LoanInfo) CouponPct() float64 {
+	if l.WacIsDecimal {
+		return l.Wac * 100.0
+	}
+	return l.Wac
+}
+
+// WacLooksLikeDecimal reports whether Wac appears to have been supplied as a
+// decimal (e.g., 0.045) while being interpreted as percentage points, which
+// produces a nonsensically small payment.
+func (l *LoanInfo) WacLooksLikeDecimal() bool {
+	return !l.WacIsDecimal && l.Wac > 0 && l.Wac < 1.0 && l.CurrentFace() >= decimalWacFaceThreshold
+}
+
+// minFace is the smallest balance a loan may have. A face below one cent
+// rounds every interest and principal payment to zero, so the schedule would
+// never pay down; such loans are rejected rather than paid off in period one.
+const minFace = 0.01
+
+// maxWam is the longest term, in months, a loan may have (40 years)
+const maxWam = 480
+
+// numPeriods returns the number of periods the engine generates for the
+// loan. A term outside the validated range, which on 32-bit platforms may not
+// even fit in an int, generates none rather than sizing columns from it.
+func (l *LoanInfo) numPeriods() int {
+	if l.Wam < 0 || l.Wam > maxWam {
+		slog.Warn("loan term outside the supported range; generating no periods",
+			slog.String("loan_id", l.ID),
+			slog.Int64("wam", l.Wam),
+		)
+		return 0
+	}
+	return int(l.periodsIn(l.Wam))
+}
+
+// Add validation function
+func (l *LoanInfo) Validate() error {
+	if l.ID == "" {
+		return fmt.Errorf("loan ID cannot be empty")
+	}
+	// IDs name the loan's output files and archive entries, so they must not
+	// be able to step outside the output directory
+	if strings.Contains(l.ID, "..") || strings.ContainsAny(l.ID, `/\`) || strings.IndexFunc(l.ID, unicode.IsControl) >= 0 {
+		return fmt.Errorf("loan ID %q cannot contain path separators, \"..\" or control characters", l.ID)
+	}
+	if l.Wam <= 0 || l.Wam > maxWam {
+		return fmt.Errorf("WAM must be between 1 and %d months, got %d", maxWam, l.Wam)
+	}
+	if l.CouponPct() < 0 || l.CouponPct() > 30 { // Reasonable rate limits
+		return fmt.Errorf("WAC must be between 0 and 30 percent, got %f", l.CouponPct())
+	}
+	if l.Factor != 0 {
+		if l.Factor < 0 || l.Factor > 1 {
+			return fmt.Errorf("factor must be greater than 0 and at most 1, got %f", l.Factor)
+		}
+		if l.OrigFace <= 0 {
+			return fmt.Errorf("original face must be positive when a factor is given, got %f", l.OrigFace)
+		}
+	}
+	if l.CurrentFace() < minFace {
+		return fmt.Errorf("face value must be at least one cent, got %f", l.CurrentFace())
+	}
+	if l.PrepayCPR < 0 || l.PrepayCPR >= 1 {
+		return fmt.Errorf("CPR must be between 0 and 1, got %f", l.PrepayCPR)
+	}
+	if l.PrepayPenaltyPct < 0 || l.PrepayPenaltyPct >= 1 {
+		return fmt.Errorf("prepay penalty must be between 0 and 1, got %f", l.PrepayPenaltyPct)
+	}
+	if l.DayCount != "" {
+		if _, ok := LookupDayCount(l.DayCount); !ok {
+			return fmt.Errorf("unknown day count convention %q", l.DayCount)
+		}
+		if l.OriginationDate.IsZero() {
+			return fmt.Errorf("origination date is required with day count %q", l.DayCount)
+		}
+	}
+	if !l.FirstPaymentDate.IsZero() {
+		if l.OriginationDate.IsZero() {
+			return fmt.Errorf("origination date is required with a first payment date")
+		}
+		if !l.FirstPaymentDate.After(l.OriginationDate) {
+			return fmt.Errorf("first payment date %s must be after origination %s",
+				l.FirstPaymentDate.Format(time.DateOnly), l.OriginationDate.Format(time.DateOnly))
+		}
+	}
+	if err := l.validateARM(); err != nil {
+		return err
+	}
+	if err := l.validateFrequency(); err != nil {
+		return err
+	}
+	if err := l.ValidateTransitions(); err != nil {
+		return err
+	}
+	if !l.Compounding.valid() {
+		return fmt.Errorf("unknown compounding convention %q", l.Compounding)
+	}
+	if l.SMMCap < 0 || l.SMMCap > 1 {
+		return fmt.Errorf("SMM cap must be between 0 and 1, got %f", l.SMMCap)
+	}
+	if l.PrepayPenaltyMonths < 0 {
+		return fmt.Errorf("prepay penalty months cannot be negative, got %d", l.PrepayPenaltyMonths)
+	}
+	if l.MonthlyEscrow < 0 {
+		return fmt.Errorf("monthly escrow cannot be negative, got %f", l.MonthlyEscrow)
+	}
+	if l.DefaultCDR < 0 || l.DefaultCDR >= 1 {
+		return fmt.Errorf("CDR must be between 0 and 1, got %f", l.DefaultCDR)
+	}
+	if l.SeverityPct < 0 || l.SeverityPct > 1 {
+		return fmt.Errorf("severity must be between 0 and 1, got %f", l.SeverityPct)
+	}
+	for i, severity := range l.SeverityArr {
+		if severity < 0 || severity > 1 {
+			return fmt.Errorf("severity_arr[%d] must be between 0 and 1, got %f", i, severity)
+		}
+	}
+	if l.PayoffThreshold < 0 {
+		return fmt.Errorf("payoff threshold cannot be negative, got %f", l.PayoffThreshold)
+	}
+	return nil
+}
@@ -1,8 +1,15 @@
 package amortization
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"math"
+	"math/big"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEnsureSMMArrayInitialized(t *testing.T) {
@@ -223,3 +230,987 @@ func TestConvertCPRToSMM_Formula(t *testing.T) {
 			prepay.PrepayCPR, calculatedCPR)
 	}
 }
+
+func TestGetAmortizationTable_NoPrepay(t *testing.T) {
+	loan := &LoanInfo{
+		ID:   "LOAN001",
+		Wam:  360,
+		Wac:  4.5,
+		Face: 250000.0,
+	}
+
+	table := loan.GetAmortizationTable()
+
+	if len(table.Period) != 360 {
+		t.Fatalf("Expected 360 periods, got %d", len(table.Period))
+	}
+	if table.BegBal[0] != 250000.0 {
+		t.Errorf("Expected first beginning balance 250000.00, got %.2f", table.BegBal[0])
+	}
+	if table.Interest[0] != 937.5 {
+		t.Errorf("Expected first period interest 937.50, got %.2f", table.Interest[0])
+	}
+	if math.Abs(table.FinalEndBal()) > 0.01 {
+		t.Errorf("Expected final end balance 0.00, got %.2f", table.FinalEndBal())
+	}
+}
+
+func TestAmortizationTable_TotalInterestAndWAL(t *testing.T) {
+	table := AmortizationTable{
+		Period:          []int{1, 2, 3},
+		Interest:        []float64{10.0, 5.0, 2.5},
+		Principal:       []float64{100.0, 100.0, 100.0},
+		PrepayAmountArr: []float64{0.0, 0.0, 0.0},
+		EndBal:          []float64{200.0, 100.0, 0.0},
+	}
+
+	if got := table.TotalInterest(); got != 17.5 {
+		t.Errorf("Expected total interest 17.50, got %.2f", got)
+	}
+
+	// Equal principal in periods 1-3 gives an average of 2 months
+	if got := table.WAL(); math.Abs(got-2.0/12.0) > 1e-9 {
+		t.Errorf("Expected WAL %.6f years, got %.6f", 2.0/12.0, got)
+	}
+
+	if got := table.FinalEndBal(); got != 0.0 {
+		t.Errorf("Expected final end balance 0.00, got %.2f", got)
+	}
+}
+
+func TestAmortizationTable_CumulativeCurves(t *testing.T) {
+	loan := LoanInfo{ID: "CUM", Wam: 360, Wac: 6.5, Face: 287654.32, PrepayInfo: PrepayInfo{PrepayCPR: 0.08}}
+	table := loan.GetAmortizationTable()
+
+	principal := table.CumulativePrincipal()
+	interest := table.CumulativeInterest()
+	if len(principal) != 360 || len(interest) != 360 {
+		t.Fatalf("Expected 360 cumulative values, got %d and %d", len(principal), len(interest))
+	}
+	for i := 1; i < len(principal); i++ {
+		if principal[i] < principal[i-1] || interest[i] < interest[i-1] {
+			t.Fatalf("Period %d: expected non-decreasing curves, got principal %.2f -> %.2f, interest %.2f -> %.2f",
+				i+1, principal[i-1], principal[i], interest[i-1], interest[i])
+		}
+	}
+	if got := principal[len(principal)-1]; math.Abs(got-loan.Face) > 0.01 {
+		t.Errorf("Expected cumulative principal to reach the face %.2f, got %.2f", loan.Face, got)
+	}
+	if got := interest[len(interest)-1]; got != table.TotalInterest() {
+		t.Errorf("Expected cumulative interest to reach the total %.2f, got %.2f", table.TotalInterest(), got)
+	}
+
+	empty := AmortizationTable{}
+	if len(empty.CumulativePrincipal()) != 0 || len(empty.CumulativeInterest()) != 0 {
+		t.Error("Expected empty curves for an empty table")
+	}
+}
+
+func TestGetAmortizationTable_PayoffThreshold(t *testing.T) {
+	testCases := []struct {
+		name      string
+		loan      LoanInfo
+		wantEarly bool
+	}{
+		{name: "float", loan: LoanInfo{ID: "THRESH", Wam: 360, Wac: 6.0, Face: 180000.0, PayoffThreshold: 250.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.10}}, wantEarly: true},
+		{name: "integer cents", loan: LoanInfo{ID: "THRESH-C", Wam: 360, Wac: 6.0, Face: 180000.0, PayoffThreshold: 250.0, IntegerCents: true, PrepayInfo: PrepayInfo{PrepayCPR: 0.10}}, wantEarly: true},
+		{name: "level", loan: LoanInfo{ID: "THRESH-L", Wam: 120, Wac: 4.0, Face: 50000.0, PayoffThreshold: 100.0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			face := tc.loan.Face
+			table := tc.loan.GetAmortizationTable()
+
+			paidOff := 0
+			for i, endBal := range table.EndBal {
+				if endBal > 0 && endBal < tc.loan.PayoffThreshold {
+					t.Fatalf("Period %d: end balance %.2f is below the %.2f threshold", i+1, endBal, tc.loan.PayoffThreshold)
+				}
+				if endBal == 0 && paidOff == 0 {
+					paidOff = i + 1
+				}
+			}
+			if early := paidOff > 0 && paidOff < len(table.EndBal); early != tc.wantEarly {
+				t.Fatalf("Expected early payoff %v, paid off in period %d of %d", tc.wantEarly, paidOff, len(table.EndBal))
+			}
+			// The residual is folded into the payoff period's principal
+			i := paidOff - 1
+			if got := roundToCent(table.Principal[i] + table.PrepayAmountArr[i]); got != table.BegBal[i] {
+				t.Errorf("Expected the payoff period to retire its %.2f opening balance, got %.2f", table.BegBal[i], got)
+			}
+			if err := table.Check(); err != nil {
+				t.Errorf("Expected a consistent table, got %v", err)
+			}
+			if got := table.CumulativePrincipal()[len(table.Period)-1]; math.Abs(got-face) > 0.01 {
+				t.Errorf("Expected principal to total the face %.2f, got %.2f", face, got)
+			}
+		})
+	}
+
+	// The default threshold leaves the schedule unchanged
+	explicit := LoanInfo{ID: "CENT", Wam: 360, Wac: 6.0, Face: 180000.0, PayoffThreshold: 0.01, PrepayInfo: PrepayInfo{PrepayCPR: 0.10}}
+	implicit := LoanInfo{ID: "CENT", Wam: 360, Wac: 6.0, Face: 180000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.10}}
+	a, b := explicit.GetAmortizationTable(), implicit.GetAmortizationTable()
+	if !reflect.DeepEqual(a.EndBal, b.EndBal) || !reflect.DeepEqual(a.Principal, b.Principal) {
+		t.Error("Expected a one-cent threshold to match the default schedule")
+	}
+
+	if err := (&LoanInfo{ID: "NEG", Wam: 360, Wac: 6.0, Face: 1000.0, PayoffThreshold: -1}).Validate(); err == nil {
+		t.Error("Expected a negative payoff threshold to fail validation")
+	}
+}
+
+func TestAmortizationTable_WALEmpty(t *testing.T) {
+	table := AmortizationTable{}
+	if got := table.WAL(); got != 0 {
+		t.Errorf("Expected WAL 0 for empty table, got %f", got)
+	}
+}
+
+func TestAmortizationTable_Summary(t *testing.T) {
+	table := AmortizationTable{
+		Period:          []int{1, 2},
+		BegBal:          []float64{200.0, 100.0},
+		Interest:        []float64{2.0, 1.0},
+		Principal:       []float64{90.0, 100.0},
+		PrepayAmountArr: []float64{10.0, 0.0},
+		EndBal:          []float64{100.0, 0.0},
+	}
+
+	summary := table.Summary()
+
+	if summary.Periods != 2 {
+		t.Errorf("Expected 2 periods, got %d", summary.Periods)
+	}
+	if summary.TotalInterest != 3.0 {
+		t.Errorf("Expected total interest 3.00, got %.2f", summary.TotalInterest)
+	}
+	if summary.TotalPrincipal != 200.0 {
+		t.Errorf("Expected total principal 200.00, got %.2f", summary.TotalPrincipal)
+	}
+	if len(summary.FactorCurve) != 2 || summary.FactorCurve[0] != 0.5 || summary.FactorCurve[1] != 0.0 {
+		t.Errorf("Expected factor curve [0.5 0], got %v", summary.FactorCurve)
+	}
+}
+
+func TestAmortizationTable_Check_GeneratedTables(t *testing.T) {
+	for _, cpr := range []float64{0.0, 0.06, 0.5, 0.99} {
+		loan := &LoanInfo{ID: "CHECK", Wam: 360, Wac: 6.0, Face: 300000.0}
+		loan.PrepayCPR = cpr
+
+		table := loan.GetAmortizationTable()
+		if err := table.Check(); err != nil {
+			t.Errorf("CPR %.2f: expected generated table to pass Check, got %v", cpr, err)
+		}
+	}
+}
+
+func TestAmortizationTable_Check_DetectsCorruption(t *testing.T) {
+	testCases := []struct {
+		name    string
+		corrupt func(a *AmortizationTable)
+		wantErr string
+	}{
+		{
+			name:    "short column",
+			corrupt: func(a *AmortizationTable) { a.Interest = a.Interest[:len(a.Interest)-1] },
+			wantErr: "column interest has 11 entries, expected 12",
+		},
+		{
+			name:    "broken balance roll",
+			corrupt: func(a *AmortizationTable) { a.BegBal[5] += 100 },
+			wantErr: "period 6: beginning balance",
+		},
+		{
+			name:    "principal does not reconcile",
+			corrupt: func(a *AmortizationTable) { a.Principal[3] += 50 },
+			wantErr: "period 4: ending balance",
+		},
+		{
+			name:    "negative balance",
+			corrupt: func(a *AmortizationTable) { a.EndBal[11] = -1 },
+			wantErr: "period 12: negative ending balance",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loan := &LoanInfo{ID: "CHECK", Wam: 12, Wac: 5.0, Face: 10000.0}
+			table := loan.GetAmortizationTable()
+			tc.corrupt(&table)
+
+			err := table.Check()
+			if err == nil {
+				t.Fatal("Expected Check to report an inconsistency, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %q", tc.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestGetAmortizationTable_ZeroCoupon(t *testing.T) {
+	testCases := []struct {
+		face float64
+		wam  int64
+	}{
+		{face: 10000.0, wam: 3},
+		{face: 10000.0, wam: 360},
+		{face: 123456.78, wam: 7},
+		{face: 250000.0, wam: 480},
+	}
+
+	for _, tc := range testCases {
+		loan := &LoanInfo{ID: "ZERO", Wam: tc.wam, Wac: 0.0, Face: tc.face}
+		if err := loan.Validate(); err != nil {
+			t.Fatalf("Expected 0%% coupon loan to validate, got %v", err)
+		}
+
+		table := loan.GetAmortizationTable()
+		level := roundToCent(tc.face / float64(tc.wam))
+		last := len(table.Period) - 1
+
+		totalPrincipal := 0.0
+		for i := range table.Period {
+			if table.Interest[i] != 0.0 {
+				t.Errorf("Face %.2f WAM %d period %d: expected zero interest, got %.2f",
+					tc.face, tc.wam, i+1, table.Interest[i])
+			}
+			if i < last && table.Principal[i] != level {
+				t.Errorf("Face %.2f WAM %d period %d: expected principal %.2f, got %.2f",
+					tc.face, tc.wam, i+1, level, table.Principal[i])
+			}
+			totalPrincipal += table.Principal[i]
+		}
+
+		if table.EndBal[last] != 0.0 {
+			t.Errorf("Face %.2f WAM %d: expected final balance exactly 0, got %v", tc.face, tc.wam, table.EndBal[last])
+		}
+		if math.Abs(totalPrincipal-tc.face) > 1e-6 {
+			t.Errorf("Face %.2f WAM %d: expected total principal to equal face, got %.2f", tc.face, tc.wam, totalPrincipal)
+		}
+		if err := table.Check(); err != nil {
+			t.Errorf("Face %.2f WAM %d: %v", tc.face, tc.wam, err)
+		}
+	}
+}
+
+func TestAmortizationTable_MarshalRows(t *testing.T) {
+	loan := &LoanInfo{ID: "ROWS", Wam: 24, Wac: 5.5, Face: 50000.0}
+	loan.PrepayCPR = 0.1
+	table := loan.GetAmortizationTable()
+
+	rowBytes, err := table.MarshalRows()
+	if err != nil {
+		t.Fatalf("MarshalRows failed: %v", err)
+	}
+	var rows []map[string]float64
+	if err := json.Unmarshal(rowBytes, &rows); err != nil {
+		t.Fatalf("row layout is not valid JSON: %v", err)
+	}
+
+	colBytes, err := json.Marshal(table)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var cols map[string]json.RawMessage
+	if err := json.Unmarshal(colBytes, &cols); err != nil {
+		t.Fatalf("column layout is not valid JSON: %v", err)
+	}
+
+	if len(rows) != len(table.Period) {
+		t.Fatalf("Expected %d rows, got %d", len(table.Period), len(rows))
+	}
+
+	columnToRowKey := map[string]string{
+		"period":            "period",
+		"beg_bal":           "beg_bal",
+		"interest":          "interest",
+		"principal":         "principal",
+		"sched_bal":         "sched_bal",
+		"prepay_amount_arr": "prepay_amount",
+		"end_bal":           "end_bal",
+	}
+	for column, rowKey := range columnToRowKey {
+		var values []float64
+		if err := json.Unmarshal(cols[column], &values); err != nil {
+			t.Fatalf("column %s is not a number array: %v", column, err)
+		}
+		for i, v := range values {
+			if rows[i][rowKey] != v {
+				t.Errorf("Period %d %s: column layout %v, row layout %v", i+1, column, v, rows[i][rowKey])
+			}
+		}
+	}
+}
+
+func TestGetAmortizationTable_PrepayPenalty(t *testing.T) {
+	loan := &LoanInfo{ID: "PENALTY", Wam: 60, Wac: 6.0, Face: 100000.0}
+	loan.PrepayCPR = 0.10
+	loan.PrepayPenaltyPct = 0.02
+	loan.PrepayPenaltyMonths = 12
+
+	table := loan.GetAmortizationTable()
+
+	if len(table.PenaltyArr) != 60 {
+		t.Fatalf("Expected 60 penalty entries, got %d", len(table.PenaltyArr))
+	}
+	for i, penalty := range table.PenaltyArr {
+		if i < 12 {
+			expected := roundToCent(0.02 * table.PrepayAmountArr[i])
+			if penalty != expected || penalty <= 0 {
+				t.Errorf("Period %d: expected penalty %.2f inside window, got %.2f", i+1, expected, penalty)
+			}
+		} else if penalty != 0 {
+			t.Errorf("Period %d: expected no penalty after window, got %.2f", i+1, penalty)
+		}
+	}
+
+	// The penalty is extra cashflow and must not change the balance path
+	loan.PrepayPenaltyPct = 0
+	plain := loan.GetAmortizationTable()
+	for i := range table.EndBal {
+		if table.EndBal[i] != plain.EndBal[i] {
+			t.Fatalf("Period %d: penalty changed the ending balance", i+1)
+		}
+	}
+	if plain.PenaltyArr != nil {
+		t.Errorf("Expected no penalty column without a penalty, got %d entries", len(plain.PenaltyArr))
+	}
+}
+
+func TestGetAmortizationTable_Escrow(t *testing.T) {
+	loan := &LoanInfo{ID: "ESCROW", Wam: 360, Wac: 6.5, Face: 320000.0, MonthlyEscrow: 487.5}
+	table := loan.GetAmortizationTable()
+
+	if len(table.EscrowArr) != 360 || len(table.TotalPayment) != 360 {
+		t.Fatalf("Expected 360 escrow and total payment entries, got %d and %d", len(table.EscrowArr), len(table.TotalPayment))
+	}
+	for i, escrow := range table.EscrowArr {
+		if escrow != 487.5 {
+			t.Errorf("Period %d: expected constant escrow 487.50, got %.2f", i+1, escrow)
+		}
+		expected := roundToCent(table.Payment[i] + escrow)
+		if table.TotalPayment[i] != expected {
+			t.Errorf("Period %d: expected total payment %.2f, got %.2f", i+1, expected, table.TotalPayment[i])
+		}
+	}
+
+	// Escrow passes through to the escrow account and must not touch P&I
+	loan.MonthlyEscrow = 0
+	plain := loan.GetAmortizationTable()
+	for i := range table.Period {
+		if table.Interest[i] != plain.Interest[i] || table.Principal[i] != plain.Principal[i] || table.EndBal[i] != plain.EndBal[i] {
+			t.Fatalf("Period %d: escrow changed interest, principal or balance", i+1)
+		}
+	}
+	if plain.EscrowArr != nil || plain.TotalPayment != nil {
+		t.Errorf("Expected no escrow columns without escrow")
+	}
+}
+
+func TestGetAmortizationTable_EscrowStopsAtPayoff(t *testing.T) {
+	smm := make([]float64, 24)
+	smm[5] = 1.0 // Prepaid in full in period 6
+	loan := &LoanInfo{ID: "ESCROW", Wam: 24, Wac: 5.0, Face: 10000.0, MonthlyEscrow: 100.0}
+	loan.PrepayCPR = -1
+	loan.SMMArr = smm
+
+	table := loan.GetAmortizationTable()
+	for i, escrow := range table.EscrowArr {
+		want := 100.0
+		if i >= 6 {
+			want = 0
+		}
+		if escrow != want {
+			t.Errorf("Period %d: expected escrow %.2f, got %.2f", i+1, want, escrow)
+		}
+	}
+}
+
+func TestLoanInfo_WacLooksLikeDecimal(t *testing.T) {
+	testCases := []struct {
+		name string
+		loan LoanInfo
+		want bool
+	}{
+		{name: "decimal coupon on large face", loan: LoanInfo{Wac: 0.045, Face: 250000.0}, want: true},
+		{name: "percentage coupon", loan: LoanInfo{Wac: 4.5, Face: 250000.0}, want: false},
+		{name: "small face", loan: LoanInfo{Wac: 0.5, Face: 5000.0}, want: false},
+		{name: "zero coupon", loan: LoanInfo{Wac: 0.0, Face: 250000.0}, want: false},
+		{name: "explicit decimal", loan: LoanInfo{Wac: 0.045, Face: 250000.0, WacIsDecimal: true}, want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.loan.WacLooksLikeDecimal(); got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetAmortizationTable_WacIsDecimal(t *testing.T) {
+	percent := &LoanInfo{ID: "PCT", Wam: 360, Wac: 4.5, Face: 250000.0}
+	decimal := &LoanInfo{ID: "DEC", Wam: 360, Wac: 0.045, Face: 250000.0, WacIsDecimal: true}
+
+	if err := decimal.Validate(); err != nil {
+		t.Fatalf("Expected decimal coupon to validate, got %v", err)
+	}
+
+	a, b := percent.GetAmortizationTable(), decimal.GetAmortizationTable()
+	for i := range a.Interest {
+		if a.Interest[i] != b.Interest[i] {
+			t.Fatalf("Period %d: expected identical interest, got %.2f and %.2f", i+1, a.Interest[i], b.Interest[i])
+		}
+	}
+}
+
+func TestGetAmortizationTable_OrigFaceAndFactor(t *testing.T) {
+	seasoned := &LoanInfo{ID: "SEASONED", Wam: 300, Wac: 5.0, OrigFace: 400000.0, Factor: 0.5}
+	if err := seasoned.Validate(); err != nil {
+		t.Fatalf("Expected seasoned loan to validate, got %v", err)
+	}
+
+	table := seasoned.GetAmortizationTable()
+	if table.BegBal[0] != 200000.0 {
+		t.Errorf("Expected factor 0.5 to halve the beginning balance to 200000.00, got %.2f", table.BegBal[0])
+	}
+
+	// Factors are reported relative to the original face, not the current one
+	if len(table.FactorArr) != 300 {
+		t.Fatalf("Expected 300 factors, got %d", len(table.FactorArr))
+	}
+	expected := table.EndBal[0] / 400000.0
+	if math.Abs(table.FactorArr[0]-expected) > 1e-12 || table.FactorArr[0] >= 0.5 {
+		t.Errorf("Expected first factor %.6f (below 0.5), got %.6f", expected, table.FactorArr[0])
+	}
+	if summary := table.Summary(); summary.FactorCurve[0] != table.FactorArr[0] {
+		t.Errorf("Expected summary factor curve relative to original face, got %.6f", summary.FactorCurve[0])
+	}
+
+	plain := &LoanInfo{ID: "PLAIN", Wam: 300, Wac: 5.0, Face: 200000.0}
+	plainTable := plain.GetAmortizationTable()
+	for i := range table.EndBal {
+		if table.EndBal[i] != plainTable.EndBal[i] {
+			t.Fatalf("Period %d: expected same balances as a 200000.00 loan", i+1)
+		}
+	}
+}
+
+func TestValidate_Factor(t *testing.T) {
+	testCases := []struct {
+		name    string
+		loan    LoanInfo
+		wantErr bool
+	}{
+		{name: "valid factor", loan: LoanInfo{ID: "F", Wam: 360, Wac: 4.0, OrigFace: 100000.0, Factor: 0.75}},
+		{name: "factor of one", loan: LoanInfo{ID: "F", Wam: 360, Wac: 4.0, OrigFace: 100000.0, Factor: 1.0}},
+		{name: "factor above one", loan: LoanInfo{ID: "F", Wam: 360, Wac: 4.0, OrigFace: 100000.0, Factor: 1.2}, wantErr: true},
+		{name: "negative factor", loan: LoanInfo{ID: "F", Wam: 360, Wac: 4.0, OrigFace: 100000.0, Factor: -0.5}, wantErr: true},
+		{name: "factor without original face", loan: LoanInfo{ID: "F", Wam: 360, Wac: 4.0, Factor: 0.5}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.loan.Validate()
+			if tc.wantErr && err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidate_LoanID(t *testing.T) {
+	for _, id := range []string{"/../../../escaped/pwn", "../up", "a/b", `a\b`, "a..b", "line\nbreak", "nul\x00"} {
+		loan := LoanInfo{ID: id, Wam: 360, Wac: 4.0, Face: 100000.0}
+		if err := loan.Validate(); err == nil || !strings.Contains(err.Error(), "path separators") {
+			t.Errorf("ID %q: expected an unsafe ID error, got %v", id, err)
+		}
+	}
+	for _, id := range []string{"LOAN-001", "pool_7.a", "ABC 123"} {
+		loan := LoanInfo{ID: id, Wam: 360, Wac: 4.0, Face: 100000.0}
+		if err := loan.Validate(); err != nil {
+			t.Errorf("ID %q: expected no error, got %v", id, err)
+		}
+	}
+}
+
+func TestValidate_SubCentFace(t *testing.T) {
+	testCases := []struct {
+		name    string
+		loan    LoanInfo
+		wantErr bool
+	}{
+		{name: "one cent", loan: LoanInfo{ID: "C", Wam: 360, Wac: 4.0, Face: 0.01}},
+		{name: "below one cent", loan: LoanInfo{ID: "C", Wam: 360, Wac: 4.0, Face: 0.004}, wantErr: true},
+		{name: "seasoned below one cent", loan: LoanInfo{ID: "C", Wam: 360, Wac: 4.0, OrigFace: 1.0, Factor: 0.004}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.loan.Validate()
+			if tc.wantErr && (err == nil || !strings.Contains(err.Error(), "at least one cent")) {
+				t.Errorf("Expected a minimum face error, got %v", err)
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetAmortizationTable_FinalPeriodRetiresBalance(t *testing.T) {
+	testCases := []struct {
+		wac float64
+		wam int64
+		cpr float64
+	}{
+		{wac: 0.0, wam: 7, cpr: 0.0},
+		{wac: 3.25, wam: 12, cpr: 0.0},
+		{wac: 4.5, wam: 180, cpr: 0.05},
+		{wac: 6.875, wam: 360, cpr: 0.10},
+		{wac: 9.99, wam: 37, cpr: 0.30},
+		{wac: 12.0, wam: 480, cpr: 0.02},
+	}
+
+	for _, tc := range testCases {
+		loan := &LoanInfo{
+			ID:   "FINAL001",
+			Wam:  tc.wam,
+			Wac:  tc.wac,
+			Face: 123456.78,
+			PrepayInfo: PrepayInfo{
+				PrepayCPR: tc.cpr,
+			},
+		}
+		table := loan.GetAmortizationTable()
+		last := len(table.Period) - 1
+
+		if table.EndBal[last] != 0.0 {
+			t.Errorf("wac %.3f wam %d cpr %.2f: expected final end balance exactly 0, got %v",
+				tc.wac, tc.wam, tc.cpr, table.EndBal[last])
+		}
+		if paid := roundToCent(table.Principal[last] + table.PrepayAmountArr[last]); paid != table.BegBal[last] {
+			t.Errorf("wac %.3f wam %d cpr %.2f: expected final principal plus prepay %.2f to equal beginning balance %.2f",
+				tc.wac, tc.wam, tc.cpr, paid, table.BegBal[last])
+		}
+		if err := table.Check(); err != nil {
+			t.Errorf("wac %.3f wam %d cpr %.2f: %v", tc.wac, tc.wam, tc.cpr, err)
+		}
+	}
+}
+
+func TestTrim_DropsPeriodsAfterPayoff(t *testing.T) {
+	loan := &LoanInfo{
+		ID:   "TRIM001",
+		Wam:  360,
+		Wac:  6.0,
+		Face: 100000.0,
+		PrepayInfo: PrepayInfo{
+			PrepayCPR:           0.5,
+			PrepayPenaltyPct:    0.01,
+			PrepayPenaltyMonths: 12,
+		},
+	}
+	table := loan.GetAmortizationTable()
+	totalInterest := table.TotalInterest()
+
+	table.Trim()
+
+	n := len(table.Period)
+	if n >= 360 {
+		t.Fatalf("Expected trimmed table shorter than 360 periods, got %d", n)
+	}
+	if table.EndBal[n-1] != 0.0 || table.BegBal[n-1] == 0.0 {
+		t.Errorf("Expected last period to be the payoff period, got beg %.2f end %.2f", table.BegBal[n-1], table.EndBal[n-1])
+	}
+	if len(table.Interest) != n || len(table.PenaltyArr) != n {
+		t.Errorf("Expected all columns trimmed to %d, got interest %d penalty %d", n, len(table.Interest), len(table.PenaltyArr))
+	}
+	if table.TotalInterest() != totalInterest {
+		t.Errorf("Expected total interest unchanged at %.2f, got %.2f", totalInterest, table.TotalInterest())
+	}
+	if err := table.Check(); err != nil {
+		t.Errorf("Trimmed table failed check: %v", err)
+	}
+}
+
+func TestTrim_FullTermUnchanged(t *testing.T) {
+	loan := &LoanInfo{ID: "TRIM002", Wam: 24, Wac: 5.0, Face: 10000.0}
+	table := loan.GetAmortizationTable()
+	table.Trim()
+
+	if len(table.Period) != 24 {
+		t.Errorf("Expected 24 periods, got %d", len(table.Period))
+	}
+}
+
+// referencePayment evaluates the level payment in 256-bit precision
+func referencePayment(principal, annualPct float64, numPayments int) float64 {
+	const prec = 256
+	rate := new(big.Float).SetPrec(prec).Quo(big.NewFloat(annualPct), big.NewFloat(1200))
+	growth := new(big.Float).SetPrec(prec).Add(big.NewFloat(1), rate)
+
+	factor := new(big.Float).SetPrec(prec).SetInt64(1)
+	for i := 0; i < numPayments; i++ {
+		factor.Mul(factor, growth)
+	}
+
+	// P * r * f / (f - 1)
+	num := new(big.Float).SetPrec(prec).Mul(big.NewFloat(principal), rate)
+	num.Mul(num, factor)
+	den := new(big.Float).SetPrec(prec).Sub(factor, big.NewFloat(1))
+	payment, _ := num.Quo(num, den).Float64()
+	return payment
+}
+
+func TestCalculateMonthlyPayment_MatchesHighPrecision(t *testing.T) {
+	testCases := []struct {
+		annualPct float64
+		periods   int
+	}{
+		{annualPct: 30.0, periods: 480},
+		{annualPct: 29.99, periods: 479},
+		{annualPct: 0.001, periods: 480},
+		{annualPct: 4.5, periods: 360},
+		{annualPct: 12.0, periods: 1},
+	}
+
+	for _, tc := range testCases {
+		got := calculateMonthlyPayment(1000000.0, tc.annualPct/1200.0, float64(tc.periods))
+		want := referencePayment(1000000.0, tc.annualPct, tc.periods)
+
+		if relErr := math.Abs(got-want) / want; relErr > 1e-13 {
+			t.Errorf("%.3f%% over %d periods: expected %.10f, got %.10f (relative error %.2e)",
+				tc.annualPct, tc.periods, want, got, relErr)
+		}
+	}
+}
+
+func TestParCheck_AmortizedLoansPriceAtPar(t *testing.T) {
+	testCases := []struct {
+		wac float64
+		wam int64
+		cpr float64
+	}{
+		{wac: 0.0, wam: 60, cpr: 0.0},
+		{wac: 3.5, wam: 180, cpr: 0.0},
+		{wac: 4.5, wam: 360, cpr: 0.08},
+		{wac: 7.25, wam: 360, cpr: 0.3},
+		{wac: 30.0, wam: 480, cpr: 0.0},
+	}
+
+	for _, tc := range testCases {
+		loan := &LoanInfo{
+			ID:   "PAR001",
+			Wam:  tc.wam,
+			Wac:  tc.wac,
+			Face: 250000.0,
+			PrepayInfo: PrepayInfo{
+				PrepayCPR: tc.cpr,
+			},
+		}
+		table := loan.GetAmortizationTable()
+
+		pv, ok := table.ParCheck(tc.wac)
+		if !ok {
+			t.Errorf("wac %.2f wam %d cpr %.2f: expected PV at par 250000.00, got %.4f", tc.wac, tc.wam, tc.cpr, pv)
+		}
+	}
+}
+
+func TestParCheck_DetectsWrongRate(t *testing.T) {
+	loan := &LoanInfo{ID: "PAR002", Wam: 360, Wac: 5.0, Face: 250000.0}
+	table := loan.GetAmortizationTable()
+
+	pv, ok := table.ParCheck(6.0)
+	if ok {
+		t.Errorf("Expected par check to fail when discounting above the coupon, got PV %.2f", pv)
+	}
+	if pv >= 250000.0 {
+		t.Errorf("Expected PV below par when discounting above the coupon, got %.2f", pv)
+	}
+}
+
+func TestProject_SelectsColumns(t *testing.T) {
+	loan := &LoanInfo{ID: "PROJ001", Wam: 12, Wac: 5.0, Face: 10000.0}
+	table := loan.GetAmortizationTable()
+
+	projected, err := table.Project([]string{"period", "principal", "interest", "penalty_arr"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(projected) != 4 {
+		t.Fatalf("Expected 4 columns, got %d", len(projected))
+	}
+
+	var principal []float64
+	if err := json.Unmarshal(projected["principal"], &principal); err != nil {
+		t.Fatalf("principal column is not valid JSON: %v", err)
+	}
+	if len(principal) != 12 || principal[0] != table.Principal[0] {
+		t.Errorf("Expected projected principal to match table, got %v", principal)
+	}
+	// Empty optional columns are reported as null rather than dropped
+	if string(projected["penalty_arr"]) != "null" {
+		t.Errorf("Expected null penalty_arr, got %s", projected["penalty_arr"])
+	}
+
+	if _, err := table.Project([]string{"principal", "coupon"}); err == nil {
+		t.Error("Expected error for unknown field, got nil")
+	}
+}
+
+func TestTableFields_MatchesJSON(t *testing.T) {
+	loan := &LoanInfo{ID: "PROJ002", Wam: 12, Wac: 5.0, Face: 10000.0, OrigFace: 20000.0, Factor: 0.5}
+	table := loan.GetAmortizationTable()
+
+	encoded, _ := json.Marshal(table)
+	var columns map[string]json.RawMessage
+	json.Unmarshal(encoded, &columns)
+
+	fields := TableFields()
+	for name := range columns {
+		if !strings.Contains(","+strings.Join(fields, ",")+",", ","+name+",") {
+			t.Errorf("JSON column %q missing from TableFields %v", name, fields)
+		}
+	}
+}
+
+func TestGetAmortizationTable_PaymentConstantWithoutPrepay(t *testing.T) {
+	loan := &LoanInfo{ID: "PMT002", Wam: 360, Wac: 6.5, Face: 275000.0}
+	table := loan.GetAmortizationTable()
+
+	if len(table.Payment) != 360 {
+		t.Fatalf("Expected 360 payments, got %d", len(table.Payment))
+	}
+	level := table.Payment[0]
+	for i := 0; i < 359; i++ {
+		if math.Abs(table.Payment[i]-level) > 0.01 {
+			t.Fatalf("Period %d: expected level payment %.2f, got %.2f", i+1, level, table.Payment[i])
+		}
+		if table.Payment[i] != roundToCent(table.Interest[i]+table.Principal[i]) {
+			t.Fatalf("Period %d: payment %.2f is not interest plus principal", i+1, table.Payment[i])
+		}
+	}
+	// The final payment absorbs the rounding residual
+	if math.Abs(table.Payment[359]-level) > 1.0 {
+		t.Errorf("Expected final payment near %.2f, got %.2f", level, table.Payment[359])
+	}
+}
+
+func TestGetAmortizationTable_PaymentExcludesPrepay(t *testing.T) {
+	loan := &LoanInfo{ID: "PMT003", Wam: 120, Wac: 5.0, Face: 100000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.2}}
+	table := loan.GetAmortizationTable()
+
+	if table.Payment[0] != roundToCent(table.Interest[0]+table.Principal[0]) {
+		t.Errorf("Expected payment %.2f to exclude prepayment %.2f", table.Payment[0], table.PrepayAmountArr[0])
+	}
+}
+
+func TestGetAmortizationTable_EffectiveMaturity(t *testing.T) {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(original) })
+
+	fast := &LoanInfo{ID: "FAST", Wam: 360, Wac: 6.0, Face: 200000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.60}}
+	table := fast.GetAmortizationTable()
+
+	if table.EffectiveMaturity <= 0 || table.EffectiveMaturity >= int(fast.Wam) {
+		t.Fatalf("Expected an effective maturity inside the 360-month term, got %d", table.EffectiveMaturity)
+	}
+	if table.EndBal[table.EffectiveMaturity-1] != 0.0 || table.EndBal[table.EffectiveMaturity-2] == 0.0 {
+		t.Errorf("Expected period %d to be the first with a zero balance", table.EffectiveMaturity)
+	}
+	// The early payoff is left on the table for the caller to log
+	if buf.Len() != 0 {
+		t.Errorf("Expected the engine not to log, got %s", buf.String())
+	}
+
+	level := &LoanInfo{ID: "LEVEL", Wam: 360, Wac: 6.0, Face: 200000.0}
+	if got := level.GetAmortizationTable().EffectiveMaturity; got != 0 {
+		t.Errorf("Expected 0 for a loan that runs its full term, got %d", got)
+	}
+}
+
+func TestGetAmortizationTable_StubFirstPeriod(t *testing.T) {
+	origination := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	firstPayment := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		dayCount string
+		want     float64 // First-period interest on 100000 at 6%
+	}{
+		{name: "ACT/360", dayCount: "ACT/360", want: 283.33}, // 17 actual days
+		{name: "default 30/360", dayCount: "", want: 266.67}, // 16 days under 30/360
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loan := &LoanInfo{
+				ID: "STUB", Wam: 360, Wac: 6.0, Face: 100000.0,
+				DayCount: tc.dayCount, OriginationDate: origination, FirstPaymentDate: firstPayment,
+			}
+			if err := loan.Validate(); err != nil {
+				t.Fatalf("Expected stub loan to validate, got %v", err)
+			}
+
+			table := loan.GetAmortizationTable()
+			if table.Interest[0] != tc.want {
+				t.Errorf("Expected stub interest %.2f, got %.2f", tc.want, table.Interest[0])
+			}
+			if table.Interest[0] >= 500.0 {
+				t.Errorf("Expected less than a full month's interest, got %.2f", table.Interest[0])
+			}
+
+			// The second period is a regular month starting at the first payment
+			if tc.dayCount == "" {
+				if want := roundToCent(table.BegBal[1] * 0.005); table.Interest[1] != want {
+					t.Errorf("Expected a regular second period of %.2f, got %.2f", want, table.Interest[1])
+				}
+			}
+
+			last := len(table.EndBal) - 1
+			if table.EndBal[last] != 0.0 {
+				t.Errorf("Expected the loan to amortize fully, got %.2f", table.EndBal[last])
+			}
+		})
+	}
+}
+
+func TestValidate_FirstPaymentDate(t *testing.T) {
+	origination := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name         string
+		origination  time.Time
+		firstPayment time.Time
+		wantErr      string
+	}{
+		{name: "no origination", firstPayment: origination, wantErr: "origination date is required"},
+		{name: "before origination", origination: origination, firstPayment: origination.AddDate(0, 0, -1), wantErr: "must be after origination"},
+		{name: "on origination", origination: origination, firstPayment: origination, wantErr: "must be after origination"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loan := &LoanInfo{
+				ID: "STUB", Wam: 360, Wac: 6.0, Face: 100000.0,
+				OriginationDate: tc.origination, FirstPaymentDate: tc.firstPayment,
+			}
+			err := loan.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGetAmortizationTable_Diagnostics(t *testing.T) {
+	t.Run("high CPR", func(t *testing.T) {
+		loan := &LoanInfo{ID: "FAST", Wam: 360, Wac: 6.0, Face: 100000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.50}}
+		table := loan.GetAmortizationTable()
+		diag := table.Diagnostics
+
+		if diag.MonthlyRate != 0.005 {
+			t.Errorf("Expected monthly rate 0.005, got %v", diag.MonthlyRate)
+		}
+		if want := roundToCent(calculateMonthlyPayment(100000.0, 0.005, 360)); diag.MonthlyPayment != want {
+			t.Errorf("Expected monthly payment %.2f, got %.2f", want, diag.MonthlyPayment)
+		}
+		if diag.PrepayModel != "flat_cpr" {
+			t.Errorf("Expected the flat CPR model, got %q", diag.PrepayModel)
+		}
+		if diag.NegAmClamped {
+			t.Errorf("Expected no negative amortization clamping")
+		}
+		if table.EffectiveMaturity == 0 || table.EffectiveMaturity > 120 {
+			t.Errorf("Expected a 50%% CPR to retire the loan within 10 years, got %d", table.EffectiveMaturity)
+		}
+	})
+
+	t.Run("true-up", func(t *testing.T) {
+		// 123456.78 / 7 leaves a residual for the payoff period to absorb
+		loan := &LoanInfo{ID: "ZERO", Wam: 7, Wac: 0.0, Face: 123456.78}
+		if diag := loan.GetAmortizationTable().Diagnostics; !diag.TrueUpAdjusted {
+			t.Errorf("Expected the true-up to adjust principal, got %+v", diag)
+		}
+
+		level := &LoanInfo{ID: "EVEN", Wam: 4, Wac: 0.0, Face: 1000.0}
+		if diag := level.GetAmortizationTable().Diagnostics; diag.TrueUpAdjusted {
+			t.Errorf("Expected no true-up for an evenly divisible balance, got %+v", diag)
+		}
+	})
+
+	t.Run("negative amortization", func(t *testing.T) {
+		// A three-month stub accrues far more interest than one level payment
+		loan := &LoanInfo{
+			ID: "STUB", Wam: 360, Wac: 6.0, Face: 100000.0,
+			OriginationDate:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			FirstPaymentDate: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		}
+		table := loan.GetAmortizationTable()
+		if !table.Diagnostics.NegAmClamped {
+			t.Errorf("Expected negative amortization to be clamped")
+		}
+		if table.Principal[0] != 0 || table.EndBal[0] != 100000.0 {
+			t.Errorf("Expected no principal and an unchanged balance, got %.2f and %.2f", table.Principal[0], table.EndBal[0])
+		}
+	})
+
+	t.Run("model names", func(t *testing.T) {
+		testCases := []struct {
+			model PrepayModel
+			want  string
+		}{
+			{model: PSA{Speed: 150}, want: "psa"},
+			{model: VectorCPR{CPR: []float64{0.1}}, want: "cpr_vector"},
+			{model: smmVector{0.01}, want: "smm_vector"},
+		}
+		for _, tc := range testCases {
+			loan := &LoanInfo{ID: "MODEL", Wam: 12, Wac: 5.0, Face: 1000.0, PrepayInfo: PrepayInfo{PrepayModel: tc.model}}
+			if got := loan.GetAmortizationTable().Diagnostics.PrepayModel; got != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		}
+	})
+}
+
+func TestScaleTo(t *testing.T) {
+	base := LoanInfo{ID: "SCALE001", Wam: 360, Wac: 5.25, Face: 250000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.06}}
+	scaled := base.ScaleTo(1000000.0)
+
+	if scaled.Face != 1000000.0 || scaled.Wam != base.Wam || scaled.Wac != base.Wac || scaled.PrepayCPR != base.PrepayCPR {
+		t.Fatalf("Expected only the face to change, got %+v", scaled)
+	}
+	if base.Face != 250000.0 {
+		t.Errorf("Expected the original loan to be unchanged, got face %f", base.Face)
+	}
+
+	baseTable := base.GetAmortizationTable()
+	table := scaled.GetAmortizationTable()
+	retired := table.CumulativePrincipal()
+	if got := retired[len(retired)-1]; math.Abs(got-1000000.0) > 0.01 {
+		t.Errorf("Expected total principal 1000000.00, got %.2f", got)
+	}
+
+	// Four times the face gives four times every cashflow, to within the
+	// cent rounding of each period
+	for i := 0; i < 120; i++ {
+		if math.Abs(table.Interest[i]-4*baseTable.Interest[i]) > 0.04 {
+			t.Fatalf("Period %d: expected interest %.2f, got %.2f", i+1, 4*baseTable.Interest[i], table.Interest[i])
+		}
+	}
+
+	seasoned := LoanInfo{ID: "SCALE002", Wam: 300, Wac: 4.0, OrigFace: 200000.0, Factor: 0.8}
+	if got := seasoned.ScaleTo(80000.0); got.CurrentFace() != 80000.0 || got.Factor != 0.8 {
+		t.Errorf("Expected a current face of 80000 at factor 0.8, got %f at %f", got.CurrentFace(), got.Factor)
+	}
+}
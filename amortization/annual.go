@@ -0,0 +1,45 @@
+package amortization
+
+// AnnualTable rolls an amortization table up into yearly buckets
+type AnnualTable struct {
+	Year         []int     `json:"year"`          // Calendar year, or year number from origination (1, 2, 3, ...) when undated
+	Interest     []float64 `json:"interest"`      // Interest paid during the year
+	Principal    []float64 `json:"principal"`     // Scheduled principal paid during the year
+	PrepayAmount []float64 `json:"prepay_amount"` // Prepayments during the year
+	EndBal       []float64 `json:"end_bal"`       // Balance at the end of the year
+}
+
+// AnnualView groups the periods of the table into years, summing interest,
+// principal, and prepayment per year and reporting the balance at the end of
+// each year. A dated table is grouped by the calendar year of each payment
+// date, so the first and last years may be partial. An undated table is
+// grouped into buckets of PeriodsPerYear periods counted from origination,
+// with a trailing partial year kept as its own bucket.
+func (a *AmortizationTable) AnnualView() AnnualTable {
+	var annual AnnualTable
+
+	dated := len(a.PaymentDates) == len(a.Period)
+	perYear := int(a.periodsPerYear())
+	for i := range a.Period {
+		year := (a.Period[i]-1)/perYear + 1
+		if dated {
+			year = a.PaymentDates[i].Year()
+		}
+		last := len(annual.Year) - 1
+		if last < 0 || annual.Year[last] != year {
+			annual.Year = append(annual.Year, year)
+			annual.Interest = append(annual.Interest, 0)
+			annual.Principal = append(annual.Principal, 0)
+			annual.PrepayAmount = append(annual.PrepayAmount, 0)
+			annual.EndBal = append(annual.EndBal, 0)
+			last++
+		}
+
+		annual.Interest[last] = roundToCent(annual.Interest[last] + a.Interest[i])
+		annual.Principal[last] = roundToCent(annual.Principal[last] + a.Principal[i])
+		annual.PrepayAmount[last] = roundToCent(annual.PrepayAmount[last] + a.PrepayAmountArr[i])
+		annual.EndBal[last] = a.EndBal[i]
+	}
+
+	return annual
+}
@@ -0,0 +1,111 @@
+package amortization
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAnnualView_SumsMatchMonthly(t *testing.T) {
+	loan := &LoanInfo{
+		ID:   "ANNUAL001",
+		Wam:  360,
+		Wac:  6.0,
+		Face: 300000.0,
+		PrepayInfo: PrepayInfo{
+			PrepayCPR: 0.08,
+		},
+	}
+	table := loan.GetAmortizationTable()
+	annual := table.AnnualView()
+
+	if len(annual.Year) != 30 {
+		t.Fatalf("Expected 30 years, got %d", len(annual.Year))
+	}
+
+	annualInterest := 0.0
+	annualPrincipal := 0.0
+	for i := range annual.Year {
+		annualInterest += annual.Interest[i]
+		annualPrincipal += annual.Principal[i] + annual.PrepayAmount[i]
+	}
+
+	if math.Abs(roundToCent(annualInterest)-table.TotalInterest()) > 0.001 {
+		t.Errorf("Expected annual interest %.2f to equal monthly total %.2f", annualInterest, table.TotalInterest())
+	}
+	if math.Abs(roundToCent(annualPrincipal)-table.Summary().TotalPrincipal) > 0.001 {
+		t.Errorf("Expected annual principal %.2f to equal monthly total %.2f", annualPrincipal, table.Summary().TotalPrincipal)
+	}
+
+	// Year-end balance is the ending balance of the 12th period of the year
+	if annual.EndBal[0] != table.EndBal[11] {
+		t.Errorf("Expected year 1 end balance %.2f, got %.2f", table.EndBal[11], annual.EndBal[0])
+	}
+	if annual.EndBal[29] != 0 {
+		t.Errorf("Expected final year end balance 0, got %.2f", annual.EndBal[29])
+	}
+}
+
+func TestAnnualView_PartialFinalYear(t *testing.T) {
+	loan := &LoanInfo{
+		ID:   "ANNUAL002",
+		Wam:  15,
+		Wac:  5.0,
+		Face: 15000.0,
+	}
+	table := loan.GetAmortizationTable()
+	annual := table.AnnualView()
+
+	if len(annual.Year) != 2 {
+		t.Fatalf("Expected 2 years for a 15-month loan, got %d", len(annual.Year))
+	}
+
+	lastYearInterest := 0.0
+	for i := 12; i < 15; i++ {
+		lastYearInterest += table.Interest[i]
+	}
+	if math.Abs(annual.Interest[1]-roundToCent(lastYearInterest)) > 0.001 {
+		t.Errorf("Expected partial year interest %.2f, got %.2f", lastYearInterest, annual.Interest[1])
+	}
+}
+
+func TestAnnualView_EmptyTable(t *testing.T) {
+	table := AmortizationTable{}
+	if annual := table.AnnualView(); len(annual.Year) != 0 {
+		t.Errorf("Expected no years for an empty table, got %d", len(annual.Year))
+	}
+}
+
+func TestAnnualView_CalendarYearsWhenDated(t *testing.T) {
+	loan := &LoanInfo{
+		ID:              "ANNUAL003",
+		Wam:             24,
+		Wac:             5.0,
+		Face:            24000.0,
+		OriginationDate: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+	}
+	table := loan.GetAmortizationTable()
+	annual := table.AnnualView()
+
+	if want := []int{2024, 2025, 2026}; !slices.Equal(annual.Year, want) {
+		t.Fatalf("Expected calendar years %v, got %v", want, annual.Year)
+	}
+
+	// Each bucket holds exactly the periods paying in its year
+	for y, year := range annual.Year {
+		interest, last := 0.0, -1
+		for i, date := range table.PaymentDates {
+			if date.Year() == year {
+				interest += table.Interest[i]
+				last = i
+			}
+		}
+		if math.Abs(annual.Interest[y]-roundToCent(interest)) > 0.001 {
+			t.Errorf("%d: expected interest %.2f, got %.2f", year, interest, annual.Interest[y])
+		}
+		if annual.EndBal[y] != table.EndBal[last] {
+			t.Errorf("%d: expected year-end balance %.2f, got %.2f", year, table.EndBal[last], annual.EndBal[y])
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+)
+
+// RateReset changes an adjustable-rate loan's coupon from Period onward
+type RateReset struct {
+	Period int     `json:"period"` // First period (1-based) accruing at the new coupon
+	Rate   float64 `json:"rate"`   // Fully indexed coupon before caps, in percentage points
+}
+
+// ARMInfo describes an adjustable-rate loan: its reset schedule and the caps
+// bounding each reset. Caps are in percentage points; zero means uncapped.
+type ARMInfo struct {
+	RateResets []RateReset `json:"rate_resets,omitempty"`
+	// PeriodicCap bounds how far a reset may move the coupon, up or down,
+	// from the coupon before it
+	PeriodicCap float64 `json:"periodic_cap,omitempty"`
+	// LifetimeCap and LifetimeFloor bound how far the coupon may rise above
+	// or fall below the initial coupon over the life of the loan
+	LifetimeCap   float64 `json:"lifetime_cap,omitempty"`
+	LifetimeFloor float64 `json:"lifetime_floor,omitempty"`
+}
+
+// clampReset returns the coupon applied when a reset to target follows prior
+func (a ARMInfo) clampReset(initial, prior, target float64) float64 {
+	coupon := target
+	if a.PeriodicCap > 0 {
+		coupon = math.Min(math.Max(coupon, prior-a.PeriodicCap), prior+a.PeriodicCap)
+	}
+	if a.LifetimeCap > 0 {
+		coupon = math.Min(coupon, initial+a.LifetimeCap)
+	}
+	if a.LifetimeFloor > 0 {
+		coupon = math.Max(coupon, initial-a.LifetimeFloor)
+	}
+	return math.Max(coupon, 0)
+}
+
+// couponSchedule returns the coupon applied in each period, in percentage
+// points, after capping every reset. Fixed-rate loans return nil.
+func (l *LoanInfo) couponSchedule() []float64 {
+	if len(l.RateResets) == 0 {
+		return nil
+	}
+
+	initial := l.CouponPct()
+	coupons := make([]float64, l.numPeriods())
+	coupon, next := initial, 0
+	for j := range coupons {
+		if next < len(l.RateResets) && l.RateResets[next].Period == j+1 {
+			coupon = l.clampReset(initial, coupon, l.RateResets[next].Rate)
+			next++
+		}
+		coupons[j] = coupon
+	}
+	return coupons
+}
+
+// resetsAt reports whether the coupon changes at period j (0-based)
+func resetsAt(coupons []float64, j int) bool {
+	return j > 0 && coupons != nil && coupons[j] != coupons[j-1]
+}
+
+// validateARM checks the reset schedule and caps
+func (l *LoanInfo) validateARM() error {
+	if l.PeriodicCap < 0 || l.LifetimeCap < 0 || l.LifetimeFloor < 0 {
+		return fmt.Errorf("rate caps and floors cannot be negative")
+	}
+	prior := 1
+	for _, reset := range l.RateResets {
+		if reset.Period <= prior || int64(reset.Period) > l.Wam {
+			return fmt.Errorf("rate reset periods must increase within 2 to %d, got %d", l.Wam, reset.Period)
+		}
+		if reset.Rate < 0 || reset.Rate > 30 {
+			return fmt.Errorf("reset rate must be between 0 and 30 percent, got %f", reset.Rate)
+		}
+		prior = reset.Period
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package amortization
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func armLoan(arm ARMInfo) *LoanInfo {
+	return &LoanInfo{ID: "ARM", Wam: 360, Wac: 6.0, Face: 200000.0, ARMInfo: arm}
+}
+
+func TestGetAmortizationTable_PeriodicCapLimitsReset(t *testing.T) {
+	// Uncapped, the reset would move the coupon from 6% to 9%
+	loan := armLoan(ARMInfo{
+		RateResets:  []RateReset{{Period: 13, Rate: 9.0}},
+		PeriodicCap: 2.0,
+	})
+	if err := loan.Validate(); err != nil {
+		t.Fatalf("Expected ARM loan to validate, got %v", err)
+	}
+	table := loan.GetAmortizationTable()
+
+	if want := roundToCent(table.BegBal[11] * 0.06 / 12); table.Interest[11] != want {
+		t.Errorf("Expected the initial coupon before the reset, interest %.2f, got %.2f", want, table.Interest[11])
+	}
+	if want := roundToCent(table.BegBal[12] * 0.08 / 12); table.Interest[12] != want {
+		t.Errorf("Expected the reset capped at 8%%, interest %.2f, got %.2f", want, table.Interest[12])
+	}
+
+	// The capped coupon re-amortizes the balance over the remaining 348 months
+	want := calculateMonthlyPayment(table.BegBal[12], 0.08/12, 348)
+	if math.Abs(table.Payment[12]-want) > 0.01 {
+		t.Errorf("Expected a re-amortized payment of %.2f, got %.2f", want, table.Payment[12])
+	}
+	if table.Payment[12] <= table.Payment[11] {
+		t.Errorf("Expected the payment to rise with the coupon, got %.2f after %.2f", table.Payment[12], table.Payment[11])
+	}
+	if last := len(table.EndBal) - 1; table.EndBal[last] != 0.0 {
+		t.Errorf("Expected the loan to amortize fully, got %.2f", table.EndBal[last])
+	}
+}
+
+func TestCouponSchedule_Caps(t *testing.T) {
+	testCases := []struct {
+		name string
+		arm  ARMInfo
+		want map[int]float64 // Coupon by 1-based period
+	}{
+		{
+			name: "uncapped",
+			arm:  ARMInfo{RateResets: []RateReset{{Period: 13, Rate: 9.0}}},
+			want: map[int]float64{1: 6.0, 12: 6.0, 13: 9.0, 360: 9.0},
+		},
+		{
+			name: "periodic then lifetime cap",
+			arm: ARMInfo{
+				RateResets:  []RateReset{{Period: 13, Rate: 9.0}, {Period: 25, Rate: 12.0}},
+				PeriodicCap: 2.0, LifetimeCap: 3.0,
+			},
+			want: map[int]float64{13: 8.0, 24: 8.0, 25: 9.0},
+		},
+		{
+			name: "periodic cap applies downward",
+			arm:  ARMInfo{RateResets: []RateReset{{Period: 13, Rate: 2.0}}, PeriodicCap: 1.0},
+			want: map[int]float64{13: 5.0},
+		},
+		{
+			name: "lifetime floor",
+			arm:  ARMInfo{RateResets: []RateReset{{Period: 13, Rate: 1.0}}, LifetimeFloor: 2.0},
+			want: map[int]float64{13: 4.0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			coupons := armLoan(tc.arm).couponSchedule()
+			for period, want := range tc.want {
+				if got := coupons[period-1]; math.Abs(got-want) > 1e-12 {
+					t.Errorf("Period %d: expected coupon %.2f, got %.2f", period, want, got)
+				}
+			}
+		})
+	}
+
+	if coupons := armLoan(ARMInfo{}).couponSchedule(); coupons != nil {
+		t.Errorf("Expected no schedule for a fixed-rate loan, got %d coupons", len(coupons))
+	}
+}
+
+func TestValidate_ARM(t *testing.T) {
+	testCases := []struct {
+		name    string
+		arm     ARMInfo
+		wantErr string
+	}{
+		{name: "negative cap", arm: ARMInfo{PeriodicCap: -1}, wantErr: "cannot be negative"},
+		{name: "reset in first period", arm: ARMInfo{RateResets: []RateReset{{Period: 1, Rate: 5}}}, wantErr: "must increase"},
+		{name: "out of order", arm: ARMInfo{RateResets: []RateReset{{Period: 25, Rate: 5}, {Period: 13, Rate: 5}}}, wantErr: "must increase"},
+		{name: "past maturity", arm: ARMInfo{RateResets: []RateReset{{Period: 361, Rate: 5}}}, wantErr: "must increase"},
+		{name: "rate out of range", arm: ARMInfo{RateResets: []RateReset{{Period: 13, Rate: 31}}}, wantErr: "reset rate"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := armLoan(tc.arm).Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
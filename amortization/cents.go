@@ -0,0 +1,61 @@
+package amortization
+
+// toCents converts a dollar amount to whole cents under the rounding mode
+func toCents(dollars float64) int64 {
+	return int64(round(dollars * 100))
+}
+
+// fromCents converts whole cents back to a dollar amount for output
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// stepCents runs one period of the integer-cents engine. The balance is
+// carried as int64 cents and each period's interest, principal and
+// prepayment are rounded to whole cents before they are applied, so the
+// columns reconcile exactly and the final balance is exactly zero without a
+// true-up pass.
+func (a *amortizer) stepCents() PeriodRow {
+	j := a.j
+	row := PeriodRow{BegBal: fromCents(a.cents)}
+
+	if resetsAt(a.coupons, j) {
+		rate := a.l.monthlyRate(a.coupons[j])
+		a.centsPayment = toCents(calculateMonthlyPayment(fromCents(a.cents), rate, float64(a.numPeriods-j)))
+	}
+
+	interestCents := int64(round(float64(a.cents) * a.periodRate(j)))
+	row.Interest = fromCents(interestCents)
+
+	// The final period retires whatever remains; earlier periods pay the
+	// level payment net of interest, never more than is owed
+	principalCents := a.centsPayment - interestCents
+	if j == a.numPeriods-1 || principalCents > a.cents {
+		principalCents = a.cents
+	}
+	if principalCents < 0 {
+		principalCents = 0
+		a.negAmClamped = true
+	}
+	scheduled := a.cents - principalCents
+	// Defaults come off the scheduled balance ahead of prepayment
+	defaultCents := min(toCents(a.mdr*fromCents(scheduled)), scheduled)
+	performing := scheduled - defaultCents
+	smm := a.prepaySMM(fromCents(performing))
+	prepayCents := min(toCents(smm*fromCents(performing)), performing)
+
+	// A residual under the payoff threshold is retired with the principal
+	if residual := performing - prepayCents; residual > 0 && fromCents(residual) < a.threshold {
+		principalCents += residual
+		scheduled -= residual
+	}
+
+	row.Principal = fromCents(principalCents)
+	row.SchedBal = fromCents(scheduled)
+	row.Default = fromCents(defaultCents)
+	row.Loss = fromCents(toCents(a.l.severityAt(j+1) * row.Default))
+	row.PrepayAmount = fromCents(prepayCents)
+	a.cents = scheduled - defaultCents - prepayCents
+	row.EndBal = fromCents(a.cents)
+	return row
+}
@@ -0,0 +1,65 @@
+package amortization
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGetAmortizationTable_IntegerCents(t *testing.T) {
+	testCases := []struct {
+		name string
+		loan LoanInfo
+	}{
+		// 123456.78 / 7 does not divide into whole cents, so the float path
+		// leaves a residual that TrueUpBalances has to fold into the payoff
+		{name: "zero coupon", loan: LoanInfo{ID: "ZERO", Wam: 7, Wac: 0.0, Face: 123456.78}},
+		{name: "prepaying", loan: LoanInfo{ID: "CPR", Wam: 360, Wac: 6.875, Face: 333333.33, PrepayInfo: PrepayInfo{PrepayCPR: 0.08}}},
+		{name: "adjustable", loan: LoanInfo{ID: "ARM", Wam: 360, Wac: 5.5, Face: 275000.0, ARMInfo: ARMInfo{RateResets: []RateReset{{Period: 61, Rate: 8.25}}, PeriodicCap: 2.0}}},
+		{name: "dated", loan: LoanInfo{ID: "ACT", Wam: 120, Wac: 5.25, Face: 98765.43, DayCount: "ACT/365", OriginationDate: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			floatLoan := tc.loan
+			centsLoan := tc.loan
+			centsLoan.IntegerCents = true
+
+			floatTable := floatLoan.GetAmortizationTable()
+			centsTable := centsLoan.GetAmortizationTable()
+
+			last := len(centsTable.Period) - 1
+			if centsTable.EndBal[last] != 0.0 {
+				t.Fatalf("Expected final balance exactly 0, got %v", centsTable.EndBal[last])
+			}
+
+			retired := int64(0)
+			for i := range centsTable.Period {
+				beg := toCents(centsTable.BegBal[i])
+				end := toCents(centsTable.EndBal[i])
+				paid := toCents(centsTable.Principal[i]) + toCents(centsTable.PrepayAmountArr[i])
+				if beg-paid != end {
+					t.Errorf("Period %d: %d - %d != %d cents", i+1, beg, paid, end)
+				}
+				if i > 0 && beg != toCents(centsTable.EndBal[i-1]) {
+					t.Errorf("Period %d: beginning balance does not roll from the prior period", i+1)
+				}
+				retired += paid
+			}
+			if retired != toCents(tc.loan.Face) {
+				t.Errorf("Expected %d cents retired, got %d", toCents(tc.loan.Face), retired)
+			}
+			if err := centsTable.Check(); err != nil {
+				t.Error(err)
+			}
+
+			// Both paths round each period to the cent, so their totals agree
+			// to within a cent per period
+			tolerance := 0.01 * float64(len(centsTable.Period))
+			floatSummary, centsSummary := floatTable.Summary(), centsTable.Summary()
+			if diff := math.Abs(floatSummary.TotalInterest - centsSummary.TotalInterest); diff > tolerance {
+				t.Errorf("Total interest differs from the float path by %.2f", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package amortization
+
+import "math"
+
+// CompoundingConvention selects how the annual coupon is turned into the
+// rate each period accrues
+type CompoundingConvention string
+
+const (
+	// CompoundingSimple accrues the coupon pro rata: Wac/12/100 a month.
+	// This is the default.
+	CompoundingSimple CompoundingConvention = "simple"
+	// CompoundingAnnual treats the coupon as an annually compounded rate, so
+	// twelve monthly periods compound to it: (1+Wac/100)^(1/12)-1
+	CompoundingAnnual CompoundingConvention = "annual"
+)
+
+// valid reports whether c is a known convention; empty means simple
+func (c CompoundingConvention) valid() bool {
+	return c == "" || c == CompoundingSimple || c == CompoundingAnnual
+}
+
+// accrualRate returns the rate accrued at an annual coupon of couponPct over
+// a period spanning yearFraction of a year, under the loan's compounding
+// convention
+func (l *LoanInfo) accrualRate(couponPct, yearFraction float64) float64 {
+	annual := couponPct / 100.0
+	if l.Compounding == CompoundingAnnual {
+		return math.Expm1(yearFraction * math.Log1p(annual))
+	}
+	return annual * yearFraction
+}
+
+// monthlyRate returns the rate accrued over one monthly period at an annual
+// coupon of couponPct. The simple convention divides by 12 directly rather
+// than multiplying by 1/12, which would not round-trip (6% gives 0.005).
+func (l *LoanInfo) monthlyRate(couponPct float64) float64 {
+	if l.Compounding == CompoundingAnnual {
+		return l.accrualRate(couponPct, 1.0/12.0)
+	}
+	return couponPct / 12.0 / 100.0
+}
+
+// periodicRate returns the rate accrued over one regular period at the
+// loan's initial coupon
+func (l *LoanInfo) periodicRate() float64 {
+	return l.regularRate(l.CouponPct())
+}
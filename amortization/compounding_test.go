@@ -0,0 +1,53 @@
+package amortization
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCompoundingConvention_FirstPeriodInterest(t *testing.T) {
+	testCases := []struct {
+		name        string
+		compounding CompoundingConvention
+		want        float64
+	}{
+		{name: "default", compounding: "", want: 500.00},
+		{name: "simple", compounding: CompoundingSimple, want: 500.00},
+		// 100000 * (1.06^(1/12) - 1) = 486.755...
+		{name: "annual", compounding: CompoundingAnnual, want: 486.76},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loan := &LoanInfo{ID: "COMP", Wam: 360, Wac: 6.0, Face: 100000.0, Compounding: tc.compounding}
+			if err := loan.Validate(); err != nil {
+				t.Fatalf("Expected loan to validate, got %v", err)
+			}
+
+			table := loan.GetAmortizationTable()
+			if table.Interest[0] != tc.want {
+				t.Errorf("Expected first-period interest %.2f, got %.2f", tc.want, table.Interest[0])
+			}
+			last := len(table.EndBal) - 1
+			if table.EndBal[last] != 0.0 {
+				t.Errorf("Expected the loan to amortize fully, got %.2f", table.EndBal[last])
+			}
+		})
+	}
+}
+
+func TestCompoundingConvention_AnnualCompoundsToCoupon(t *testing.T) {
+	loan := &LoanInfo{Wac: 6.0, Compounding: CompoundingAnnual}
+	if got := math.Pow(1+loan.periodicRate(), 12) - 1; math.Abs(got-0.06) > 1e-12 {
+		t.Errorf("Expected twelve periods to compound to 6%%, got %.12f", got)
+	}
+}
+
+func TestValidate_UnknownCompounding(t *testing.T) {
+	loan := &LoanInfo{ID: "COMP", Wam: 360, Wac: 6.0, Face: 100000.0, Compounding: "continuous"}
+	err := loan.Validate()
+	if err == nil || !strings.Contains(err.Error(), "compounding") {
+		t.Errorf("Expected an unknown compounding error, got %v", err)
+	}
+}
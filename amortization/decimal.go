@@ -0,0 +1,76 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Decimal places written for JSON output. Amounts are already rounded to the
+// cent; ratios keep enough places for a factor or SMM on a large balance.
+const (
+	amountDecimals = 2
+	ratioDecimals  = 10
+)
+
+// Amounts is a column of dollar amounts. It marshals to JSON as plain
+// decimals with at most two places, never in exponent notation.
+type Amounts []float64
+
+// Ratios is a column of fractions such as factors or SMMs. It marshals to
+// JSON as plain decimals with at most ten places, never in exponent notation.
+type Ratios []float64
+
+// MarshalJSON writes each amount to the cent without an exponent
+func (a Amounts) MarshalJSON() ([]byte, error) {
+	return marshalDecimals(a, amountDecimals)
+}
+
+// MarshalJSON writes each ratio to ten places without an exponent
+func (r Ratios) MarshalJSON() ([]byte, error) {
+	return marshalDecimals(r, ratioDecimals)
+}
+
+// marshalDecimals writes values as a JSON array of fixed-point numbers with
+// at most places decimals, trailing zeros trimmed. A nil column is null, as
+// encoding/json writes a nil slice.
+func marshalDecimals(values []float64, places int) ([]byte, error) {
+	if values == nil {
+		return []byte("null"), nil
+	}
+
+	buf := make([]byte, 0, 2+len(values)*(places+8))
+	buf = append(buf, '[')
+	for i, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("unsupported value in column: %v", v)
+		}
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendDecimal(buf, v, places)
+	}
+	return append(buf, ']'), nil
+}
+
+// appendDecimal appends v in fixed-point notation with at most places
+// decimals, dropping trailing zeros and a bare decimal point. A value that
+// rounds to zero is written as 0, never -0.
+func appendDecimal(buf []byte, v float64, places int) []byte {
+	start := len(buf)
+	buf = strconv.AppendFloat(buf, v, 'f', places, 64)
+	if places > 0 {
+		end := len(buf)
+		for buf[end-1] == '0' {
+			end--
+		}
+		if buf[end-1] == '.' {
+			end--
+		}
+		buf = buf[:end]
+	}
+	if string(buf[start:]) == "-0" {
+		buf = append(buf[:start], '0')
+	}
+	return buf
+}
@@ -0,0 +1,79 @@
+package amortization
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAmortizationTable_MarshalsPlainDecimals(t *testing.T) {
+	loan := &LoanInfo{ID: "PLAIN", Wam: 360, Wac: 4.5, Face: 250000.0, OrigFace: 250000.0}
+	loan.PrepayCPR = 1.2e-6 // An SMM of about 1e-7
+	table := loan.GetAmortizationTable()
+
+	encoded, err := json.Marshal(table)
+	if err != nil {
+		t.Fatalf("Failed to marshal table: %v", err)
+	}
+	if exponent := regexp.MustCompile(`[0-9][eE][-+]?[0-9]`).Find(encoded); exponent != nil {
+		t.Errorf("Expected no exponent notation in the table, found %s", exponent)
+	}
+	if !strings.Contains(string(encoded), `"beg_bal":[250000,`) {
+		t.Errorf("Expected the $250,000 face as a plain decimal, got %.80s", encoded)
+	}
+
+	smm, err := json.Marshal(loan.SMMArr[:1])
+	if err != nil {
+		t.Fatalf("Failed to marshal SMMs: %v", err)
+	}
+	if string(smm) != "[0.0000001]" {
+		t.Errorf("Expected the small SMM as a plain decimal, got %s", smm)
+	}
+
+	// The formatted columns decode back into the same values
+	var decoded AmortizationTable
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal table: %v", err)
+	}
+	for i := range table.EndBal {
+		if decoded.EndBal[i] != table.EndBal[i] {
+			t.Fatalf("Period %d: end balance %v decoded as %v", i+1, table.EndBal[i], decoded.EndBal[i])
+		}
+		if math.Abs(decoded.FactorArr[i]-table.FactorArr[i]) > 5e-11 {
+			t.Fatalf("Period %d: factor %v decoded as %v", i+1, table.FactorArr[i], decoded.FactorArr[i])
+		}
+	}
+}
+
+func TestMarshalDecimals(t *testing.T) {
+	testCases := []struct {
+		name   string
+		column json.Marshaler
+		want   string
+	}{
+		{name: "nil column", column: Amounts(nil), want: "null"},
+		{name: "empty column", column: Amounts{}, want: "[]"},
+		{name: "amounts", column: Amounts{250000, 1234.5, 0.01, 0.1 + 0.2}, want: "[250000,1234.5,0.01,0.3]"},
+		{name: "huge amount", column: Amounts{1e21}, want: "[1000000000000000000000]"},
+		{name: "negative zero", column: Amounts{math.Copysign(0, -1), -0.001}, want: "[0,0]"},
+		{name: "ratios", column: Ratios{1, 0.123456789012, 2.5e-9, 1e-12}, want: "[1,0.123456789,0.0000000025,0]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := json.Marshal(tc.column)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("Expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := json.Marshal(Amounts{math.NaN()}); err == nil {
+		t.Error("Expected an error for NaN")
+	}
+}
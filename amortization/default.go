@@ -0,0 +1,87 @@
+package amortization
+
+// DefaultInfo is the loan's default model. Each period a constant share of
+// the scheduled balance, the monthly default rate implied by DefaultCDR,
+// defaults ahead of prepayment; the severity of the period is lost and the
+// rest is recovered as principal. A zero CDR models no defaults.
+type DefaultInfo struct {
+	DefaultCDR  float64 `json:"default_cdr,omitempty"`  // Annual constant default rate in decimals (e.g., 0.02)
+	SeverityPct float64 `json:"severity_pct,omitempty"` // Share of a defaulted balance lost, in decimals (e.g., 0.35)
+	// SeverityArr gives the severity by loan age, one per period from the
+	// first; the last value carries forward past the end of the curve. When
+	// absent every period uses SeverityPct.
+	SeverityArr []float64 `json:"severity_arr,omitempty"`
+}
+
+// hasDefaults reports whether the loan models defaults
+func (d *DefaultInfo) hasDefaults() bool {
+	return d.DefaultCDR > 0
+}
+
+// monthlyDefaultRate returns the share of the scheduled balance defaulting
+// each period, 1 - (1-CDR)^(1/12), the same conversion as CPR to SMM
+func (d *DefaultInfo) monthlyDefaultRate() float64 {
+	return SMMFromCPR(d.DefaultCDR)
+}
+
+// severityAt returns the share lost on balances defaulting in period
+// (1-based)
+func (d *DefaultInfo) severityAt(period int) float64 {
+	if len(d.SeverityArr) == 0 {
+		return d.SeverityPct
+	}
+	return d.SeverityArr[min(period, len(d.SeverityArr))-1]
+}
+
+// LossSummary condenses a table's defaults and losses for credit investors
+type LossSummary struct {
+	RealizedCDR          float64 `json:"realized_cdr"`           // Annual default rate implied by the defaults, in decimals
+	CumulativeDefaultPct float64 `json:"cumulative_default_pct"` // Defaulted balance as a percent of the opening balance
+	CumulativeLossPct    float64 `json:"cumulative_loss_pct"`    // Loss as a percent of the opening balance
+	PeakDelinquency      float64 `json:"peak_delinquency"`       // Highest share of the loan 30 to 180 days delinquent
+}
+
+// LossSummary reports the table's cumulative defaults and losses against its
+// opening balance, the annual default rate they imply, and the peak of the
+// delinquency curves. The realized CDR annualizes the average share of the
+// scheduled balance that defaulted over the periods that had one. Tables
+// without defaults or delinquency curves report zeros.
+func (a *AmortizationTable) LossSummary() LossSummary {
+	var summary LossSummary
+
+	if len(a.BegBal) > 0 && a.BegBal[0] > 0 && len(a.CumulativeDefault) > 0 {
+		last := len(a.CumulativeDefault) - 1
+		summary.CumulativeDefaultPct = a.CumulativeDefault[last] / a.BegBal[0] * 100
+		summary.CumulativeLossPct = a.CumulativeLoss[last] / a.BegBal[0] * 100
+	}
+
+	mdr, periods := 0.0, 0
+	for i, defaulted := range a.DefaultAmountArr {
+		if a.SchedBal[i] > 0 {
+			mdr += defaulted / a.SchedBal[i]
+			periods++
+		}
+	}
+	if periods > 0 {
+		summary.RealizedCDR = smmToCPR(mdr / float64(periods))
+	}
+
+	dq := a.DelinqArrays
+	for i := range dq.DQ30Arr {
+		delinquent := dq.DQ30Arr[i] + dq.DQ60Arr[i] + dq.DQ90Arr[i] + dq.DQ120Arr[i] + dq.DQ150Arr[i] + dq.DQ180Arr[i]
+		summary.PeakDelinquency = max(summary.PeakDelinquency, delinquent)
+	}
+	return summary
+}
+
+// runningTotal returns the running sum of col through each period, to the
+// cent
+func runningTotal(col []float64) Amounts {
+	cumulative := make(Amounts, len(col))
+	total := 0.0
+	for i, v := range col {
+		total = roundToCent(total + v)
+		cumulative[i] = total
+	}
+	return cumulative
+}
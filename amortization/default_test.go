@@ -0,0 +1,156 @@
+package amortization
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGetAmortizationTable_ConstantCDR(t *testing.T) {
+	const cdr, severity = 0.02, 0.35
+
+	for _, cents := range []bool{false, true} {
+		loan := LoanInfo{
+			ID: "CDR001", Wam: 360, Wac: 6.0, Face: 250000.0,
+			IntegerCents: cents,
+			DefaultInfo:  DefaultInfo{DefaultCDR: cdr, SeverityPct: severity},
+		}
+		table := loan.GetAmortizationTable()
+
+		if err := table.Check(); err != nil {
+			t.Fatalf("integer cents %v: %v", cents, err)
+		}
+		n := len(table.Period)
+		if len(table.DefaultAmountArr) != n || len(table.LossArr) != n || len(table.CumulativeDefault) != n || len(table.CumulativeLoss) != n {
+			t.Fatalf("integer cents %v: expected %d entries in each default column", cents, n)
+		}
+
+		// Each period defaults the monthly rate implied by the CDR on the
+		// scheduled balance, to within rounding, until the payoff period
+		mdr := SMMFromCPR(cdr)
+		for i := 0; i < n-1; i++ {
+			expected := mdr * table.SchedBal[i]
+			if math.Abs(table.DefaultAmountArr[i]-expected) > 0.02 {
+				t.Errorf("integer cents %v, period %d: expected default %.2f, got %.2f", cents, i+1, expected, table.DefaultAmountArr[i])
+			}
+		}
+
+		// Severity applies to every default, so the cumulative loss is the
+		// cumulative default times severity, to within a cent per period
+		last := n - 1
+		expectedLoss := severity * table.CumulativeDefault[last]
+		if diff := math.Abs(table.CumulativeLoss[last] - expectedLoss); diff > 0.01*float64(n) {
+			t.Errorf("integer cents %v: expected cumulative loss %.2f, got %.2f", cents, expectedLoss, table.CumulativeLoss[last])
+		}
+
+		summary := table.LossSummary()
+		if math.Abs(summary.RealizedCDR-cdr) > 1e-4 {
+			t.Errorf("integer cents %v: expected realized CDR near %v, got %v", cents, cdr, summary.RealizedCDR)
+		}
+		if math.Abs(summary.CumulativeLossPct-severity*summary.CumulativeDefaultPct) > 1e-3 {
+			t.Errorf("integer cents %v: expected loss pct %.4f to be severity times default pct %.4f",
+				cents, summary.CumulativeLossPct, summary.CumulativeDefaultPct)
+		}
+	}
+}
+
+func TestGetAmortizationTable_DefaultsReduceBalance(t *testing.T) {
+	base := LoanInfo{ID: "CDR002", Wam: 120, Wac: 5.0, Face: 100000.0}
+	defaulting := base
+	defaulting.DefaultInfo = DefaultInfo{DefaultCDR: 0.10, SeverityPct: 0.40}
+
+	baseTable := base.GetAmortizationTable()
+	defaultTable := defaulting.GetAmortizationTable()
+
+	if baseTable.DefaultAmountArr != nil || baseTable.CumulativeLoss != nil {
+		t.Error("Expected no default columns without a default model")
+	}
+	if defaultTable.EndBal[11] >= baseTable.EndBal[11] {
+		t.Errorf("Expected defaults to lower the balance after a year, got %.2f vs %.2f",
+			defaultTable.EndBal[11], baseTable.EndBal[11])
+	}
+
+	// Scheduled principal, defaults and prepayment together retire the face
+	total := 0.0
+	for i := range defaultTable.Period {
+		total += defaultTable.Principal[i] + defaultTable.DefaultAmountArr[i] + defaultTable.PrepayAmountArr[i]
+	}
+	if math.Abs(total-defaulting.Face) > 0.01 {
+		t.Errorf("Expected %.2f retired, got %.2f", defaulting.Face, total)
+	}
+}
+
+func TestGetAmortizationTable_SeverityCurve(t *testing.T) {
+	flat := LoanInfo{
+		ID: "SEV001", Wam: 360, Wac: 6.0, Face: 300000.0,
+		DefaultInfo: DefaultInfo{DefaultCDR: 0.03, SeverityPct: 0.35},
+	}
+	// Losses run high on early defaults and settle lower for seasoned loans;
+	// the curve is shorter than the term and SeverityPct is ignored
+	curved := flat
+	curved.SeverityArr = []float64{0.6, 0.6, 0.5, 0.4, 0.3, 0.2}
+
+	flatTable := flat.GetAmortizationTable()
+	curvedTable := curved.GetAmortizationTable()
+
+	// Defaults do not depend on severity; only the losses on them change
+	for i := range flatTable.Period {
+		if flatTable.DefaultAmountArr[i] != curvedTable.DefaultAmountArr[i] {
+			t.Fatalf("Period %d: expected equal defaults, got %.2f and %.2f", i+1, flatTable.DefaultAmountArr[i], curvedTable.DefaultAmountArr[i])
+		}
+	}
+	for i, severity := range []float64{0.6, 0.6, 0.5, 0.4, 0.3, 0.2, 0.2, 0.2} {
+		if want := roundToCent(severity * curvedTable.DefaultAmountArr[i]); curvedTable.LossArr[i] != want {
+			t.Errorf("Period %d: expected loss %.2f at severity %.1f, got %.2f", i+1, want, severity, curvedTable.LossArr[i])
+		}
+	}
+
+	last := len(flatTable.Period) - 1
+	flatLoss, curvedLoss := flatTable.CumulativeLoss[last], curvedTable.CumulativeLoss[last]
+	if math.Abs(flatLoss-curvedLoss) < 1.0 {
+		t.Errorf("Expected the severity curve to change cumulative loss, got %.2f and %.2f", flatLoss, curvedLoss)
+	}
+	// From period 7 on the curve holds at 0.2, below the flat 0.35
+	if curvedLoss >= flatLoss {
+		t.Errorf("Expected lower cumulative loss under the curve, got %.2f vs flat %.2f", curvedLoss, flatLoss)
+	}
+}
+
+func TestLossSummary_NoDefaults(t *testing.T) {
+	loan := LoanInfo{ID: "CDR003", Wam: 60, Wac: 4.0, Face: 20000.0}
+	table := loan.GetAmortizationTable()
+	if summary := table.LossSummary(); summary != (LossSummary{}) {
+		t.Errorf("Expected a zero loss summary, got %+v", summary)
+	}
+}
+
+func TestValidate_DefaultModel(t *testing.T) {
+	testCases := []struct {
+		name    string
+		info    DefaultInfo
+		wantErr string
+	}{
+		{name: "no defaults", info: DefaultInfo{}},
+		{name: "valid", info: DefaultInfo{DefaultCDR: 0.03, SeverityPct: 0.4}},
+		{name: "negative CDR", info: DefaultInfo{DefaultCDR: -0.01}, wantErr: "CDR must be between 0 and 1"},
+		{name: "CDR of one", info: DefaultInfo{DefaultCDR: 1}, wantErr: "CDR must be between 0 and 1"},
+		{name: "severity above one", info: DefaultInfo{DefaultCDR: 0.02, SeverityPct: 1.5}, wantErr: "severity must be between 0 and 1"},
+		{name: "negative severity curve", info: DefaultInfo{DefaultCDR: 0.02, SeverityArr: []float64{0.4, -0.1}}, wantErr: "severity_arr[1] must be between 0 and 1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loan := LoanInfo{ID: "CDR004", Wam: 360, Wac: 5.0, Face: 100000.0, DefaultInfo: tc.info}
+			err := loan.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package amortization
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// delinquencyStates is the number of statuses a roll-rate row transitions
+// between: performing, 30 through 180 days delinquent, and default
+const delinquencyStates = 8
+
+// transitionTolerance absorbs rounding in supplied probabilities, e.g. rows
+// of thirds that sum to 0.9999999
+const transitionTolerance = 1e-6
+
+// transitionNames are the JSON names of the roll-rate rows, performing first
+var transitionNames = [delinquencyStates]string{
+	"performing_transition", "dq30_transition", "dq60_transition", "dq90_transition",
+	"dq120_transition", "dq150_transition", "dq180_transition", "default_transition",
+}
+
+// transitionRow is one roll-rate row named as it appears in JSON
+type transitionRow struct {
+	name string
+	row  []float64
+}
+
+// transitionRows returns the roll-rate rows from performing to default
+func (d *DelinquencyInfo) transitionRows() []transitionRow {
+	rows := make([]transitionRow, delinquencyStates)
+	for i, row := range d.transitionRefs() {
+		rows[i] = transitionRow{transitionNames[i], *row}
+	}
+	return rows
+}
+
+// ValidateTransitions checks that every supplied roll-rate row has exactly
+// one probability per delinquency status, none negative, summing to 1.
+// Omitted rows are not checked. The error names the offending row.
+func (l *LoanInfo) ValidateTransitions() error {
+	for _, t := range l.transitionRows() {
+		if t.row == nil {
+			continue
+		}
+		if len(t.row) != delinquencyStates {
+			return fmt.Errorf("%s must have %d elements, got %d", t.name, delinquencyStates, len(t.row))
+		}
+		if err := validateTransitionRow(t.name, t.row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTransitionRow checks that a roll-rate row is a probability
+// distribution: no negative entries, summing to 1
+func validateTransitionRow(name string, row []float64) error {
+	sum := 0.0
+	for _, p := range row {
+		if p < 0 {
+			return fmt.Errorf("%s probabilities cannot be negative, got %f", name, p)
+		}
+		sum += p
+	}
+	if math.Abs(sum-1.0) > transitionTolerance {
+		return fmt.Errorf("%s must sum to 1, got %f", name, sum)
+	}
+	return nil
+}
+
+// RollRateMatrix holds the monthly transition probabilities between
+// delinquency statuses, performing through default. Row i is the
+// distribution of where a loan in status i is one period later. It marshals
+// to JSON under the same names as the transition fields of DelinquencyInfo.
+type RollRateMatrix [delinquencyStates][delinquencyStates]float64
+
+// FromLoanInfo fills the matrix from the loan's transition rows. A row the
+// loan omits keeps a loan in its status. A supplied row of the wrong length
+// is an error.
+func (m *RollRateMatrix) FromLoanInfo(l *LoanInfo) error {
+	for i, t := range l.transitionRows() {
+		if t.row == nil {
+			m[i] = [delinquencyStates]float64{}
+			m[i][i] = 1.0
+			continue
+		}
+		if len(t.row) != delinquencyStates {
+			return fmt.Errorf("%s must have %d elements, got %d", t.name, delinquencyStates, len(t.row))
+		}
+		copy(m[i][:], t.row)
+	}
+	return nil
+}
+
+// Validate checks that the matrix is stochastic: every row non-negative and
+// summing to 1. The error names the offending row.
+func (m *RollRateMatrix) Validate() error {
+	for i, name := range transitionNames {
+		if err := validateTransitionRow(name, m[i][:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply moves a distribution over the delinquency statuses forward one
+// period
+func (m *RollRateMatrix) Apply(dist [delinquencyStates]float64) [delinquencyStates]float64 {
+	var next [delinquencyStates]float64
+	for from, share := range dist {
+		if share == 0 {
+			continue
+		}
+		for to, p := range m[from] {
+			next[to] += share * p
+		}
+	}
+	return next
+}
+
+// MarshalJSON writes the matrix as the transition rows of DelinquencyInfo
+func (m RollRateMatrix) MarshalJSON() ([]byte, error) {
+	var d DelinquencyInfo
+	for i, t := range d.transitionRefs() {
+		*t = append([]float64(nil), m[i][:]...)
+	}
+	return json.Marshal(d)
+}
+
+// UnmarshalJSON reads the matrix from transition rows named as in
+// DelinquencyInfo. Every row is required.
+func (m *RollRateMatrix) UnmarshalJSON(data []byte) error {
+	var d DelinquencyInfo
+	if err := json.Unmarshal(data, &d); err != nil {
+		return err
+	}
+	for i, t := range d.transitionRefs() {
+		if len(*t) != delinquencyStates {
+			return fmt.Errorf("%s must have %d elements, got %d", transitionNames[i], delinquencyStates, len(*t))
+		}
+		copy(m[i][:], *t)
+	}
+	return nil
+}
+
+// transitionRefs returns pointers to the roll-rate rows from performing to
+// default, for filling them in
+func (d *DelinquencyInfo) transitionRefs() []*[]float64 {
+	return []*[]float64{
+		&d.PerformingTransition, &d.DQ30Transition, &d.DQ60Transition, &d.DQ90Transition,
+		&d.DQ120Transition, &d.DQ150Transition, &d.DQ180Transition, &d.DefaultTransition,
+	}
+}
+
+// delinquencyCurves rolls a performing loan forward through the roll-rate
+// matrix, returning the share of the loan in each status at the end of every
+// period. Loans without StaticDQ, or whose matrix is malformed, have no
+// curves.
+func (l *LoanInfo) delinquencyCurves(n int) DelinqArrays {
+	var m RollRateMatrix
+	if !l.StaticDQ || m.FromLoanInfo(l) != nil {
+		return DelinqArrays{}
+	}
+
+	curves := DelinqArrays{}
+	columns := curves.columns()
+	for _, col := range columns {
+		*col = make([]float64, n)
+	}
+	dist := [delinquencyStates]float64{1.0} // Every loan starts performing
+	for j := 0; j < n; j++ {
+		dist = m.Apply(dist)
+		for status, col := range columns {
+			(*col)[j] = dist[status]
+		}
+	}
+	return curves
+}
+
+// interestCollections splits each period's accrued interest between the
+// cash paid by the performing share of the loan and the arrears owed by the
+// share in any other status, rolling both through the roll-rate matrix.
+// Arrears move with the loans that owe them and are remitted when those loans
+// cure back to performing. It returns the interest collected in each period
+// and the arrears outstanding at its end; loans without StaticDQ, or whose
+// matrix is malformed, have neither.
+func (l *LoanInfo) interestCollections(interest []float64) (collected, arrears Amounts) {
+	var m RollRateMatrix
+	if !l.StaticDQ || m.FromLoanInfo(l) != nil {
+		return nil, nil
+	}
+
+	collected = make(Amounts, len(interest))
+	arrears = make(Amounts, len(interest))
+	dist := [delinquencyStates]float64{1.0} // Every loan starts performing
+	var owed [delinquencyStates]float64     // Arrears carried by the loans in each status
+	for j, accrued := range interest {
+		dist = m.Apply(dist)
+		owed = m.Apply(owed)
+
+		// Loans that cured this period pay what they owe
+		remitted := owed[0]
+		owed[0] = 0
+		outstanding := 0.0
+		for status := 1; status < delinquencyStates; status++ {
+			owed[status] += accrued * dist[status]
+			outstanding += owed[status]
+		}
+		collected[j] = roundToCent(accrued*dist[0] + remitted)
+		arrears[j] = roundToCent(outstanding)
+	}
+	return collected, arrears
+}
+
+// columns returns pointers to the status arrays from performing to default
+func (d *DelinqArrays) columns() []*[]float64 {
+	return []*[]float64{
+		&d.PerfArr, &d.DQ30Arr, &d.DQ60Arr, &d.DQ90Arr, &d.DQ120Arr, &d.DQ150Arr, &d.DQ180Arr, &d.DefaultArr,
+	}
+}
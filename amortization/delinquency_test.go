@@ -0,0 +1,252 @@
+package amortization
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidateTransitions(t *testing.T) {
+	valid := []float64{0.92, 0.02, 0.01, 0.01, 0.01, 0.01, 0.01, 0.01}
+
+	testCases := []struct {
+		name    string
+		dq      DelinquencyInfo
+		wantErr string
+	}{
+		{name: "no rows", dq: DelinquencyInfo{}},
+		{name: "valid rows", dq: DelinquencyInfo{PerformingTransition: valid, DQ30Transition: valid}},
+		{name: "thirds within tolerance", dq: DelinquencyInfo{DefaultTransition: []float64{1.0 / 3, 1.0 / 3, 1.0 / 3, 0, 0, 0, 0, 0}}},
+		{
+			name:    "short row",
+			dq:      DelinquencyInfo{PerformingTransition: valid, DQ30Transition: []float64{0.90, 0.04, 0.02, 0.01, 0.01, 0.01, 0.01}},
+			wantErr: "dq30_transition must have 8 elements, got 7",
+		},
+		{
+			name:    "row summing to 0.97",
+			dq:      DelinquencyInfo{DQ60Transition: []float64{0.90, 0.02, 0.01, 0.01, 0.01, 0.01, 0.01, 0.00}},
+			wantErr: "dq60_transition must sum to 1, got 0.970000",
+		},
+		{
+			name:    "negative probability",
+			dq:      DelinquencyInfo{DQ180Transition: []float64{1.02, -0.02, 0, 0, 0, 0, 0, 0}},
+			wantErr: "dq180_transition probabilities cannot be negative",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loan := LoanInfo{DelinquencyInfo: tc.dq}
+			err := loan.ValidateTransitions()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Transitions(t *testing.T) {
+	loan := LoanInfo{ID: "DQ", Wam: 360, Wac: 5.0, Face: 100000.0}
+	loan.DQ30Transition = []float64{0.5, 0.5}
+
+	err := loan.Validate()
+	if err == nil || !strings.Contains(err.Error(), "dq30_transition") {
+		t.Errorf("Expected Validate to reject the malformed row, got %v", err)
+	}
+}
+
+// testRollRates is a stochastic matrix: delinquent loans cure or roll one
+// bucket deeper, and default is absorbing
+func testRollRates() DelinquencyInfo {
+	return DelinquencyInfo{
+		PerformingTransition: []float64{0.97, 0.03, 0, 0, 0, 0, 0, 0},
+		DQ30Transition:       []float64{0.50, 0, 0.50, 0, 0, 0, 0, 0},
+		DQ60Transition:       []float64{0.30, 0, 0, 0.70, 0, 0, 0, 0},
+		DQ90Transition:       []float64{0.20, 0, 0, 0, 0.80, 0, 0, 0},
+		DQ120Transition:      []float64{0.10, 0, 0, 0, 0, 0.90, 0, 0},
+		DQ150Transition:      []float64{0.05, 0, 0, 0, 0, 0, 0.95, 0},
+		DQ180Transition:      []float64{0.05, 0, 0, 0, 0, 0, 0, 0.95},
+		DefaultTransition:    []float64{0, 0, 0, 0, 0, 0, 0, 1},
+	}
+}
+
+func TestRollRateMatrix_Valid(t *testing.T) {
+	loan := LoanInfo{DelinquencyInfo: testRollRates()}
+	var m RollRateMatrix
+	if err := m.FromLoanInfo(&loan); err != nil {
+		t.Fatalf("FromLoanInfo failed: %v", err)
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("Expected a valid matrix, got %v", err)
+	}
+	if m[1][2] != 0.50 || m[7][7] != 1 {
+		t.Errorf("Expected rows in status order, got %v and %v", m[1], m[7])
+	}
+
+	dist := m.Apply([8]float64{1.0})
+	if dist[0] != 0.97 || dist[1] != 0.03 {
+		t.Errorf("Expected one roll of a performing loan to be [0.97 0.03 ...], got %v", dist)
+	}
+	dist = m.Apply(dist)
+	total := 0.0
+	for _, share := range dist {
+		total += share
+	}
+	if math.Abs(total-1.0) > 1e-12 {
+		t.Errorf("Expected the distribution to stay whole, got a total of %f", total)
+	}
+	if want := 0.97*0.97 + 0.03*0.50; math.Abs(dist[0]-want) > 1e-12 {
+		t.Errorf("Expected %.6f performing after two rolls, got %.6f", want, dist[0])
+	}
+
+	// Omitted rows keep a loan in its status
+	var partial RollRateMatrix
+	if err := partial.FromLoanInfo(&LoanInfo{DelinquencyInfo: DelinquencyInfo{PerformingTransition: loan.PerformingTransition}}); err != nil {
+		t.Fatalf("FromLoanInfo failed: %v", err)
+	}
+	if partial[3][3] != 1 || partial.Validate() != nil {
+		t.Errorf("Expected an omitted row to be absorbing, got %v", partial[3])
+	}
+}
+
+func TestRollRateMatrix_NonStochastic(t *testing.T) {
+	dq := testRollRates()
+	dq.DQ90Transition = []float64{0.20, 0, 0, 0, 0.70, 0, 0, 0}
+	var m RollRateMatrix
+	if err := m.FromLoanInfo(&LoanInfo{DelinquencyInfo: dq}); err != nil {
+		t.Fatalf("FromLoanInfo failed: %v", err)
+	}
+	if err := m.Validate(); err == nil || !strings.Contains(err.Error(), "dq90_transition must sum to 1") {
+		t.Errorf("Expected dq90_transition to fail validation, got %v", err)
+	}
+
+	m[2][0] = -0.1
+	if err := m.Validate(); err == nil || !strings.Contains(err.Error(), "dq60_transition probabilities cannot be negative") {
+		t.Errorf("Expected dq60_transition to fail validation, got %v", err)
+	}
+
+	dq.DQ30Transition = []float64{1}
+	if err := m.FromLoanInfo(&LoanInfo{DelinquencyInfo: dq}); err == nil {
+		t.Error("Expected a short row to be rejected")
+	}
+}
+
+func TestRollRateMatrix_JSONUsesTransitionNames(t *testing.T) {
+	loan := LoanInfo{DelinquencyInfo: testRollRates()}
+	var m RollRateMatrix
+	if err := m.FromLoanInfo(&loan); err != nil {
+		t.Fatalf("FromLoanInfo failed: %v", err)
+	}
+
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"dq30_transition":[0.5,0,0.5,0,0,0,0,0]`) {
+		t.Errorf("Expected the transition field names, got %s", encoded)
+	}
+
+	// A loan's transition fields decode as a matrix, and back
+	var decoded RollRateMatrix
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded != m {
+		t.Errorf("Expected the matrix to round-trip, got %v", decoded)
+	}
+	if err := json.Unmarshal([]byte(`{"performing_transition": [1,0,0,0,0,0,0,0]}`), &decoded); err == nil {
+		t.Error("Expected a matrix missing rows to be rejected")
+	}
+}
+
+func TestGetAmortizationTable_DelinquencyCurves(t *testing.T) {
+	dq := testRollRates()
+	dq.StaticDQ = true
+	loan := LoanInfo{ID: "DQ", Wam: 60, Wac: 5.0, Face: 100000.0, DelinquencyInfo: dq}
+	table := loan.GetAmortizationTable()
+
+	curves := table.DelinqArrays
+	if len(curves.PerfArr) != 60 || len(curves.DefaultArr) != 60 {
+		t.Fatalf("Expected 60 periods of curves, got %d", len(curves.PerfArr))
+	}
+	if curves.PerfArr[0] != 0.97 || curves.DQ30Arr[0] != 0.03 {
+		t.Errorf("Expected the first period to roll 3%% to 30 days, got %.4f and %.4f", curves.PerfArr[0], curves.DQ30Arr[0])
+	}
+	for j := 1; j < 60; j++ {
+		if curves.DefaultArr[j] < curves.DefaultArr[j-1] {
+			t.Fatalf("Period %d: expected the absorbing default share to never fall", j+1)
+		}
+	}
+
+	loan.StaticDQ = false
+	if table := loan.GetAmortizationTable(); table.DelinqArrays.PerfArr != nil {
+		t.Error("Expected no curves without StaticDQ")
+	}
+}
+
+func TestInterestCollections_ArrearsCollectedOnCure(t *testing.T) {
+	// A tenth of performing loans miss a payment each period and every 30-day
+	// delinquent loan cures the next
+	loan := LoanInfo{
+		ID: "CURE001", Wam: 360, Wac: 6.0, Face: 200000.0,
+		DelinquencyInfo: DelinquencyInfo{
+			StaticDQ:             true,
+			PerformingTransition: []float64{0.9, 0.1, 0, 0, 0, 0, 0, 0},
+			DQ30Transition:       []float64{1, 0, 0, 0, 0, 0, 0, 0},
+		},
+	}
+	table := loan.GetAmortizationTable()
+
+	if len(table.CollectedInterest) != len(table.Period) || len(table.InterestArrears) != len(table.Period) {
+		t.Fatalf("Expected collected interest and arrears for every period")
+	}
+
+	// Period 1: the delinquent tenth accrues but does not pay
+	accrued1, accrued2 := table.Interest[0], table.Interest[1]
+	if want := roundToCent(0.9 * accrued1); table.CollectedInterest[0] != want {
+		t.Errorf("Period 1: expected collected interest %.2f, got %.2f", want, table.CollectedInterest[0])
+	}
+	if want := roundToCent(0.1 * accrued1); table.InterestArrears[0] != want {
+		t.Errorf("Period 1: expected arrears %.2f, got %.2f", want, table.InterestArrears[0])
+	}
+
+	// Period 2: the cured tenth remits its arrears with its payment, while a
+	// new 9% of the loan falls behind
+	if want := roundToCent(0.91*accrued2 + 0.1*accrued1); math.Abs(table.CollectedInterest[1]-want) > 0.01 {
+		t.Errorf("Period 2: expected collected interest %.2f, got %.2f", want, table.CollectedInterest[1])
+	}
+	if want := roundToCent(0.09 * accrued2); math.Abs(table.InterestArrears[1]-want) > 0.01 {
+		t.Errorf("Period 2: expected arrears %.2f, got %.2f", want, table.InterestArrears[1])
+	}
+
+	// Every accrued dollar is either collected or still owed
+	collected, accrued := 0.0, 0.0
+	for i := range table.Period {
+		collected += table.CollectedInterest[i]
+		accrued += table.Interest[i]
+	}
+	last := len(table.Period) - 1
+	if diff := math.Abs(collected + table.InterestArrears[last] - accrued); diff > 0.01*float64(len(table.Period)) {
+		t.Errorf("Expected collected %.2f plus arrears %.2f to equal accrued %.2f", collected, table.InterestArrears[last], accrued)
+	}
+
+	// Cashflows count the cash collected, not the interest accrued
+	if flows := table.Cashflows(); flows[0] != table.CollectedInterest[0]+table.Principal[0]+table.PrepayAmountArr[0] {
+		t.Errorf("Expected period 1 cashflow to use collected interest, got %.2f", flows[0])
+	}
+}
+
+func TestInterestCollections_WithoutStaticDQ(t *testing.T) {
+	loan := LoanInfo{ID: "CURE002", Wam: 60, Wac: 5.0, Face: 10000.0}
+	table := loan.GetAmortizationTable()
+	if table.CollectedInterest != nil || table.InterestArrears != nil {
+		t.Error("Expected no interest collection columns without StaticDQ")
+	}
+}
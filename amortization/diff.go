@@ -0,0 +1,52 @@
+package amortization
+
+// TableDiff holds the differences between two tables, each taken as the
+// compared table minus the base. Tables of different lengths are aligned on
+// their first period and a table contributes zero past its last period.
+type TableDiff struct {
+	Period       []int   `json:"period"`        // Period numbers (1, 2, 3, ...)
+	Interest     Amounts `json:"interest"`      // Interest delta for each period
+	Principal    Amounts `json:"principal"`     // Scheduled principal delta for each period
+	PrepayAmount Amounts `json:"prepay_amount"` // Prepayment delta for each period
+	EndBal       Amounts `json:"end_bal"`       // Ending balance delta for each period
+
+	TotalInterest  float64 `json:"total_interest"`  // Change in total interest
+	TotalPrincipal float64 `json:"total_principal"` // Change in scheduled plus prepaid principal
+	WAL            float64 `json:"wal"`             // Change in weighted average life, in years
+	Periods        int     `json:"periods"`         // Change in the number of periods
+}
+
+// DiffTables compares other against base period by period and in aggregate,
+// e.g. to show the effect of an assumption change on the same loan
+func DiffTables(base, other AmortizationTable) TableDiff {
+	n := max(len(base.Period), len(other.Period))
+	diff := TableDiff{
+		Period:       make([]int, n),
+		Interest:     make(Amounts, n),
+		Principal:    make(Amounts, n),
+		PrepayAmount: make(Amounts, n),
+		EndBal:       make(Amounts, n),
+	}
+	for i := 0; i < n; i++ {
+		diff.Period[i] = i + 1
+		diff.Interest[i] = roundToCent(columnAt(other.Interest, i) - columnAt(base.Interest, i))
+		diff.Principal[i] = roundToCent(columnAt(other.Principal, i) - columnAt(base.Principal, i))
+		diff.PrepayAmount[i] = roundToCent(columnAt(other.PrepayAmountArr, i) - columnAt(base.PrepayAmountArr, i))
+		diff.EndBal[i] = roundToCent(columnAt(other.EndBal, i) - columnAt(base.EndBal, i))
+	}
+
+	baseSummary, otherSummary := base.Summary(), other.Summary()
+	diff.TotalInterest = roundToCent(otherSummary.TotalInterest - baseSummary.TotalInterest)
+	diff.TotalPrincipal = roundToCent(otherSummary.TotalPrincipal - baseSummary.TotalPrincipal)
+	diff.WAL = otherSummary.WAL - baseSummary.WAL
+	diff.Periods = otherSummary.Periods - baseSummary.Periods
+	return diff
+}
+
+// columnAt returns col[i], or zero past the end of the column
+func columnAt(col []float64, i int) float64 {
+	if i < len(col) {
+		return col[i]
+	}
+	return 0.0
+}
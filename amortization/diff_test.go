@@ -0,0 +1,42 @@
+package amortization
+
+import "testing"
+
+func TestDiffTables(t *testing.T) {
+	base := (&LoanInfo{ID: "BASE", Wam: 360, Wac: 6.0, Face: 200000.0}).GetAmortizationTable()
+	fast := (&LoanInfo{ID: "FAST", Wam: 360, Wac: 6.0, Face: 200000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.15}}).GetAmortizationTable()
+
+	diff := DiffTables(base, fast)
+	if len(diff.Period) != 360 || len(diff.Interest) != 360 {
+		t.Fatalf("Expected 360 periods of deltas, got %d", len(diff.Period))
+	}
+	for i := range diff.Period {
+		if want := roundToCent(fast.Interest[i] - base.Interest[i]); diff.Interest[i] != want {
+			t.Fatalf("Period %d: expected interest delta %.2f, got %.2f", i+1, want, diff.Interest[i])
+		}
+	}
+	if diff.TotalInterest >= 0 || diff.WAL >= 0 {
+		t.Errorf("Expected faster prepayment to cut interest and WAL, got %.2f and %.4f", diff.TotalInterest, diff.WAL)
+	}
+	if diff.TotalPrincipal != 0 {
+		t.Errorf("Expected the same principal to be returned either way, got a delta of %.2f", diff.TotalPrincipal)
+	}
+
+	if self := DiffTables(base, base); self.TotalInterest != 0 || self.WAL != 0 {
+		t.Errorf("Expected no difference between a table and itself, got %+v", self)
+	}
+}
+
+func TestDiffTables_DifferentLengths(t *testing.T) {
+	short := (&LoanInfo{ID: "SHORT", Wam: 12, Wac: 5.0, Face: 12000.0}).GetAmortizationTable()
+	long := (&LoanInfo{ID: "LONG", Wam: 24, Wac: 5.0, Face: 12000.0}).GetAmortizationTable()
+
+	diff := DiffTables(short, long)
+	if len(diff.Period) != 24 || diff.Periods != 12 {
+		t.Fatalf("Expected 24 aligned periods and 12 more periods, got %d and %d", len(diff.Period), diff.Periods)
+	}
+	// Past the short table's end it contributes zero
+	if diff.Interest[20] != long.Interest[20] || diff.EndBal[20] != long.EndBal[20] {
+		t.Errorf("Expected the longer table's values past the shorter one's end, got %.2f and %.2f", diff.Interest[20], diff.EndBal[20])
+	}
+}
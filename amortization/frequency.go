@@ -0,0 +1,113 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+)
+
+// Payment frequencies the engine amortizes; empty means monthly
+const (
+	FrequencyMonthly  = "monthly"
+	FrequencyBiweekly = "biweekly"
+)
+
+// biweeklyPeriodsPerYear is the number of biweekly payments in a year
+const biweeklyPeriodsPerYear = 26
+
+// biweeklyYearFraction is the share of a year each biweekly period accrues:
+// its 14 actual days out of 365. Twenty-six periods span only 364 days, so
+// accruing a twenty-sixth of the coupon each period would charge a full
+// year's interest a day early.
+const biweeklyYearFraction = 14.0 / 365.0
+
+// biweekly reports whether the loan pays every two weeks
+func (l *LoanInfo) biweekly() bool {
+	return l.PaymentFrequency == FrequencyBiweekly
+}
+
+// periodsPerYear returns the number of payments the loan makes in a year
+func (l *LoanInfo) periodsPerYear() int {
+	if l.biweekly() {
+		return biweeklyPeriodsPerYear
+	}
+	return 12
+}
+
+// periodsIn converts a span of months to the loan's periods, Wam/12*26 for a
+// biweekly loan
+func (l *LoanInfo) periodsIn(months int64) int64 {
+	if l.biweekly() {
+		return months * biweeklyPeriodsPerYear / 12
+	}
+	return months
+}
+
+// monthOf returns the month (1-based) in which period j (0-based) falls, for
+// consulting the monthly prepayment models
+func (l *LoanInfo) monthOf(j int) int {
+	if l.biweekly() {
+		return j*12/biweeklyPeriodsPerYear + 1
+	}
+	return j + 1
+}
+
+// perPeriodRate converts a monthly rate of decrement, such as an SMM, to the
+// loan's periods: 1 - (1-rate)^(12/periods per year)
+func (l *LoanInfo) perPeriodRate(monthly float64) float64 {
+	if !l.biweekly() {
+		return monthly
+	}
+	return 1 - math.Pow(1-monthly, 12.0/biweeklyPeriodsPerYear)
+}
+
+// regularRate returns the rate accrued over one regular period at an annual
+// coupon of couponPct
+func (l *LoanInfo) regularRate(couponPct float64) float64 {
+	if l.biweekly() {
+		return l.accrualRate(couponPct, biweeklyYearFraction)
+	}
+	return l.monthlyRate(couponPct)
+}
+
+// levelPayment returns the payment each period on balance over the loan's
+// term. Biweekly loans follow the usual biweekly plan and pay half the
+// monthly payment every period: 26 half payments a year make 13 monthly
+// payments, which retires the loan years early.
+func (l *LoanInfo) levelPayment(balance float64) float64 {
+	payment := calculateMonthlyPayment(balance, l.monthlyRate(l.CouponPct()), float64(l.Wam))
+	if l.biweekly() {
+		return payment / 2
+	}
+	return payment
+}
+
+// escrowPerPeriod returns the escrow collected with each payment, the
+// monthly escrow spread over the year's payments
+func (l *LoanInfo) escrowPerPeriod() float64 {
+	if l.biweekly() {
+		return l.MonthlyEscrow * 12 / biweeklyPeriodsPerYear
+	}
+	return l.MonthlyEscrow
+}
+
+// validateFrequency checks the payment frequency. Biweekly loans amortize on
+// undated 14-day periods at a fixed coupon, so they cannot carry payment
+// dates, adjustable rates or delinquency curves, which are all monthly.
+func (l *LoanInfo) validateFrequency() error {
+	switch l.PaymentFrequency {
+	case "", FrequencyMonthly:
+		return nil
+	case FrequencyBiweekly:
+	default:
+		return fmt.Errorf("unknown payment frequency %q", l.PaymentFrequency)
+	}
+	switch {
+	case !l.OriginationDate.IsZero():
+		return fmt.Errorf("biweekly loans cannot carry an origination date")
+	case len(l.RateResets) > 0:
+		return fmt.Errorf("biweekly loans cannot carry rate resets")
+	case l.StaticDQ:
+		return fmt.Errorf("biweekly loans cannot carry delinquency curves")
+	}
+	return nil
+}
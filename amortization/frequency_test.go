@@ -0,0 +1,163 @@
+package amortization
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGetAmortizationTable_Biweekly(t *testing.T) {
+	monthly := LoanInfo{ID: "BIW001", Wam: 360, Wac: 6.0, Face: 300000.0}
+	biweekly := monthly
+	biweekly.PaymentFrequency = FrequencyBiweekly
+
+	for _, cents := range []bool{false, true} {
+		monthly.IntegerCents, biweekly.IntegerCents = cents, cents
+		monthlyTable := monthly.GetAmortizationTable()
+		biweeklyTable := biweekly.GetAmortizationTable()
+
+		if err := biweeklyTable.Check(); err != nil {
+			t.Fatalf("integer cents %v: %v", cents, err)
+		}
+		// The schedule runs Wam/12*26 periods
+		if n := len(biweeklyTable.Period); n != 780 {
+			t.Fatalf("integer cents %v: expected 780 periods, got %d", cents, n)
+		}
+		if biweeklyTable.PeriodsPerYear != 26 || monthlyTable.PeriodsPerYear != 0 {
+			t.Errorf("integer cents %v: expected 26 periods per year for biweekly only, got %d and %d",
+				cents, biweeklyTable.PeriodsPerYear, monthlyTable.PeriodsPerYear)
+		}
+
+		// Each payment is half the monthly payment
+		if want := roundToCent(monthlyTable.Payment[0] / 2); math.Abs(biweeklyTable.Payment[0]-want) > 0.01 {
+			t.Errorf("integer cents %v: expected a payment near %.2f, got %.2f", cents, want, biweeklyTable.Payment[0])
+		}
+
+		// Thirteen monthly payments a year retire a 30-year loan in under 25
+		// years, with the early payoff shown as the effective maturity
+		years := float64(biweeklyTable.EffectiveMaturity) / 26
+		if biweeklyTable.EffectiveMaturity == 0 || years > 25 {
+			t.Errorf("integer cents %v: expected payoff within 25 years, got period %d (%.1f years)",
+				cents, biweeklyTable.EffectiveMaturity, years)
+		}
+		if biweeklyTable.WAL() >= monthlyTable.WAL() {
+			t.Errorf("integer cents %v: expected a shorter WAL than monthly, got %.2f vs %.2f",
+				cents, biweeklyTable.WAL(), monthlyTable.WAL())
+		}
+
+		monthlyInterest, biweeklyInterest := 0.0, 0.0
+		for _, v := range monthlyTable.Interest {
+			monthlyInterest += v
+		}
+		for _, v := range biweeklyTable.Interest {
+			biweeklyInterest += v
+		}
+		if biweeklyInterest >= monthlyInterest {
+			t.Errorf("integer cents %v: expected less interest than monthly, got %.2f vs %.2f",
+				cents, biweeklyInterest, monthlyInterest)
+		}
+	}
+}
+
+func TestGetAmortizationTable_BiweeklyRates(t *testing.T) {
+	loan := LoanInfo{
+		ID: "BIW002", Wam: 120, Wac: 5.0, Face: 100000.0,
+		PaymentFrequency: FrequencyBiweekly,
+		PrepayInfo: PrepayInfo{
+			PrepayCPR: 0.06, PrepayPenaltyPct: 0.02, PrepayPenaltyMonths: 12,
+		},
+		MonthlyEscrow: 260.0,
+	}
+	table := loan.GetAmortizationTable()
+
+	// The first period accrues 14 days of the coupon
+	if want := roundToCent(100000.0 * 0.05 * 14 / 365); table.Interest[0] != want {
+		t.Errorf("Expected first-period interest %.2f, got %.2f", want, table.Interest[0])
+	}
+	// The CPR compounds to the same annual rate over 26 periods
+	if smm := loan.SMMArr[0]; math.Abs(math.Pow(1-smm, 26)-(1-0.06)) > 1e-12 {
+		t.Errorf("Expected a biweekly SMM compounding to a 6%% CPR, got %v", smm)
+	}
+	// A year of penalties is 26 biweekly periods
+	if table.PenaltyArr[25] == 0 || table.PenaltyArr[26] != 0 {
+		t.Errorf("Expected penalties through period 26 only, got %.2f and %.2f", table.PenaltyArr[25], table.PenaltyArr[26])
+	}
+	if table.EscrowArr[0] != 120.0 {
+		t.Errorf("Expected escrow of 120.00 a period, got %.2f", table.EscrowArr[0])
+	}
+}
+
+func TestValidate_PaymentFrequency(t *testing.T) {
+	testCases := []struct {
+		name    string
+		modify  func(*LoanInfo)
+		wantErr string
+	}{
+		{name: "monthly", modify: func(l *LoanInfo) { l.PaymentFrequency = FrequencyMonthly }},
+		{name: "biweekly", modify: func(l *LoanInfo) { l.PaymentFrequency = FrequencyBiweekly }},
+		{name: "unknown", modify: func(l *LoanInfo) { l.PaymentFrequency = "weekly" }, wantErr: "unknown payment frequency"},
+		{name: "biweekly with delinquency", modify: func(l *LoanInfo) {
+			l.PaymentFrequency = FrequencyBiweekly
+			l.StaticDQ = true
+		}, wantErr: "cannot carry delinquency curves"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loan := LoanInfo{ID: "BIW003", Wam: 360, Wac: 5.0, Face: 100000.0}
+			tc.modify(&loan)
+			err := loan.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestBiweekly_PricesAtPar(t *testing.T) {
+	loan := LoanInfo{ID: "BIW004", Wam: 360, Wac: 6.0, Face: 100000.0, PaymentFrequency: FrequencyBiweekly}
+	table := loan.GetAmortizationTable()
+
+	pv, ok := table.ParCheck(6.0)
+	if !ok {
+		t.Errorf("Expected par at the coupon, got PV %.2f", pv)
+	}
+
+	pricing := table.Price(table.YieldDiscountFactors(6.0))
+	if math.Abs(pricing.PV-100000.0) > 0.005*float64(len(table.Period)) {
+		t.Errorf("Expected PV at the coupon near par, got %.2f", pricing.PV)
+	}
+	if pricing.Duration <= 0 || pricing.Duration >= table.WAL() {
+		t.Errorf("Expected duration in years between 0 and WAL %.2f, got %.2f", table.WAL(), pricing.Duration)
+	}
+	if higher := table.Price(table.YieldDiscountFactors(7.0)); higher.PV >= pricing.PV {
+		t.Errorf("Expected higher yield to lower PV, got %.2f vs %.2f", higher.PV, pricing.PV)
+	}
+}
+
+func TestBiweekly_AnnualView(t *testing.T) {
+	loan := LoanInfo{ID: "BIW005", Wam: 360, Wac: 6.0, Face: 100000.0, PaymentFrequency: FrequencyBiweekly}
+	table := loan.GetAmortizationTable()
+	annual := table.AnnualView()
+
+	if len(annual.Year) != 30 {
+		t.Fatalf("Expected 30 years of 26 periods, got %d", len(annual.Year))
+	}
+	if annual.EndBal[0] != table.EndBal[25] {
+		t.Errorf("Expected year 1 to end at period 26's balance %.2f, got %.2f", table.EndBal[25], annual.EndBal[0])
+	}
+
+	interest := 0.0
+	for _, v := range annual.Interest {
+		interest += v
+	}
+	if math.Abs(roundToCent(interest)-table.TotalInterest()) > 0.001 {
+		t.Errorf("Expected annual interest %.2f to equal the biweekly total %.2f", interest, table.TotalInterest())
+	}
+}
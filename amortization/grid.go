@@ -0,0 +1,81 @@
+package amortization
+
+import "fmt"
+
+// maxGridCells bounds the CPR by WAC combinations of a sensitivity grid
+const maxGridCells = 2500
+
+// ValidateGrid checks the axes of a sensitivity grid: both must be non-empty,
+// CPRs in decimals within [0, 1) and WACs in percentage points within
+// [0, 30], with at most maxGridCells combinations.
+func ValidateGrid(cprs, wacs []float64) error {
+	if len(cprs) == 0 || len(wacs) == 0 {
+		return fmt.Errorf("grid needs at least one CPR and one WAC")
+	}
+	if len(cprs)*len(wacs) > maxGridCells {
+		return fmt.Errorf("grid of %d by %d exceeds %d cells", len(cprs), len(wacs), maxGridCells)
+	}
+	for _, cpr := range cprs {
+		if cpr < 0 || cpr >= 1 {
+			return fmt.Errorf("CPR must be between 0 and 1, got %f", cpr)
+		}
+	}
+	for _, wac := range wacs {
+		if wac < 0 || wac > 30 {
+			return fmt.Errorf("WAC must be between 0 and 30 percent, got %f", wac)
+		}
+	}
+	return nil
+}
+
+// SensitivityGrid amortizes base at every combination of a flat CPR and a
+// coupon (in percentage points) and summarizes each table, indexed
+// [cpr][wac]. Cells are amortized one after another; callers bounding the
+// work across requests amortize GridScenarios themselves and assemble the
+// result with GridSummaries. Axes are not checked; see ValidateGrid.
+func SensitivityGrid(base LoanInfo, cprs, wacs []float64) [][]TableSummary {
+	scenarios := GridScenarios(base, cprs, wacs)
+	tables := make([]AmortizationTable, len(scenarios))
+	for i := range scenarios {
+		tables[i] = scenarios[i].GetAmortizationTable()
+	}
+	return GridSummaries(base, tables, cprs, wacs)
+}
+
+// GridScenarios returns base at every combination of a flat CPR and a coupon
+// (in percentage points), ordered by CPR and then coupon: the scenario for
+// cprs[i] and wacs[j] is at i*len(wacs)+j. The scenarios replace any
+// prepayment model or SMM vector on base, which is not modified.
+func GridScenarios(base LoanInfo, cprs, wacs []float64) []LoanInfo {
+	scenarios := make([]LoanInfo, 0, len(cprs)*len(wacs))
+	for _, cpr := range cprs {
+		for _, wac := range wacs {
+			scenario := base
+			scenario.PrepayCPR = cpr
+			scenario.PrepayModel = nil
+			scenario.SMMArr = nil
+			scenario.Wac = wac
+			scenario.WacIsDecimal = false
+			scenarios = append(scenarios, scenario)
+		}
+	}
+	return scenarios
+}
+
+// GridSummaries summarizes the tables of the GridScenarios of base, in the
+// same order, into a grid indexed [cpr][wac]. Each summary's PV discounts
+// the scenario's cashflows at the base loan's own coupon, so cells are
+// comparable as prices.
+func GridSummaries(base LoanInfo, tables []AmortizationTable, cprs, wacs []float64) [][]TableSummary {
+	grid := make([][]TableSummary, len(cprs))
+	for i := range grid {
+		grid[i] = make([]TableSummary, len(wacs))
+		for j := range grid[i] {
+			table := tables[i*len(wacs)+j]
+			summary := table.Summary()
+			summary.PV = roundToCent(table.Price(table.YieldDiscountFactors(base.CouponPct())).PV)
+			grid[i][j] = summary
+		}
+	}
+	return grid
+}
@@ -0,0 +1,68 @@
+package amortization
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSensitivityGrid(t *testing.T) {
+	base := LoanInfo{ID: "GRID", Wam: 360, Wac: 6.0, Face: 200000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.5, SMMArr: make([]float64, 360)}}
+	cprs := []float64{0.0, 0.20}
+	wacs := []float64{4.0, 8.0}
+
+	grid := SensitivityGrid(base, cprs, wacs)
+	if len(grid) != len(cprs) {
+		t.Fatalf("Expected %d rows, got %d", len(cprs), len(grid))
+	}
+	for i := range grid {
+		if len(grid[i]) != len(wacs) {
+			t.Fatalf("Row %d: expected %d cells, got %d", i, len(wacs), len(grid[i]))
+		}
+	}
+
+	slowLow, fastHigh := grid[0][0], grid[1][1]
+	if slowLow.WAL <= fastHigh.WAL {
+		t.Errorf("Expected the slow prepay corner to have the longer WAL, got %.4f and %.4f", slowLow.WAL, fastHigh.WAL)
+	}
+	if slowLow.TotalInterest == fastHigh.TotalInterest || slowLow.PV == fastHigh.PV {
+		t.Errorf("Expected the corner cells to differ, got %+v and %+v", slowLow, fastHigh)
+	}
+
+	// Each cell matches amortizing the scenario directly
+	scenario := LoanInfo{ID: "GRID", Wam: 360, Wac: 8.0, Face: 200000.0}
+	table := scenario.GetAmortizationTable()
+	if want := table.Summary(); grid[0][1].TotalInterest != want.TotalInterest {
+		t.Errorf("Expected cell [0][1] total interest %.2f, got %.2f", want.TotalInterest, grid[0][1].TotalInterest)
+	}
+	// At the base coupon without prepayment the loan prices at par
+	par := SensitivityGrid(base, []float64{0}, []float64{6.0})
+	if math.Abs(par[0][0].PV-base.Face) > 1.0 {
+		t.Errorf("Expected a par price of %.2f, got %.2f", base.Face, par[0][0].PV)
+	}
+	if base.PrepayCPR != 0.5 {
+		t.Error("Expected the base loan to be left unmodified")
+	}
+}
+
+func TestValidateGrid(t *testing.T) {
+	tests := []struct {
+		name    string
+		cprs    []float64
+		wacs    []float64
+		wantErr bool
+	}{
+		{name: "valid", cprs: []float64{0, 0.1}, wacs: []float64{3, 7}},
+		{name: "empty CPRs", wacs: []float64{5}, wantErr: true},
+		{name: "empty WACs", cprs: []float64{0.1}, wantErr: true},
+		{name: "CPR of one", cprs: []float64{1}, wacs: []float64{5}, wantErr: true},
+		{name: "negative WAC", cprs: []float64{0.1}, wacs: []float64{-1}, wantErr: true},
+		{name: "too many cells", cprs: make([]float64, 51), wacs: make([]float64, 50), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateGrid(tt.cprs, tt.wacs); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateGrid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
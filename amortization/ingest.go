@@ -0,0 +1,139 @@
+package amortization
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoanInfoFromMap builds a loan from a flat row of strings keyed by the
+// loan's JSON field names, as read from a CSV file or similar row source.
+// id, wam, wac and face are required; other recognised columns are optional
+// and blank cells are treated as unset. Unrecognised columns are ignored.
+// Errors name the offending field, e.g. "wac: not a number". The loan is not
+// validated.
+func LoanInfoFromMap(row map[string]string) (LoanInfo, error) {
+	var l LoanInfo
+	p := rowParser{row: row}
+
+	l.ID = p.required("id")
+	l.Wam = p.int("wam", true)
+	l.Wac = p.float("wac", true)
+	l.Face = p.float("face", true)
+	l.OrigFace = p.float("orig_face", false)
+	l.Factor = p.float("factor", false)
+	l.WacIsDecimal = p.bool("wac_is_decimal")
+	l.DayCount = p.value("day_count")
+	l.OriginationDate = p.date("origination_date")
+	l.Compounding = CompoundingConvention(p.value("compounding"))
+	l.PaymentFrequency = p.value("payment_frequency")
+	l.IntegerCents = p.bool("integer_cents")
+	l.PrepayCPR = p.float("prepay_cpr", false)
+	l.PrepayPenaltyPct = p.float("prepay_penalty_pct", false)
+	l.PrepayPenaltyMonths = p.int("prepay_penalty_months", false)
+	l.SMMCap = p.float("smm_cap", false)
+
+	if p.err != nil {
+		return LoanInfo{}, p.err
+	}
+	return l, nil
+}
+
+// NormalizeFractionalWac corrects a coupon supplied as a fraction during bulk
+// ingestion: a Wac in (0, threshold) not marked WacIsDecimal is taken to be a
+// decimal rate and multiplied by 100 (0.045 becomes 4.5). It reports whether
+// the coupon was changed. A non-positive threshold disables the correction.
+// This is for ingestion only; the engine always reads Wac as percentage
+// points.
+func (l *LoanInfo) NormalizeFractionalWac(threshold float64) bool {
+	if threshold <= 0 || l.WacIsDecimal || l.Wac <= 0 || l.Wac >= threshold {
+		return false
+	}
+	l.Wac *= 100
+	return true
+}
+
+// rowParser reads typed fields from a row, keeping the first error so the
+// caller can parse every field and check once
+type rowParser struct {
+	row map[string]string
+	err error
+}
+
+func (p *rowParser) fail(field, reason string) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%s: %s", field, reason)
+	}
+}
+
+// value returns the trimmed cell, or "" when the column is absent
+func (p *rowParser) value(field string) string {
+	return strings.TrimSpace(p.row[field])
+}
+
+func (p *rowParser) required(field string) string {
+	v := p.value(field)
+	if v == "" {
+		p.fail(field, "required")
+	}
+	return v
+}
+
+func (p *rowParser) float(field string, required bool) float64 {
+	v := p.value(field)
+	if v == "" {
+		if required {
+			p.fail(field, "required")
+		}
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		p.fail(field, "not a number")
+	}
+	return f
+}
+
+func (p *rowParser) int(field string, required bool) int64 {
+	v := p.value(field)
+	if v == "" {
+		if required {
+			p.fail(field, "required")
+		}
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		p.fail(field, "not an integer")
+	}
+	return n
+}
+
+func (p *rowParser) bool(field string) bool {
+	v := p.value(field)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		p.fail(field, "not a boolean")
+	}
+	return b
+}
+
+// date accepts a calendar date (2024-01-31) or a full RFC 3339 timestamp
+func (p *rowParser) date(field string) time.Time {
+	v := p.value(field)
+	if v == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		p.fail(field, "not a date (want YYYY-MM-DD)")
+	}
+	return t
+}
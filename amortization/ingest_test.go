@@ -0,0 +1,97 @@
+package amortization
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoanInfoFromMap_Valid(t *testing.T) {
+	loan, err := LoanInfoFromMap(map[string]string{
+		"id":               "ROW1",
+		"wam":              "360",
+		"wac":              " 6.5 ",
+		"face":             "250000",
+		"prepay_cpr":       "0.06",
+		"day_count":        "ACT/365",
+		"origination_date": "2024-01-15",
+		"wac_is_decimal":   "",
+		"servicer":         "ignored",
+	})
+	if err != nil {
+		t.Fatalf("Expected row to parse, got %v", err)
+	}
+
+	if loan.ID != "ROW1" || loan.Wam != 360 || loan.Wac != 6.5 || loan.Face != 250000 {
+		t.Errorf("Unexpected core fields: %+v", loan)
+	}
+	if loan.PrepayCPR != 0.06 || loan.DayCount != "ACT/365" {
+		t.Errorf("Unexpected optional fields: %+v", loan)
+	}
+	if want := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC); !loan.OriginationDate.Equal(want) {
+		t.Errorf("Expected origination %s, got %s", want, loan.OriginationDate)
+	}
+	if err := loan.Validate(); err != nil {
+		t.Errorf("Expected parsed loan to validate, got %v", err)
+	}
+}
+
+func TestLoanInfoFromMap_Errors(t *testing.T) {
+	valid := func() map[string]string {
+		return map[string]string{"id": "ROW1", "wam": "360", "wac": "6.5", "face": "250000"}
+	}
+
+	testCases := []struct {
+		name    string
+		mutate  func(row map[string]string)
+		wantErr string
+	}{
+		{name: "missing id", mutate: func(row map[string]string) { delete(row, "id") }, wantErr: "id: required"},
+		{name: "missing face", mutate: func(row map[string]string) { row["face"] = "" }, wantErr: "face: required"},
+		{name: "unparseable wac", mutate: func(row map[string]string) { row["wac"] = "6.5%" }, wantErr: "wac: not a number"},
+		{name: "fractional wam", mutate: func(row map[string]string) { row["wam"] = "360.5" }, wantErr: "wam: not an integer"},
+		{name: "bad boolean", mutate: func(row map[string]string) { row["wac_is_decimal"] = "maybe" }, wantErr: "wac_is_decimal: not a boolean"},
+		{name: "bad date", mutate: func(row map[string]string) { row["origination_date"] = "01/15/2024" }, wantErr: "origination_date: not a date (want YYYY-MM-DD)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			row := valid()
+			tc.mutate(row)
+			_, err := LoanInfoFromMap(row)
+			if err == nil {
+				t.Fatalf("Expected error %q, got nil", tc.wantErr)
+			}
+			if err.Error() != tc.wantErr {
+				t.Errorf("Expected error %q, got %q", tc.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestNormalizeFractionalWac_MixedRows(t *testing.T) {
+	rows := []map[string]string{
+		{"id": "PCT", "wam": "360", "wac": "4.5", "face": "250000"},
+		{"id": "FRAC", "wam": "360", "wac": "0.045", "face": "250000"},
+		{"id": "FLAGGED", "wam": "360", "wac": "0.045", "face": "250000", "wac_is_decimal": "true"},
+	}
+
+	wantChanged := map[string]bool{"PCT": false, "FRAC": true, "FLAGGED": false}
+	for _, row := range rows {
+		loan, err := LoanInfoFromMap(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed := loan.NormalizeFractionalWac(1.0); changed != wantChanged[loan.ID] {
+			t.Errorf("%s: expected changed %v, got %v", loan.ID, wantChanged[loan.ID], changed)
+		}
+		// The flagged row is already read as a decimal by the engine
+		if loan.CouponPct() != 4.5 {
+			t.Errorf("%s: expected a 4.5%% coupon, got %v", loan.ID, loan.CouponPct())
+		}
+	}
+
+	disabled := LoanInfo{ID: "OFF", Wac: 0.045}
+	if disabled.NormalizeFractionalWac(0) || disabled.Wac != 0.045 {
+		t.Errorf("Expected a zero threshold to leave the coupon alone, got %v", disabled.Wac)
+	}
+}
@@ -0,0 +1,276 @@
+package amortization
+
+import "math"
+
+// AmortizationIterator returns a function that yields the loan's
+// amortization table one period at a time, reporting false once the term is
+// exhausted. Rows match GetAmortizationTable's Rows exactly, including the
+// cent true-up of the balances, but no column is materialized, so memory
+// stays constant however long the term. Like GetAmortizationTable, the
+// iterator states the loan's Face as its current face and records each
+// period's SMM in SMMArr as it goes.
+func (l *LoanInfo) AmortizationIterator() func() (PeriodRow, bool) {
+	return l.newAmortizer().next
+}
+
+// amortizer carries the engine's state from one period to the next. Both the
+// float and the integer-cents engines step through it; GetAmortizationTable
+// collects its rows into columns.
+type amortizer struct {
+	l          *LoanInfo
+	j          int
+	numPeriods int
+
+	monthlyRate    float64
+	monthlyPayment float64
+	initialPayment float64
+	prepayModel    PrepayModel
+	smmCap         float64
+	threshold      float64
+	mdr            float64 // Share of the scheduled balance defaulting each period
+	coupons        []float64
+	periodRate     func(j int) float64
+
+	// Float engine: the unrounded running balance, and the trued-up balance
+	// the emitted rows reconcile to
+	balance    float64
+	trueBal    float64
+	paidOff    bool
+	trueUpUsed bool
+
+	// Integer-cents engine: the running balance and level payment in whole
+	// cents
+	cents        int64
+	centsPayment int64
+
+	negAmClamped  bool
+	smmCapped     []int
+	penaltyPct    float64
+	penaltyMonths int64
+}
+
+// newAmortizer resolves the loan's rates, payment and prepayment model ahead
+// of the first period
+func (l *LoanInfo) newAmortizer() *amortizer {
+	a := &amortizer{
+		l:           l,
+		numPeriods:  l.numPeriods(),
+		monthlyRate: l.periodicRate(),
+		// Resolve the prepayment model once; it is consulted each period and
+		// the resulting SMMs are recorded on the loan
+		prepayModel: l.prepayModel(),
+		smmCap:      l.smmCap(),
+		threshold:   l.payoffThreshold(),
+	}
+	if l.hasDefaults() {
+		a.mdr = l.perPeriodRate(l.monthlyDefaultRate())
+	}
+	l.SMMArr = make([]float64, a.numPeriods)
+
+	// Seasoned loans state their balance as original face times factor
+	l.Face = l.CurrentFace()
+
+	// Dated loans accrue each period over its actual days, and a stub first
+	// period over the days from origination to the first payment. Adjustable
+	// loans accrue at each period's capped coupon.
+	a.coupons = l.couponSchedule()
+	a.periodRate = l.periodRater(a.coupons)
+
+	if l.PrepayPenaltyPct != 0 && l.PrepayPenaltyMonths != 0 {
+		a.penaltyPct = l.PrepayPenaltyPct
+		a.penaltyMonths = l.periodsIn(l.PrepayPenaltyMonths)
+	}
+
+	if l.IntegerCents {
+		a.cents = toCents(l.Face)
+		a.centsPayment = toCents(l.levelPayment(fromCents(a.cents)))
+		a.initialPayment = fromCents(a.centsPayment)
+		return a
+	}
+
+	a.monthlyPayment = l.levelPayment(l.Face)
+	a.initialPayment = roundToCent(a.monthlyPayment)
+	a.balance = l.Face
+	a.trueBal = roundToCent(l.Face)
+	return a
+}
+
+// next returns the following period's row, or false once the term is done
+func (a *amortizer) next() (PeriodRow, bool) {
+	if a.j >= a.numPeriods {
+		return PeriodRow{}, false
+	}
+
+	var row PeriodRow
+	if a.l.IntegerCents {
+		row = a.stepCents()
+	} else {
+		row = a.stepFloat()
+	}
+	a.j++
+
+	row.Period = a.j
+	row.Payment = roundToCent(row.Interest + row.Principal)
+	if int64(a.j) <= a.penaltyMonths {
+		row.Penalty = roundToCent(a.penaltyPct * row.PrepayAmount)
+	}
+	if a.l.OrigFace > 0 {
+		row.Factor = row.EndBal / a.l.OrigFace
+	}
+	if !a.l.OriginationDate.IsZero() {
+		_, row.PaymentDate = a.l.accrualPeriod(a.j - 1)
+	}
+	if a.l.MonthlyEscrow > 0 {
+		if row.BegBal > 0 {
+			row.Escrow = roundToCent(a.l.escrowPerPeriod())
+		}
+		row.TotalPayment = roundToCent(row.Payment + row.Escrow)
+	}
+	return row, true
+}
+
+// prepaySMM consults the prepayment model for the period, applying the SMM
+// cap and recording the result on the loan
+func (a *amortizer) prepaySMM(scheduled float64) float64 {
+	j := a.j
+	a.l.SMMArr[j] = a.l.perPeriodRate(a.prepayModel.SMM(a.l.monthOf(j), scheduled))
+	if a.l.SMMArr[j] > a.smmCap {
+		a.l.SMMArr[j] = a.smmCap
+		a.smmCapped = append(a.smmCapped, j+1)
+	}
+	return a.l.SMMArr[j]
+}
+
+// stepFloat runs one period of the float engine and trues the rounded row up
+// to the balance carried from the previous row, as TrueUpBalances does for a
+// whole table
+func (a *amortizer) stepFloat() PeriodRow {
+	j := a.j
+	i := a.numPeriods - j // Remaining periods
+
+	// A rate reset re-amortizes the balance over the remaining term
+	if resetsAt(a.coupons, j) {
+		a.monthlyPayment = calculateMonthlyPayment(a.balance, a.l.monthlyRate(a.coupons[j]), float64(i))
+	}
+
+	// 🟢 FAST: Simple multiplication instead of expensive PPmt
+	interestPayment := a.balance * a.periodRate(j)
+
+	// Calculate principal using standard formula
+	var principalPayment float64
+	if i == 1 {
+		// Final payment: all remaining balance. Any cent residual left by
+		// rounding earlier periods is absorbed by the true-up below.
+		principalPayment = a.balance
+	} else {
+		principalPayment = a.monthlyPayment - interestPayment
+	}
+	// Prepayments can retire the balance before maturity; never pay more than is owed
+	if principalPayment > a.balance {
+		principalPayment = a.balance
+	}
+	// A period accruing more interest than the payment (a long stub, a
+	// reset) pays no principal rather than growing the balance
+	if principalPayment < 0 {
+		principalPayment = 0
+		a.negAmClamped = true
+	}
+
+	currentSchedBal := a.balance - principalPayment
+	// Defaults come off the scheduled balance ahead of prepayment
+	defaultAmount := a.mdr * currentSchedBal
+	performing := currentSchedBal - defaultAmount
+	prepayAmount := a.prepaySMM(performing) * performing
+
+	// Update remaining balance
+	a.balance = performing - prepayAmount
+	if a.balance < 0.0 {
+		a.balance = 0.0
+	}
+
+	row := PeriodRow{
+		BegBal:       a.trueBal,
+		Interest:     roundToCent(interestPayment),
+		Principal:    roundToCent(principalPayment),
+		PrepayAmount: roundToCent(prepayAmount),
+		Default:      roundToCent(defaultAmount),
+	}
+	rawPrincipal := row.Principal
+
+	switch {
+	case a.paidOff:
+		row.Principal = 0.0
+		row.PrepayAmount = 0.0
+		row.Default = 0.0
+	case roundToCent(a.balance) < a.threshold:
+		// Payoff period: retire exactly what remains, folding any residual
+		// under the payoff threshold into principal
+		row.PrepayAmount = math.Min(row.PrepayAmount, a.trueBal)
+		row.Default = math.Min(row.Default, roundToCent(a.trueBal-row.PrepayAmount))
+		row.Principal = roundToCent(a.trueBal - row.PrepayAmount - row.Default)
+		a.balance = 0.0
+		a.paidOff = true
+	default:
+		row.Principal = math.Min(row.Principal, a.trueBal)
+		row.Default = math.Min(row.Default, roundToCent(a.trueBal-row.Principal))
+		row.PrepayAmount = math.Min(row.PrepayAmount, roundToCent(a.trueBal-row.Principal-row.Default))
+	}
+	if row.Principal != rawPrincipal {
+		a.trueUpUsed = true
+	}
+	row.Loss = roundToCent(a.l.severityAt(j+1) * row.Default)
+
+	row.SchedBal = roundToCent(a.trueBal - row.Principal)
+	a.trueBal = roundToCent(row.SchedBal - row.Default - row.PrepayAmount)
+	row.EndBal = a.trueBal
+	return row
+}
+
+// table collects the remaining rows into a materialized amortization table
+func (a *amortizer) table() AmortizationTable {
+	n := a.numPeriods - a.j
+	amortTable := AmortizationTable{
+		Period:          make([]int, 0, n),
+		BegBal:          make([]float64, 0, n),
+		SchedBal:        make([]float64, 0, n),
+		PrepayAmountArr: make([]float64, 0, n),
+		Interest:        make([]float64, 0, n),
+		Principal:       make([]float64, 0, n),
+		EndBal:          make([]float64, 0, n),
+		DelinqArrays:    DelinqArrays{},
+	}
+	if a.l.hasDefaults() {
+		amortTable.DefaultAmountArr = make(Amounts, 0, n)
+		amortTable.LossArr = make(Amounts, 0, n)
+	}
+	for row, ok := a.next(); ok; row, ok = a.next() {
+		amortTable.Period = append(amortTable.Period, row.Period)
+		amortTable.BegBal = append(amortTable.BegBal, row.BegBal)
+		amortTable.SchedBal = append(amortTable.SchedBal, row.SchedBal)
+		amortTable.PrepayAmountArr = append(amortTable.PrepayAmountArr, row.PrepayAmount)
+		amortTable.Interest = append(amortTable.Interest, row.Interest)
+		amortTable.Principal = append(amortTable.Principal, row.Principal)
+		amortTable.EndBal = append(amortTable.EndBal, row.EndBal)
+		if amortTable.DefaultAmountArr != nil {
+			amortTable.DefaultAmountArr = append(amortTable.DefaultAmountArr, row.Default)
+			amortTable.LossArr = append(amortTable.LossArr, row.Loss)
+		}
+	}
+	amortTable.SMMCapped = a.smmCapped
+	amortTable.Diagnostics = a.diagnostics()
+	a.l.completeTable(&amortTable)
+
+	return amortTable
+}
+
+// diagnostics reports the assumptions the engine resolved, once the rows
+// have been generated
+func (a *amortizer) diagnostics() Diagnostics {
+	return Diagnostics{
+		MonthlyRate:    a.monthlyRate,
+		MonthlyPayment: a.initialPayment,
+		PrepayModel:    prepayModelName(a.prepayModel),
+		NegAmClamped:   a.negAmClamped,
+		TrueUpAdjusted: a.trueUpUsed,
+	}
+}
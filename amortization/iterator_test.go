@@ -0,0 +1,94 @@
+package amortization
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAmortizationIterator_MatchesTable(t *testing.T) {
+	testCases := []struct {
+		name string
+		loan LoanInfo
+	}{
+		{name: "level", loan: LoanInfo{ID: "LEVEL", Wam: 360, Wac: 4.5, Face: 250000.0}},
+		{name: "zero coupon residual", loan: LoanInfo{ID: "ZERO", Wam: 7, Wac: 0.0, Face: 123456.78}},
+		{name: "prepaid early", loan: LoanInfo{ID: "FAST", Wam: 360, Wac: 6.0, Face: 100000.0, PrepayInfo: PrepayInfo{SMMArr: fullPayoffAt(360, 24), PrepayCPR: -1}}},
+		{name: "penalty", loan: LoanInfo{ID: "PEN", Wam: 120, Wac: 5.0, Face: 150000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.10, PrepayPenaltyPct: 0.02, PrepayPenaltyMonths: 12}}},
+		{name: "escrow", loan: LoanInfo{ID: "ESC", Wam: 120, Wac: 4.0, Face: 90000.0, MonthlyEscrow: 312.45, PrepayInfo: PrepayInfo{PrepayCPR: 0.2}}},
+		{name: "seasoned", loan: LoanInfo{ID: "SEAS", Wam: 300, Wac: 5.75, OrigFace: 400000.0, Factor: 0.8}},
+		{name: "adjustable", loan: LoanInfo{ID: "ARM", Wam: 360, Wac: 5.5, Face: 275000.0, ARMInfo: ARMInfo{RateResets: []RateReset{{Period: 61, Rate: 8.25}}, PeriodicCap: 2.0}}},
+		{name: "stub", loan: LoanInfo{ID: "STUB", Wam: 60, Wac: 7.0, Face: 50000.0, OriginationDate: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), FirstPaymentDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}},
+		{name: "integer cents", loan: LoanInfo{ID: "CENTS", Wam: 360, Wac: 6.875, Face: 333333.33, IntegerCents: true, PrepayInfo: PrepayInfo{PrepayCPR: 0.08}}},
+		{name: "payoff threshold", loan: LoanInfo{ID: "THRESH", Wam: 360, Wac: 6.0, Face: 180000.0, PayoffThreshold: 500.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.12}}},
+		{name: "no periods", loan: LoanInfo{ID: "EMPTY", Wam: 0, Wac: 5.0, Face: 1000.0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tableLoan := tc.loan
+			iterLoan := tc.loan
+			table := tableLoan.GetAmortizationTable()
+			want := table.Rows()
+
+			next := iterLoan.AmortizationIterator()
+			var got []PeriodRow
+			for row, ok := next(); ok; row, ok = next() {
+				got = append(got, row)
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("Expected %d rows, got %d", len(want), len(got))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("Period %d: iterator row %+v, table row %+v", i+1, got[i], want[i])
+				}
+			}
+			if !reflect.DeepEqual(iterLoan.SMMArr, tableLoan.SMMArr) {
+				t.Error("Expected the iterator to record the same SMMs on the loan as the table")
+			}
+
+			if _, ok := next(); ok {
+				t.Error("Expected an exhausted iterator to keep reporting false")
+			}
+		})
+	}
+}
+
+func TestGetAmortizationTable_TermOutOfRange(t *testing.T) {
+	// Terms Validate rejects, including one that overflows a 32-bit int,
+	// generate no periods instead of sizing columns from the term
+	for _, wam := range []int64{-1, maxWam + 1, math.MaxInt32 + 1, math.MaxInt64} {
+		loan := LoanInfo{ID: "HUGE", Wam: wam, Wac: 5.0, Face: 100000.0, ARMInfo: ARMInfo{RateResets: []RateReset{{Period: 61, Rate: 6.0}}}}
+		if err := loan.Validate(); err == nil {
+			t.Errorf("WAM %d: expected Validate to reject the term", wam)
+		}
+
+		table := loan.GetAmortizationTable()
+		if len(table.Period) != 0 || len(loan.SMMArr) != 0 {
+			t.Errorf("WAM %d: expected no periods, got %d", wam, len(table.Period))
+		}
+		if _, ok := loan.AmortizationIterator()(); ok {
+			t.Errorf("WAM %d: expected the iterator to yield no rows", wam)
+		}
+	}
+}
+
+// fullPayoffAt returns an SMM vector that prepays the whole balance in period
+func fullPayoffAt(periods, period int) []float64 {
+	smm := make([]float64, periods)
+	smm[period-1] = 1.0
+	return smm
+}
+
+func BenchmarkAmortizationIterator(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		loan := LoanInfo{ID: "BENCH", Wam: 360, Wac: 5.0, Face: 300000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.06}}
+		next := loan.AmortizationIterator()
+		for _, ok := next(); ok; _, ok = next() {
+		}
+	}
+}
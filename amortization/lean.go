@@ -0,0 +1,130 @@
+package amortization
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Lean wraps a table, a loan, or a slice of them so that it marshals to JSON
+// without its empty arrays and zero-value fields, such as the delinquency
+// arrays of a loan with no delinquency model. Fields keep their declaration
+// order. The unwrapped value still marshals in full, with a fixed shape, for
+// consumers that rely on every field being present.
+type Lean struct {
+	V interface{}
+}
+
+// MarshalJSON writes the wrapped value with its empty fields left out
+func (l Lean) MarshalJSON() ([]byte, error) {
+	return marshalLean(reflect.ValueOf(l.V))
+}
+
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// marshalLean encodes v, dropping empty members of every struct reached
+// through pointers and slices. Types with their own MarshalJSON, such as the
+// decimal columns and dates, are encoded as they are.
+func marshalLean(v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return []byte("null"), nil
+	}
+	if v.Type().Implements(marshalerType) {
+		return json.Marshal(v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return []byte("null"), nil
+		}
+		return marshalLean(v.Elem())
+	case reflect.Struct:
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		if err := appendLeanFields(&buf, v); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('}')
+		return buf.Bytes(), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []byte("null"), nil
+		}
+		if k := v.Type().Elem().Kind(); k != reflect.Struct && k != reflect.Pointer && k != reflect.Interface {
+			return json.Marshal(v.Interface())
+		}
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			elem, err := marshalLean(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(elem)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	default:
+		return json.Marshal(v.Interface())
+	}
+}
+
+// appendLeanFields writes the non-empty fields of the struct v as object
+// members, promoting the fields of untagged embedded structs as encoding/json
+// does
+func appendLeanFields(buf *bytes.Buffer, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			if err := appendLeanFields(buf, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() || isEmptyValue(v.Field(i)) {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		encoded, err := marshalLean(v.Field(i))
+		if err != nil {
+			return err
+		}
+		// A nested struct whose fields were all empty is empty too
+		if string(encoded) == "{}" {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(encoded)
+	}
+	return nil
+}
+
+// isEmptyValue reports whether v is a zero value or an empty slice or map
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
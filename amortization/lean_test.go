@@ -0,0 +1,81 @@
+package amortization
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLean_SmallerThanFullWithoutDelinquency(t *testing.T) {
+	loan := LoanInfo{ID: "LEAN", Wam: 360, Wac: 5.0, Face: 250000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.06}}
+	table := loan.GetAmortizationTable()
+
+	full, err := json.Marshal(&table)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	lean, err := json.Marshal(Lean{V: &table})
+	if err != nil {
+		t.Fatalf("Marshal of lean table failed: %v", err)
+	}
+	if len(lean) >= len(full) {
+		t.Errorf("Expected lean output smaller than full, got %d and %d bytes", len(lean), len(full))
+	}
+
+	var fullKeys, leanKeys map[string]json.RawMessage
+	if err := json.Unmarshal(full, &fullKeys); err != nil {
+		t.Fatalf("Full output is not valid JSON: %v", err)
+	}
+	if err := json.Unmarshal(lean, &leanKeys); err != nil {
+		t.Fatalf("Lean output is not valid JSON: %v", err)
+	}
+	if _, ok := fullKeys["delinq_arrays"]; !ok {
+		t.Error("Expected full output to keep the empty delinquency arrays")
+	}
+	if _, ok := leanKeys["delinq_arrays"]; ok {
+		t.Error("Expected lean output to drop the empty delinquency arrays")
+	}
+	for _, key := range []string{"period", "interest", "end_bal", "effective_maturity"} {
+		if string(leanKeys[key]) != string(fullKeys[key]) {
+			t.Errorf("Expected lean %s to match full output, got %.40s", key, leanKeys[key])
+		}
+	}
+	if diagnostics := string(leanKeys["diagnostics"]); strings.Contains(diagnostics, "neg_am_clamped") || !strings.Contains(diagnostics, "monthly_payment") {
+		t.Errorf("Expected lean diagnostics to drop only the false flags, got %s", diagnostics)
+	}
+
+	// Lean output decodes back to the same cashflows
+	var decoded AmortizationTable
+	if err := json.Unmarshal(lean, &decoded); err != nil {
+		t.Fatalf("Lean output does not decode as a table: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.EndBal, table.EndBal) || !reflect.DeepEqual(decoded.PrepayAmountArr, table.PrepayAmountArr) {
+		t.Error("Expected lean output to decode to the same balances and prepayments")
+	}
+}
+
+func TestLean_LoansKeepOrderAndPromoteEmbeddedFields(t *testing.T) {
+	loans := []LoanInfo{
+		{ID: "A", Wam: 360, Wac: 5.0, Face: 100000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.06}, OriginationDate: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{ID: "B", Wam: 120, Wac: 4.0, Face: 50000.0},
+	}
+
+	lean, err := json.Marshal(Lean{V: loans})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `[{"id":"A","wam":360,"wac":5,"face":100000,"origination_date":"2024-01-15T00:00:00Z","prepay_cpr":0.06},` +
+		`{"id":"B","wam":120,"wac":4,"face":50000}]`
+	if string(lean) != want {
+		t.Errorf("Unexpected lean loans\n got: %s\nwant: %s", lean, want)
+	}
+	if strings.Contains(string(lean), "static_dq") {
+		t.Error("Expected the zero StaticDQ flag to be dropped")
+	}
+
+	if got, _ := json.Marshal(Lean{}); string(got) != "null" {
+		t.Errorf("Expected an empty wrapper to marshal as null, got %s", got)
+	}
+}
@@ -0,0 +1,42 @@
+package amortization
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// markdownHeader is the header and alignment rows of WriteMarkdown's table
+const markdownHeader = "| Period | Beg Bal | Interest | Principal | Prepay | End Bal |\n" +
+	"| ---: | ---: | ---: | ---: | ---: | ---: |\n"
+
+// WriteMarkdown writes the table as a GitHub-flavored Markdown table for
+// human review. A schedule longer than twice maxRows shows only its first and
+// last maxRows periods, with an elision row marking the periods left out; a
+// maxRows of zero or less writes every period.
+func (a *AmortizationTable) WriteMarkdown(w io.Writer, maxRows int) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(markdownHeader)
+
+	rows := a.Rows()
+	head, tail := rows, []PeriodRow(nil)
+	if maxRows > 0 && len(rows) > 2*maxRows {
+		head, tail = rows[:maxRows], rows[len(rows)-maxRows:]
+	}
+	for _, row := range head {
+		writeMarkdownRow(bw, row)
+	}
+	if tail != nil {
+		fmt.Fprintf(bw, "| … | %d periods omitted | | | | |\n", len(rows)-2*maxRows)
+		for _, row := range tail {
+			writeMarkdownRow(bw, row)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeMarkdownRow writes one period as a Markdown table row
+func writeMarkdownRow(w io.Writer, row PeriodRow) {
+	fmt.Fprintf(w, "| %d | %.2f | %.2f | %.2f | %.2f | %.2f |\n",
+		row.Period, row.BegBal, row.Interest, row.Principal, row.PrepayAmount, row.EndBal)
+}
@@ -0,0 +1,62 @@
+package amortization
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdown_ElidesLongSchedules(t *testing.T) {
+	loan := LoanInfo{ID: "MD", Wam: 360, Wac: 6.0, Face: 200000.0}
+	table := loan.GetAmortizationTable()
+
+	var buf bytes.Buffer
+	if err := table.WriteMarkdown(&buf, 5); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	if lines[0] != "| Period | Beg Bal | Interest | Principal | Prepay | End Bal |" {
+		t.Errorf("Unexpected header row %q", lines[0])
+	}
+	if lines[1] != "| ---: | ---: | ---: | ---: | ---: | ---: |" {
+		t.Errorf("Unexpected alignment row %q", lines[1])
+	}
+	// Header, alignment, five leading rows, the elision and five trailing rows
+	if len(lines) != 13 {
+		t.Fatalf("Expected 13 lines, got %d:\n%s", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[2], "| 1 | 200000.00 | 1000.00 |") {
+		t.Errorf("Unexpected first period row %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[6], "| 5 |") {
+		t.Errorf("Expected period 5 before the elision, got %q", lines[6])
+	}
+	if lines[7] != "| … | 350 periods omitted | | | | |" {
+		t.Errorf("Unexpected elision row %q", lines[7])
+	}
+	if !strings.HasPrefix(lines[8], "| 356 |") || !strings.HasPrefix(lines[12], "| 360 |") {
+		t.Errorf("Expected periods 356 to 360 after the elision, got %q and %q", lines[8], lines[12])
+	}
+	if !strings.HasSuffix(lines[12], "| 0.00 |") {
+		t.Errorf("Expected the final period to end at a zero balance, got %q", lines[12])
+	}
+}
+
+func TestWriteMarkdown_ShortSchedulesInFull(t *testing.T) {
+	loan := LoanInfo{ID: "MD-SHORT", Wam: 10, Wac: 5.0, Face: 10000.0}
+	table := loan.GetAmortizationTable()
+
+	for _, maxRows := range []int{0, 5, 20} {
+		var buf bytes.Buffer
+		if err := table.WriteMarkdown(&buf, maxRows); err != nil {
+			t.Fatalf("WriteMarkdown failed: %v", err)
+		}
+		if strings.Contains(buf.String(), "omitted") {
+			t.Errorf("maxRows %d: expected no elision for 10 periods", maxRows)
+		}
+		if got := strings.Count(buf.String(), "\n"); got != 12 {
+			t.Errorf("maxRows %d: expected 12 lines, got %d", maxRows, got)
+		}
+	}
+}
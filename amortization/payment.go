@@ -0,0 +1,56 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxPaymentTerm bounds the term found by GetAmortizationFromPayment, matching
+// the 40-year WAM limit enforced by Validate
+const maxPaymentTerm = maxWam
+
+// GetAmortizationFromPayment amortizes face at the annual coupon wac (in
+// percentage points) with a fixed monthly payment, running until the balance
+// is retired. The term is whatever the payment implies; the final period pays
+// only what remains. It errors when the payment does not cover the first
+// period's interest, since the loan would never amortize, or when payoff would
+// take longer than 480 months.
+func GetAmortizationFromPayment(face, wac, payment float64) (AmortizationTable, error) {
+	if face <= 0 {
+		return AmortizationTable{}, fmt.Errorf("face value must be positive, got %f", face)
+	}
+	if wac < 0 || wac > 30 {
+		return AmortizationTable{}, fmt.Errorf("WAC must be between 0 and 30 percent, got %f", wac)
+	}
+
+	monthlyRate := wac / 12.0 / 100.0
+	balance := roundToCent(face)
+	if firstInterest := roundToCent(balance * monthlyRate); payment <= firstInterest {
+		return AmortizationTable{}, fmt.Errorf("payment %.2f does not cover first-period interest %.2f", payment, firstInterest)
+	}
+
+	var table AmortizationTable
+	for period := 1; balance > 0; period++ {
+		if period > maxPaymentTerm {
+			return AmortizationTable{}, fmt.Errorf("payment %.2f does not retire the balance within %d months", payment, maxPaymentTerm)
+		}
+
+		interest := roundToCent(balance * monthlyRate)
+		principal := math.Min(roundToCent(payment-interest), balance)
+		endBal := roundToCent(balance - principal)
+
+		table.Period = append(table.Period, period)
+		table.BegBal = append(table.BegBal, balance)
+		table.Interest = append(table.Interest, interest)
+		table.Principal = append(table.Principal, principal)
+		table.SchedBal = append(table.SchedBal, endBal)
+		table.PrepayAmountArr = append(table.PrepayAmountArr, 0.0)
+		table.EndBal = append(table.EndBal, endBal)
+
+		balance = endBal
+	}
+	table.Payment = paymentsOf(table.Interest, table.Principal)
+	table.Diagnostics = Diagnostics{MonthlyRate: monthlyRate, MonthlyPayment: payment}
+
+	return table, nil
+}
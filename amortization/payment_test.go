@@ -0,0 +1,71 @@
+package amortization
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetAmortizationFromPayment_PaysOffEarly(t *testing.T) {
+	// The level payment for 250,000 at 6% over 360 months is about 1,498.88
+	table, err := GetAmortizationFromPayment(250000.0, 6.0, 2000.0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	n := len(table.Period)
+	if n >= 360 {
+		t.Errorf("Expected payoff before 360 months with a larger payment, got %d", n)
+	}
+	if table.EndBal[n-1] != 0.0 {
+		t.Errorf("Expected zero final balance, got %.2f", table.EndBal[n-1])
+	}
+	for i := 0; i < n-1; i++ {
+		if paid := roundToCent(table.Interest[i] + table.Principal[i]); paid != 2000.0 {
+			t.Fatalf("Period %d: expected payment 2000.00, got %.2f", i+1, paid)
+		}
+	}
+	if last := roundToCent(table.Interest[n-1] + table.Principal[n-1]); last > 2000.0 {
+		t.Errorf("Expected final payment no larger than 2000.00, got %.2f", last)
+	}
+	if err := table.Check(); err != nil {
+		t.Errorf("Table failed check: %v", err)
+	}
+}
+
+func TestGetAmortizationFromPayment_MatchesLevelPayment(t *testing.T) {
+	loan := &LoanInfo{ID: "PMT001", Wam: 120, Wac: 5.0, Face: 100000.0}
+	payment := roundToCent(calculateMonthlyPayment(loan.Face, 5.0/1200.0, 120))
+
+	table, err := GetAmortizationFromPayment(loan.Face, loan.Wac, payment)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Rounding the payment up to the cent can retire the balance a month early
+	if n := len(table.Period); n < 119 || n > 120 {
+		t.Errorf("Expected about 120 periods, got %d", n)
+	}
+}
+
+func TestGetAmortizationFromPayment_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		face    float64
+		wac     float64
+		payment float64
+		errMsg  string
+	}{
+		{name: "interest only", face: 100000.0, wac: 6.0, payment: 500.0, errMsg: "does not cover first-period interest"},
+		{name: "negative amortization", face: 100000.0, wac: 6.0, payment: 400.0, errMsg: "does not cover first-period interest"},
+		{name: "too slow", face: 100000.0, wac: 6.0, payment: 500.5, errMsg: "within 480 months"},
+		{name: "zero face", face: 0.0, wac: 6.0, payment: 500.0, errMsg: "face value must be positive"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := GetAmortizationFromPayment(tc.face, tc.wac, tc.payment)
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Errorf("Expected error containing %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// PoolWAC returns the face-weighted average coupon of the loans, in
+// percentage points. An empty pool or one with zero total face returns 0.
+func PoolWAC(loans []LoanInfo) float64 {
+	totalFace := 0.0
+	weighted := 0.0
+	for _, loan := range loans {
+		totalFace += loan.CurrentFace()
+		weighted += loan.CurrentFace() * loan.CouponPct()
+	}
+	if totalFace == 0 {
+		return 0
+	}
+	return weighted / totalFace
+}
+
+// PoolWAM returns the face-weighted average remaining term of the loans, in
+// months. An empty pool or one with zero total face returns 0.
+func PoolWAM(loans []LoanInfo) float64 {
+	totalFace := 0.0
+	weighted := 0.0
+	for _, loan := range loans {
+		totalFace += loan.CurrentFace()
+		weighted += loan.CurrentFace() * float64(loan.Wam)
+	}
+	if totalFace == 0 {
+		return 0
+	}
+	return weighted / totalFace
+}
+
+// ScalePool scales every loan by the same ratio so the pool's current face
+// sums to targetFace, preserving each loan's share of the pool and the pool's
+// WAC and WAM. An empty pool or one with zero total face returns nil.
+func ScalePool(loans []LoanInfo, targetFace float64) []LoanInfo {
+	totalFace := 0.0
+	for _, loan := range loans {
+		totalFace += loan.CurrentFace()
+	}
+	if totalFace == 0 {
+		return nil
+	}
+
+	ratio := targetFace / totalFace
+	scaled := make([]LoanInfo, len(loans))
+	for i, loan := range loans {
+		scaled[i] = loan.ScaleTo(loan.CurrentFace() * ratio)
+	}
+	return scaled
+}
+
+// bandEpsilon keeps coupons that sit on a band boundary in the band they open
+// despite floating-point error in the division (e.g. 0.3 / 0.1)
+const bandEpsilon = 1e-9
+
+// BucketByWAC groups loans into coupon bands bandWidth percentage points wide,
+// keyed by labels such as "3.50-4.00". Bands include their lower bound and
+// exclude their upper bound, so a 4.00 coupon lands in "4.00-4.50". A
+// non-positive bandWidth returns nil.
+func BucketByWAC(loans []LoanInfo, bandWidth float64) map[string][]LoanInfo {
+	if bandWidth <= 0 {
+		return nil
+	}
+
+	buckets := make(map[string][]LoanInfo)
+	for _, loan := range loans {
+		band := math.Floor(loan.CouponPct()/bandWidth + bandEpsilon)
+		label := fmt.Sprintf("%.2f-%.2f", band*bandWidth, (band+1)*bandWidth)
+		buckets[label] = append(buckets[label], loan)
+	}
+	return buckets
+}
+
+// AggregateTables sums the tables period by period into a single pool table.
+// Tables of different lengths are aligned on their first period; the pool
+// runs as long as the longest table. Factors and SMM caps are per-loan and are
+// not aggregated.
+func AggregateTables(tables []AmortizationTable) AmortizationTable {
+	n := 0
+	for _, t := range tables {
+		n = max(n, len(t.Period))
+	}
+
+	acc := newPoolAccumulator(n)
+	for _, t := range tables {
+		acc.add(t)
+	}
+	return acc.result()
+}
+
+// AggregatePool amortizes the loans and sums their tables into a single pool
+// table, as AggregateTables does, without retaining the individual tables.
+// Tables are generated in parallel, each holding a slot of workers for as
+// long as it is amortized, and folded into the pool as soon as they are
+// generated, so peak memory is one table per slot rather than the whole
+// pool. The caller owns workers, such as the service's worker pool, so the
+// parallelism stays bounded across requests; a nil workers amortizes the
+// loans one after another. The loans themselves are not modified.
+func AggregatePool(loans []LoanInfo, workers chan struct{}) AmortizationTable {
+	n := 0
+	for i := range loans {
+		n = max(n, loans[i].numPeriods())
+	}
+	acc := newPoolAccumulator(n)
+	if workers == nil {
+		for i := range loans {
+			loan := loans[i]
+			acc.add(loan.GetAmortizationTable())
+		}
+		return acc.result()
+	}
+
+	var wg sync.WaitGroup
+	for i := range loans {
+		wg.Add(1)
+		go func(loan LoanInfo) {
+			workers <- struct{}{}
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			acc.add(loan.GetAmortizationTable())
+		}(loans[i])
+	}
+	wg.Wait()
+	return acc.result()
+}
+
+// poolAccumulator sums tables into pool columns as they arrive. It is safe
+// for concurrent use.
+type poolAccumulator struct {
+	mu    sync.Mutex
+	table AmortizationTable
+}
+
+func newPoolAccumulator(n int) *poolAccumulator {
+	acc := &poolAccumulator{table: AmortizationTable{
+		Period:          make([]int, n),
+		BegBal:          make([]float64, n),
+		Interest:        make([]float64, n),
+		Principal:       make([]float64, n),
+		SchedBal:        make([]float64, n),
+		PrepayAmountArr: make([]float64, n),
+		EndBal:          make([]float64, n),
+	}}
+	for i := range acc.table.Period {
+		acc.table.Period[i] = i + 1
+	}
+	return acc
+}
+
+// add folds t into the pool, period by period from the first
+func (acc *poolAccumulator) add(t AmortizationTable) {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	agg := &acc.table
+	for i := range t.Period {
+		agg.BegBal[i] = roundToCent(agg.BegBal[i] + t.BegBal[i])
+		agg.Interest[i] = roundToCent(agg.Interest[i] + t.Interest[i])
+		agg.Principal[i] = roundToCent(agg.Principal[i] + t.Principal[i])
+		agg.SchedBal[i] = roundToCent(agg.SchedBal[i] + t.SchedBal[i])
+		agg.PrepayAmountArr[i] = roundToCent(agg.PrepayAmountArr[i] + t.PrepayAmountArr[i])
+		agg.EndBal[i] = roundToCent(agg.EndBal[i] + t.EndBal[i])
+		if len(t.PenaltyArr) > i {
+			if agg.PenaltyArr == nil {
+				agg.PenaltyArr = make([]float64, len(agg.Period))
+			}
+			agg.PenaltyArr[i] = roundToCent(agg.PenaltyArr[i] + t.PenaltyArr[i])
+		}
+		if len(t.EscrowArr) > i {
+			if agg.EscrowArr == nil {
+				agg.EscrowArr = make([]float64, len(agg.Period))
+			}
+			agg.EscrowArr[i] = roundToCent(agg.EscrowArr[i] + t.EscrowArr[i])
+		}
+		if len(t.DefaultAmountArr) > i {
+			if agg.DefaultAmountArr == nil {
+				agg.DefaultAmountArr = make([]float64, len(agg.Period))
+				agg.LossArr = make([]float64, len(agg.Period))
+			}
+			agg.DefaultAmountArr[i] = roundToCent(agg.DefaultAmountArr[i] + t.DefaultAmountArr[i])
+			agg.LossArr[i] = roundToCent(agg.LossArr[i] + t.LossArr[i])
+		}
+	}
+}
+
+// result returns the pool table with its payment column filled in
+func (acc *poolAccumulator) result() AmortizationTable {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	acc.table.Payment = paymentsOf(acc.table.Interest, acc.table.Principal)
+	if acc.table.EscrowArr != nil {
+		acc.table.TotalPayment = paymentsOf(acc.table.Payment, acc.table.EscrowArr)
+	}
+	if acc.table.DefaultAmountArr != nil {
+		acc.table.CumulativeDefault = runningTotal(acc.table.DefaultAmountArr)
+		acc.table.CumulativeLoss = runningTotal(acc.table.LossArr)
+	}
+	return acc.table
+}
@@ -0,0 +1,244 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestPoolWACAndWAM(t *testing.T) {
+	loans := []LoanInfo{
+		{ID: "LOAN001", Wam: 360, Wac: 4.0, Face: 300000.0},
+		{ID: "LOAN002", Wam: 180, Wac: 6.0, Face: 100000.0},
+	}
+
+	// (300k * 4.0 + 100k * 6.0) / 400k = 4.5
+	if got := PoolWAC(loans); math.Abs(got-4.5) > 1e-9 {
+		t.Errorf("Expected pool WAC 4.5, got %f", got)
+	}
+
+	// (300k * 360 + 100k * 180) / 400k = 315
+	if got := PoolWAM(loans); math.Abs(got-315.0) > 1e-9 {
+		t.Errorf("Expected pool WAM 315, got %f", got)
+	}
+}
+
+func TestPoolWACAndWAM_Empty(t *testing.T) {
+	testCases := []struct {
+		name  string
+		loans []LoanInfo
+	}{
+		{name: "nil pool", loans: nil},
+		{name: "zero total face", loans: []LoanInfo{{ID: "LOAN001", Wam: 360, Wac: 4.0, Face: 0}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := PoolWAC(tc.loans); got != 0 {
+				t.Errorf("Expected pool WAC 0, got %f", got)
+			}
+			if got := PoolWAM(tc.loans); got != 0 {
+				t.Errorf("Expected pool WAM 0, got %f", got)
+			}
+		})
+	}
+}
+
+func TestBucketByWAC(t *testing.T) {
+	loans := []LoanInfo{
+		{ID: "LOAN001", Wam: 360, Wac: 3.25, Face: 100000.0},
+		{ID: "LOAN002", Wam: 360, Wac: 3.49, Face: 100000.0},
+		{ID: "LOAN003", Wam: 360, Wac: 3.5, Face: 100000.0},
+		{ID: "LOAN004", Wam: 360, Wac: 4.0, Face: 100000.0},
+		{ID: "LOAN005", Wam: 360, Wac: 0.0475, Face: 100000.0, WacIsDecimal: true},
+		{ID: "LOAN006", Wam: 360, Wac: 12.1, Face: 100000.0},
+	}
+
+	buckets := BucketByWAC(loans, 0.5)
+
+	expected := map[string][]string{
+		"3.00-3.50":   {"LOAN001", "LOAN002"},
+		"3.50-4.00":   {"LOAN003"},
+		"4.00-4.50":   {"LOAN004"},
+		"4.50-5.00":   {"LOAN005"},
+		"12.00-12.50": {"LOAN006"},
+	}
+	if len(buckets) != len(expected) {
+		t.Fatalf("Expected %d bands, got %d: %v", len(expected), len(buckets), buckets)
+	}
+	for label, ids := range expected {
+		got := buckets[label]
+		if len(got) != len(ids) {
+			t.Errorf("Band %s: expected %d loans, got %d", label, len(ids), len(got))
+			continue
+		}
+		for i, id := range ids {
+			if got[i].ID != id {
+				t.Errorf("Band %s: expected %s at %d, got %s", label, id, i, got[i].ID)
+			}
+		}
+	}
+}
+
+func TestBucketByWAC_BoundaryRounding(t *testing.T) {
+	// 0.3 / 0.1 evaluates just below 3 in floating point
+	buckets := BucketByWAC([]LoanInfo{{ID: "LOAN001", Wam: 12, Wac: 0.3, Face: 1000.0}}, 0.1)
+	if _, ok := buckets["0.30-0.40"]; !ok {
+		t.Errorf("Expected boundary coupon in band 0.30-0.40, got %v", buckets)
+	}
+
+	if buckets := BucketByWAC([]LoanInfo{{ID: "LOAN001"}}, 0); buckets != nil {
+		t.Errorf("Expected nil for zero band width, got %v", buckets)
+	}
+}
+
+func TestAggregateTables(t *testing.T) {
+	short := LoanInfo{ID: "SHORT", Wam: 12, Wac: 4.0, Face: 12000.0}
+	long := LoanInfo{ID: "LONG", Wam: 24, Wac: 6.0, Face: 24000.0}
+	a, b := short.GetAmortizationTable(), long.GetAmortizationTable()
+
+	pool := AggregateTables([]AmortizationTable{a, b})
+	if len(pool.Period) != 24 {
+		t.Fatalf("Expected the pool to run 24 periods, got %d", len(pool.Period))
+	}
+	if pool.BegBal[0] != 36000.0 {
+		t.Errorf("Expected pool beginning balance 36000, got %.2f", pool.BegBal[0])
+	}
+	if want := roundToCent(a.Interest[0] + b.Interest[0]); pool.Interest[0] != want {
+		t.Errorf("Expected first-period interest %.2f, got %.2f", want, pool.Interest[0])
+	}
+	if pool.Interest[12] != b.Interest[12] {
+		t.Errorf("Expected only the long loan after period 12, got %.2f", pool.Interest[12])
+	}
+	if pool.EndBal[23] != 0 {
+		t.Errorf("Expected the pool to pay off, got %.2f", pool.EndBal[23])
+	}
+}
+
+// benchmarkPool builds a pool of varied loans for the aggregation tests
+func benchmarkPool(size int) []LoanInfo {
+	loans := make([]LoanInfo, size)
+	for i := range loans {
+		loans[i] = LoanInfo{
+			ID:         fmt.Sprintf("LOAN%05d", i),
+			Wam:        int64(240 + (i%5)*30),
+			Wac:        3.0 + float64(i%40)*0.125,
+			Face:       100000.0 + float64(i%97)*2500.0,
+			PrepayInfo: PrepayInfo{PrepayCPR: float64(i%10) * 0.01, PrepayPenaltyPct: 0.01, PrepayPenaltyMonths: int64(i % 3 * 12)},
+		}
+	}
+	return loans
+}
+
+func TestAggregatePool_MatchesNaiveSum(t *testing.T) {
+	loans := benchmarkPool(500)
+
+	tables := make([]AmortizationTable, len(loans))
+	for i := range loans {
+		loan := loans[i]
+		tables[i] = loan.GetAmortizationTable()
+	}
+	want := AggregateTables(tables)
+
+	for _, workers := range []chan struct{}{nil, make(chan struct{}, 4)} {
+		got := AggregatePool(loans, workers)
+		if len(got.Period) != len(want.Period) {
+			t.Fatalf("workers %d: expected %d periods, got %d", cap(workers), len(want.Period), len(got.Period))
+		}
+		columns := map[string][2][]float64{
+			"beg_bal":   {want.BegBal, got.BegBal},
+			"interest":  {want.Interest, got.Interest},
+			"principal": {want.Principal, got.Principal},
+			"payment":   {want.Payment, got.Payment},
+			"prepay":    {want.PrepayAmountArr, got.PrepayAmountArr},
+			"end_bal":   {want.EndBal, got.EndBal},
+			"penalty":   {want.PenaltyArr, got.PenaltyArr},
+		}
+		for name, pair := range columns {
+			for i := range pair[0] {
+				if math.Abs(pair[0][i]-pair[1][i]) > 1e-6 {
+					t.Fatalf("workers %d: %s differs at period %d: want %.2f, got %.2f", cap(workers), name, i+1, pair[0][i], pair[1][i])
+				}
+			}
+		}
+		if len(workers) != 0 {
+			t.Errorf("Expected every worker slot released, %d still held", len(workers))
+		}
+	}
+
+	if loans[0].SMMArr != nil || loans[0].Face != 100000.0 {
+		t.Errorf("Expected AggregatePool to leave the loans unmodified")
+	}
+}
+
+// reportLiveHeap reports the heap still reachable once an iteration's
+// aggregate is built, i.e. what the approach holds onto at its peak
+func reportLiveHeap(b *testing.B, live ...any) {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	b.ReportMetric(float64(stats.HeapAlloc), "live-B")
+	runtime.KeepAlive(live)
+}
+
+func BenchmarkAggregateTables(b *testing.B) {
+	loans := benchmarkPool(10000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tables := make([]AmortizationTable, len(loans))
+		for j := range loans {
+			loan := loans[j]
+			tables[j] = loan.GetAmortizationTable()
+		}
+		pool := AggregateTables(tables)
+
+		b.StopTimer()
+		reportLiveHeap(b, tables, pool)
+		b.StartTimer()
+	}
+}
+
+func BenchmarkAggregatePool(b *testing.B) {
+	loans := benchmarkPool(10000)
+	workers := make(chan struct{}, runtime.GOMAXPROCS(0))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool := AggregatePool(loans, workers)
+
+		b.StopTimer()
+		reportLiveHeap(b, pool)
+		b.StartTimer()
+	}
+}
+
+func TestScalePool(t *testing.T) {
+	loans := []LoanInfo{
+		{ID: "LOAN001", Wam: 360, Wac: 4.0, Face: 300000.0},
+		{ID: "LOAN002", Wam: 180, Wac: 6.0, OrigFace: 125000.0, Factor: 0.8},
+	}
+
+	scaled := ScalePool(loans, 1000000.0)
+	if len(scaled) != 2 {
+		t.Fatalf("Expected 2 loans, got %d", len(scaled))
+	}
+
+	// 400k scales by 2.5 to 1mm: 750k and 250k
+	if got := scaled[0].CurrentFace(); math.Abs(got-750000.0) > 1e-6 {
+		t.Errorf("Expected LOAN001 face 750000, got %f", got)
+	}
+	if got := scaled[1].CurrentFace(); math.Abs(got-250000.0) > 1e-6 {
+		t.Errorf("Expected LOAN002 face 250000, got %f", got)
+	}
+	if math.Abs(PoolWAC(scaled)-PoolWAC(loans)) > 1e-9 || math.Abs(PoolWAM(scaled)-PoolWAM(loans)) > 1e-9 {
+		t.Error("Expected scaling to preserve the pool WAC and WAM")
+	}
+
+	if got := ScalePool([]LoanInfo{{ID: "LOAN003", Wam: 360, Wac: 4.0}}, 1000000.0); got != nil {
+		t.Errorf("Expected nil for a pool with zero face, got %v", got)
+	}
+}
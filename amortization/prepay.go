@@ -0,0 +1,239 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+)
+
+// PrepayModel supplies the single monthly mortality (SMM) applied to the
+// scheduled balance in each period. Implementations must be safe to call from
+// multiple goroutines.
+type PrepayModel interface {
+	// SMM returns the prepayment rate for period (1-based) given the
+	// scheduled balance after that period's amortization
+	SMM(period int, balance float64) float64
+}
+
+// FlatCPR prepays at a constant annual CPR in decimals
+type FlatCPR struct {
+	CPR float64
+}
+
+// SMM implements PrepayModel
+func (m FlatCPR) SMM(period int, balance float64) float64 {
+	return SMMFromCPR(m.CPR)
+}
+
+// PSA follows the PSA benchmark: CPR rises by 0.2% a month to 6% at month 30
+// and stays flat thereafter, scaled by Speed (100 = 100% PSA)
+type PSA struct {
+	Speed float64
+}
+
+// SMM implements PrepayModel
+func (m PSA) SMM(period int, balance float64) float64 {
+	return SMMFromCPR(psaCPR(m.Speed, period))
+}
+
+// psaCPR returns the annual CPR in decimals for period (1-based) at a PSA speed
+func psaCPR(speed float64, period int) float64 {
+	return 0.06 * math.Min(float64(period), 30.0) / 30.0 * speed / 100.0
+}
+
+// PSAToCPRVector returns the per-period annual CPR in decimals for wam periods
+// at psaMultiple percent of the PSA benchmark (100 = 100% PSA)
+func PSAToCPRVector(psaMultiple float64, wam int) []float64 {
+	cpr := make([]float64, wam)
+	for i := range cpr {
+		cpr[i] = psaCPR(psaMultiple, i+1)
+	}
+	return cpr
+}
+
+// CPRVectorToSMMVector converts each annual CPR in decimals to its single
+// monthly mortality
+func CPRVectorToSMMVector(cpr []float64) []float64 {
+	smm := make([]float64, len(cpr))
+	for i, c := range cpr {
+		smm[i] = SMMFromCPR(c)
+	}
+	return smm
+}
+
+// VectorCPR prepays at a per-period annual CPR in decimals. The last value
+// carries forward past the end of the vector.
+type VectorCPR struct {
+	CPR []float64
+}
+
+// SMM implements PrepayModel
+func (m VectorCPR) SMM(period int, balance float64) float64 {
+	if len(m.CPR) == 0 {
+		return 0.0
+	}
+	idx := min(period, len(m.CPR)) - 1
+	return SMMFromCPR(m.CPR[idx])
+}
+
+// CPRCurve adapts an annual CPR curve in decimals, such as one built with
+// CombineCPR, into a PrepayModel. CPRs outside [0, 1] are clamped.
+type CPRCurve func(period int) float64
+
+// SMM implements PrepayModel
+func (c CPRCurve) SMM(period int, balance float64) float64 {
+	return SMMFromCPR(math.Max(0, math.Min(1, c(period))))
+}
+
+// CombineCPR layers CPR curves into one that prepays, in each period, at the
+// highest CPR any of them gives. Each layer acts as a floor under the
+// others, so a PSA base combined with ConstantCPR(0.03) never prepays slower
+// than 3% CPR. Without models the combined curve is zero.
+func CombineCPR(models ...func(period int) float64) func(period int) float64 {
+	return func(period int) float64 {
+		cpr := 0.0
+		for _, model := range models {
+			cpr = math.Max(cpr, model(period))
+		}
+		return cpr
+	}
+}
+
+// ConstantCPR returns a curve at the same annual CPR in every period
+func ConstantCPR(cpr float64) func(period int) float64 {
+	return func(period int) float64 { return cpr }
+}
+
+// PSACPR returns the PSA benchmark curve at speed percent (100 = 100% PSA)
+func PSACPR(speed float64) func(period int) float64 {
+	return func(period int) float64 { return psaCPR(speed, period) }
+}
+
+// RampCPR seasons a curve: its CPR is scaled linearly from 1/months of its
+// value in the first period up to its full value from period months on. A
+// non-positive months leaves the curve unchanged.
+func RampCPR(model func(period int) float64, months int) func(period int) float64 {
+	if months <= 0 {
+		return model
+	}
+	return func(period int) float64 {
+		return model(period) * math.Min(float64(period)/float64(months), 1.0)
+	}
+}
+
+// smmVector applies a caller-supplied SMM array directly; periods beyond the
+// array do not prepay
+type smmVector []float64
+
+// SMM implements PrepayModel
+func (v smmVector) SMM(period int, balance float64) float64 {
+	if period > len(v) {
+		return 0.0
+	}
+	return v[period-1]
+}
+
+// prepayModel returns the model the engine consults each period. Without an
+// explicit PrepayModel the flat PrepayCPR is wrapped in a FlatCPR; a negative
+// CPR defers to the caller-supplied SMMArr.
+func (l *LoanInfo) prepayModel() PrepayModel {
+	if l.PrepayModel != nil {
+		return l.PrepayModel
+	}
+	if l.PrepayCPR >= 0.0 {
+		return FlatCPR{CPR: l.PrepayCPR}
+	}
+	return smmVector(l.SMMArr)
+}
+
+// ImpliedCPR backs out the annual CPR for each period from the observed
+// ending balances and the scheduled balances (after scheduled principal,
+// before prepayment): SMM = 1 - balance/scheduled, annualized as
+// 1 - (1-SMM)^12. Periods with a zero scheduled balance report 0, and SMMs
+// are clamped to [0, 1]. Extra entries in the longer slice are ignored.
+func ImpliedCPR(balances []float64, scheduledBalances []float64) []float64 {
+	n := min(len(balances), len(scheduledBalances))
+	cpr := make([]float64, n)
+	for i := 0; i < n; i++ {
+		if scheduledBalances[i] <= 0 {
+			continue
+		}
+		smm := 1 - balances[i]/scheduledBalances[i]
+		smm = math.Max(0, math.Min(1, smm))
+		cpr[i] = smmToCPR(smm)
+	}
+	return cpr
+}
+
+// SolveSMMVector runs the amortization in reverse: given the observed ending
+// balance of each period, it solves period by period for the SMM that
+// reproduces it on a level-payment loan of face, wac (percent) and wam. Each
+// period's scheduled balance is the prior target less that period's scheduled
+// principal, and SMM = 1 - target/scheduled. A target above its scheduled
+// balance by more than a cent, or below zero, cannot be reached by
+// prepayment and is an error; targets within a cent of the schedule solve to
+// zero. Periods after the balance reaches zero report 0.
+func SolveSMMVector(face, wac float64, wam int, targetEndBal []float64) ([]float64, error) {
+	if face <= 0 {
+		return nil, fmt.Errorf("face must be positive, got %f", face)
+	}
+	if wam <= 0 || wam > maxWam {
+		return nil, fmt.Errorf("WAM must be between 1 and %d, got %d", maxWam, wam)
+	}
+	if len(targetEndBal) > wam {
+		return nil, fmt.Errorf("got %d target balances for a %d-period loan", len(targetEndBal), wam)
+	}
+
+	rate := wac / 12.0 / 100.0
+	payment := calculateMonthlyPayment(face, rate, float64(wam))
+	smm := make([]float64, len(targetEndBal))
+	balance := face
+	for i, target := range targetEndBal {
+		if target < 0 {
+			return nil, fmt.Errorf("period %d: target balance cannot be negative, got %.2f", i+1, target)
+		}
+		principal := math.Max(0, math.Min(payment-balance*rate, balance))
+		if i == wam-1 {
+			principal = balance
+		}
+		scheduled := balance - principal
+		if target > scheduled+0.01 {
+			return nil, fmt.Errorf("period %d: target balance %.2f is above the scheduled balance %.2f",
+				i+1, target, scheduled)
+		}
+		if scheduled > 0 {
+			smm[i] = math.Max(0, 1-target/scheduled)
+		}
+		balance = target
+	}
+	return smm, nil
+}
+
+// smmToCPR annualizes a single monthly mortality
+func smmToCPR(smm float64) float64 {
+	return 1 - math.Pow(1-smm, 12)
+}
+
+// SMMFromCPR converts an annual CPR in decimals to a single monthly mortality,
+// 1 - (1-CPR)^(1/12)
+func SMMFromCPR(cpr float64) float64 {
+	return 1 - math.Pow(1-cpr, 1.0/12.0)
+}
+
+// prepayModelName names a prepayment model for diagnostics: the registered
+// name for the built-in models, otherwise the Go type
+func prepayModelName(m PrepayModel) string {
+	switch m.(type) {
+	case FlatCPR, *FlatCPR:
+		return "flat_cpr"
+	case PSA, *PSA:
+		return "psa"
+	case VectorCPR, *VectorCPR:
+		return "cpr_vector"
+	case CPRCurve:
+		return "cpr_curve"
+	case smmVector:
+		return "smm_vector"
+	default:
+		return fmt.Sprintf("%T", m)
+	}
+}
@@ -0,0 +1,404 @@
+package amortization
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPrepayModels_SMM(t *testing.T) {
+	testCases := []struct {
+		name     string
+		model    PrepayModel
+		period   int
+		expected float64
+	}{
+		{name: "flat", model: FlatCPR{CPR: 0.06}, period: 100, expected: 1 - math.Pow(0.94, 1.0/12.0)},
+		{name: "psa month 1", model: PSA{Speed: 100}, period: 1, expected: 1 - math.Pow(0.998, 1.0/12.0)},
+		{name: "psa month 30", model: PSA{Speed: 100}, period: 30, expected: 1 - math.Pow(0.94, 1.0/12.0)},
+		{name: "psa plateau", model: PSA{Speed: 200}, period: 200, expected: 1 - math.Pow(0.88, 1.0/12.0)},
+		{name: "vector", model: VectorCPR{CPR: []float64{0.0, 0.12}}, period: 2, expected: 1 - math.Pow(0.88, 1.0/12.0)},
+		{name: "vector carries forward", model: VectorCPR{CPR: []float64{0.0, 0.12}}, period: 50, expected: 1 - math.Pow(0.88, 1.0/12.0)},
+		{name: "empty vector", model: VectorCPR{}, period: 1, expected: 0.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.model.SMM(tc.period, 100000.0); math.Abs(got-tc.expected) > 1e-12 {
+				t.Errorf("Expected SMM %.10f, got %.10f", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetAmortizationTable_FlatCPRModelMatchesPrepayCPR(t *testing.T) {
+	legacy := &LoanInfo{ID: "MODEL001", Wam: 360, Wac: 5.0, Face: 200000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.1}}
+	modeled := &LoanInfo{ID: "MODEL001", Wam: 360, Wac: 5.0, Face: 200000.0, PrepayInfo: PrepayInfo{PrepayModel: FlatCPR{CPR: 0.1}}}
+
+	legacyTable := legacy.GetAmortizationTable()
+	modeledTable := modeled.GetAmortizationTable()
+
+	for i := range legacyTable.EndBal {
+		if legacyTable.EndBal[i] != modeledTable.EndBal[i] {
+			t.Fatalf("Period %d: expected end balance %.2f, got %.2f", i+1, legacyTable.EndBal[i], modeledTable.EndBal[i])
+		}
+	}
+}
+
+func TestGetAmortizationTable_SwapsPrepayModels(t *testing.T) {
+	loan := LoanInfo{ID: "MODEL002", Wam: 360, Wac: 6.0, Face: 300000.0}
+
+	models := map[string]PrepayModel{
+		"none":   VectorCPR{CPR: []float64{0.0}},
+		"flat":   FlatCPR{CPR: 0.06},
+		"psa":    PSA{Speed: 100},
+		"vector": VectorCPR{CPR: []float64{0.0, 0.0, 0.0, 0.5}},
+	}
+	tables := make(map[string]AmortizationTable)
+	for name, model := range models {
+		l := loan
+		l.PrepayModel = model
+		table := l.GetAmortizationTable()
+		if err := table.Check(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		tables[name] = table
+	}
+
+	for _, prepay := range tables["none"].PrepayAmountArr {
+		if prepay != 0.0 {
+			t.Fatalf("Expected no prepayment with a zero CPR vector, got %.2f", prepay)
+		}
+	}
+
+	// PSA ramps up, so it prepays less than the flat 6% CPR it plateaus at
+	if tables["psa"].PrepayAmountArr[0] >= tables["flat"].PrepayAmountArr[0] {
+		t.Errorf("Expected PSA month-1 prepayment below flat CPR, got %.2f vs %.2f",
+			tables["psa"].PrepayAmountArr[0], tables["flat"].PrepayAmountArr[0])
+	}
+	psa, flat := tables["psa"], tables["flat"]
+	if psa.WAL() <= flat.WAL() {
+		t.Errorf("Expected PSA WAL above flat CPR WAL, got %.2f vs %.2f", psa.WAL(), flat.WAL())
+	}
+
+	// The vector model starts prepaying in period 4
+	vector := tables["vector"]
+	if vector.PrepayAmountArr[2] != 0.0 || vector.PrepayAmountArr[3] == 0.0 {
+		t.Errorf("Expected vector prepayment to begin in period 4, got %v", vector.PrepayAmountArr[:5])
+	}
+}
+
+func TestGetAmortizationTable_RecordsModelSMMs(t *testing.T) {
+	loan := &LoanInfo{ID: "MODEL003", Wam: 60, Wac: 5.0, Face: 50000.0, PrepayInfo: PrepayInfo{PrepayModel: PSA{Speed: 150}}}
+	loan.GetAmortizationTable()
+
+	if len(loan.SMMArr) != 60 {
+		t.Fatalf("Expected 60 recorded SMMs, got %d", len(loan.SMMArr))
+	}
+	if loan.SMMArr[0] >= loan.SMMArr[29] {
+		t.Errorf("Expected ramping SMMs, got %.6f then %.6f", loan.SMMArr[0], loan.SMMArr[29])
+	}
+}
+
+func TestCombineCPR_PSAWithFloor(t *testing.T) {
+	const floor = 0.03
+	combined := CombineCPR(PSACPR(100), ConstantCPR(floor))
+
+	// 100% PSA reaches 3% CPR in month 15: the floor binds before that and
+	// PSA takes over after, up to its 6% plateau
+	for period := 1; period <= 60; period++ {
+		psa := psaCPR(100, period)
+		got := combined(period)
+		switch {
+		case period < 15:
+			if got != floor {
+				t.Errorf("Period %d: expected the %.2f floor, got %.4f", period, floor, got)
+			}
+		case period > 15:
+			if got != psa {
+				t.Errorf("Period %d: expected PSA CPR %.4f, got %.4f", period, psa, got)
+			}
+		}
+	}
+
+	if got := CombineCPR()(10); got != 0 {
+		t.Errorf("Expected an empty combination to be zero, got %v", got)
+	}
+}
+
+func TestRampCPR(t *testing.T) {
+	ramped := RampCPR(ConstantCPR(0.12), 12)
+	if got := ramped(3); math.Abs(got-0.03) > 1e-12 {
+		t.Errorf("Expected a quarter of the CPR in month 3, got %v", got)
+	}
+	if got := ramped(24); got != 0.12 {
+		t.Errorf("Expected the full CPR after seasoning, got %v", got)
+	}
+	if got := RampCPR(ConstantCPR(0.12), 0)(1); got != 0.12 {
+		t.Errorf("Expected no ramp without seasoning months, got %v", got)
+	}
+}
+
+func TestGetAmortizationTable_CombinedCPRModel(t *testing.T) {
+	combined := CombineCPR(RampCPR(PSACPR(150), 6), ConstantCPR(0.02))
+	loan := &LoanInfo{ID: "COMBINED", Wam: 360, Wac: 6.0, Face: 200000.0, PrepayInfo: PrepayInfo{PrepayModel: CPRCurve(combined)}}
+	table := loan.GetAmortizationTable()
+
+	if err := table.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if table.Diagnostics.PrepayModel != "cpr_curve" {
+		t.Errorf("Expected prepay model cpr_curve, got %q", table.Diagnostics.PrepayModel)
+	}
+	if want := SMMFromCPR(0.02); math.Abs(loan.SMMArr[0]-want) > 1e-12 {
+		t.Errorf("Expected the floor SMM %.8f in month 1, got %.8f", want, loan.SMMArr[0])
+	}
+	if want := SMMFromCPR(0.09); math.Abs(loan.SMMArr[99]-want) > 1e-12 {
+		t.Errorf("Expected the 150%% PSA plateau SMM %.8f in month 100, got %.8f", want, loan.SMMArr[99])
+	}
+
+	// CPRs outside [0, 1] are clamped rather than producing NaN SMMs
+	if smm := CPRCurve(ConstantCPR(1.5)).SMM(1, 1000); smm != 1 {
+		t.Errorf("Expected a CPR above 1 to clamp to a full prepayment, got %v", smm)
+	}
+}
+
+func TestPSAToCPRVector(t *testing.T) {
+	cpr := PSAToCPRVector(100, 360)
+
+	if len(cpr) != 360 {
+		t.Fatalf("Expected 360 periods, got %d", len(cpr))
+	}
+
+	testCases := []struct {
+		month    int
+		expected float64
+	}{
+		{month: 1, expected: 0.002},
+		{month: 15, expected: 0.03},
+		{month: 30, expected: 0.06},
+		{month: 31, expected: 0.06},
+		{month: 360, expected: 0.06},
+	}
+	for _, tc := range testCases {
+		if got := cpr[tc.month-1]; math.Abs(got-tc.expected) > 1e-12 {
+			t.Errorf("Month %d: expected CPR %.4f, got %.4f", tc.month, tc.expected, got)
+		}
+	}
+
+	if got := PSAToCPRVector(200, 30)[29]; math.Abs(got-0.12) > 1e-12 {
+		t.Errorf("Expected 200 PSA month-30 CPR 0.12, got %.4f", got)
+	}
+}
+
+func TestCPRVectorToSMMVector(t *testing.T) {
+	smm := CPRVectorToSMMVector([]float64{0.0, 0.06, 0.12})
+
+	expected := []float64{0.0, 1 - math.Pow(0.94, 1.0/12.0), 1 - math.Pow(0.88, 1.0/12.0)}
+	for i := range expected {
+		if math.Abs(smm[i]-expected[i]) > 1e-12 {
+			t.Errorf("Index %d: expected SMM %.10f, got %.10f", i, expected[i], smm[i])
+		}
+	}
+
+	// Matches the PSA model applied inside the engine
+	psa := CPRVectorToSMMVector(PSAToCPRVector(150, 40))
+	for period := 1; period <= 40; period++ {
+		if got := (PSA{Speed: 150}).SMM(period, 0); math.Abs(got-psa[period-1]) > 1e-15 {
+			t.Fatalf("Period %d: expected SMM %.10f, got %.10f", period, psa[period-1], got)
+		}
+	}
+}
+
+func TestGetAmortizationTable_FullSMMPrepaysInFirstPeriod(t *testing.T) {
+	smm := make([]float64, 24)
+	for i := range smm {
+		smm[i] = 1.0
+	}
+	loan := &LoanInfo{
+		ID:         "SMMCAP001",
+		Wam:        24,
+		Wac:        6.0,
+		Face:       50000.0,
+		PrepayInfo: PrepayInfo{PrepayCPR: -1, SMMArr: smm},
+	}
+	table := loan.GetAmortizationTable()
+
+	if table.EndBal[0] != 0.0 {
+		t.Errorf("Expected balance fully prepaid in period 1, got %.2f", table.EndBal[0])
+	}
+	if paid := roundToCent(table.Principal[0] + table.PrepayAmountArr[0]); paid != 50000.0 {
+		t.Errorf("Expected 50000.00 retired in period 1, got %.2f", paid)
+	}
+	for i := 1; i < len(table.Period); i++ {
+		if table.BegBal[i] != 0.0 || table.Principal[i] != 0.0 || table.PrepayAmountArr[i] != 0.0 || table.Interest[i] != 0.0 {
+			t.Fatalf("Period %d: expected all zeros after payoff, got beg %.2f prin %.2f prepay %.2f int %.2f",
+				i+1, table.BegBal[i], table.Principal[i], table.PrepayAmountArr[i], table.Interest[i])
+		}
+	}
+	if len(table.SMMCapped) != 0 {
+		t.Errorf("Expected an SMM of exactly 1.0 not to bind the cap, got %v", table.SMMCapped)
+	}
+	if err := table.Check(); err != nil {
+		t.Errorf("Table failed check: %v", err)
+	}
+}
+
+func TestGetAmortizationTable_SMMCapBinds(t *testing.T) {
+	loan := &LoanInfo{
+		ID:   "SMMCAP002",
+		Wam:  12,
+		Wac:  5.0,
+		Face: 10000.0,
+		PrepayInfo: PrepayInfo{
+			PrepayCPR: -1,
+			SMMArr:    []float64{0.0, 1.5, 0.0},
+		},
+	}
+	table := loan.GetAmortizationTable()
+
+	if len(table.SMMCapped) != 1 || table.SMMCapped[0] != 2 {
+		t.Errorf("Expected the cap to bind in period 2, got %v", table.SMMCapped)
+	}
+	for i, bal := range table.EndBal {
+		if bal < 0 {
+			t.Fatalf("Period %d: negative ending balance %.2f", i+1, bal)
+		}
+	}
+	if table.EndBal[1] != 0.0 {
+		t.Errorf("Expected balance retired in period 2, got %.2f", table.EndBal[1])
+	}
+
+	// A tighter cap limits each period's prepayment
+	capped := &LoanInfo{
+		ID:         "SMMCAP003",
+		Wam:        12,
+		Wac:        5.0,
+		Face:       10000.0,
+		PrepayInfo: PrepayInfo{PrepayModel: FlatCPR{CPR: 0.9}, SMMCap: 0.05},
+	}
+	capped.GetAmortizationTable()
+	for i, smm := range capped.SMMArr {
+		if smm > 0.05 {
+			t.Fatalf("Period %d: expected SMM capped at 0.05, got %f", i+1, smm)
+		}
+	}
+}
+
+func TestImpliedCPR_RecoversKnownCPR(t *testing.T) {
+	loan := &LoanInfo{ID: "IMPLIED001", Wam: 360, Wac: 6.0, Face: 500000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.12}}
+	table := loan.GetAmortizationTable()
+
+	cpr := ImpliedCPR(table.EndBal, table.SchedBal)
+	if len(cpr) != 360 {
+		t.Fatalf("Expected 360 periods, got %d", len(cpr))
+	}
+
+	// Cent rounding dominates once balances are small, so check the early periods
+	for i := 0; i < 100; i++ {
+		if math.Abs(cpr[i]-0.12) > 1e-3 {
+			t.Fatalf("Period %d: expected implied CPR 0.12, got %.6f", i+1, cpr[i])
+		}
+	}
+}
+
+func TestImpliedCPR_EdgeCases(t *testing.T) {
+	cpr := ImpliedCPR(
+		[]float64{0.0, 100.0, 0.0, 50.0},
+		[]float64{0.0, 90.0, 100.0, 100.0, 999.0},
+	)
+
+	expected := []float64{
+		0.0,                     // zero scheduled balance
+		0.0,                     // balance above schedule clamps to no prepayment
+		1.0,                     // fully prepaid
+		1 - math.Pow(0.5, 12.0), // half the scheduled balance prepaid
+	}
+	if len(cpr) != len(expected) {
+		t.Fatalf("Expected %d periods, got %d", len(expected), len(cpr))
+	}
+	for i := range expected {
+		if math.Abs(cpr[i]-expected[i]) > 1e-12 {
+			t.Errorf("Period %d: expected CPR %.6f, got %.6f", i+1, expected[i], cpr[i])
+		}
+	}
+}
+
+func TestSMMFromCPR(t *testing.T) {
+	for _, cpr := range []float64{0.0, 0.01, 0.06, 0.25, 0.5, 0.99} {
+		expected := 1 - math.Pow(1-cpr, 1.0/12.0)
+		if got := SMMFromCPR(cpr); math.Abs(got-expected) > 1e-15 {
+			t.Errorf("CPR %.2f: expected SMM %.10f, got %.10f", cpr, expected, got)
+		}
+
+		// The array filler produces the same scalar
+		prepay := &PrepayInfo{PrepayCPR: cpr}
+		if got := prepay.ConvertCPRToSMM(3)[2]; got != SMMFromCPR(cpr) {
+			t.Errorf("CPR %.2f: expected ConvertCPRToSMM to match SMMFromCPR, got %.10f", cpr, got)
+		}
+	}
+
+	// Annualizing the SMM recovers the CPR
+	if got := smmToCPR(SMMFromCPR(0.12)); math.Abs(got-0.12) > 1e-12 {
+		t.Errorf("Expected round trip to 0.12, got %.12f", got)
+	}
+}
+
+func TestSolveSMMVector_RoundTrip(t *testing.T) {
+	smm := make([]float64, 360)
+	for i := range smm {
+		smm[i] = SMMFromCPR(psaCPR(150, i+1))
+	}
+	loan := &LoanInfo{ID: "SOLVE001", Wam: 360, Wac: 5.5, Face: 400000.0, PrepayInfo: PrepayInfo{PrepayCPR: -1, SMMArr: smm}}
+	table := loan.GetAmortizationTable()
+
+	solved, err := SolveSMMVector(400000.0, 5.5, 360, table.EndBal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(solved) != len(table.EndBal) {
+		t.Fatalf("Expected %d SMMs, got %d", len(table.EndBal), len(solved))
+	}
+
+	// The observed balances are rounded to the cent, so check the periods
+	// before the balance is small enough for that to dominate
+	for i := 0; i < 120; i++ {
+		if math.Abs(solved[i]-smm[i]) > 1e-6 {
+			t.Fatalf("Period %d: expected SMM %.8f, got %.8f", i+1, smm[i], solved[i])
+		}
+	}
+
+	// Feeding the solved vector forward reproduces the balance path
+	replay := &LoanInfo{ID: "SOLVE001", Wam: 360, Wac: 5.5, Face: 400000.0, PrepayInfo: PrepayInfo{PrepayCPR: -1, SMMArr: solved}}
+	replayed := replay.GetAmortizationTable()
+	for i := range table.EndBal {
+		if math.Abs(replayed.EndBal[i]-table.EndBal[i]) > 0.02 {
+			t.Fatalf("Period %d: expected ending balance %.2f, got %.2f", i+1, table.EndBal[i], replayed.EndBal[i])
+		}
+	}
+}
+
+func TestSolveSMMVector_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		face    float64
+		wam     int
+		target  []float64
+		wantErr string
+	}{
+		{name: "zero face", face: 0, wam: 360, wantErr: "face must be positive"},
+		{name: "zero wam", face: 100000, wam: 0, wantErr: "WAM must be between"},
+		{name: "too many targets", face: 1000, wam: 2, target: []float64{500, 0, 0}, wantErr: "3 target balances for a 2-period loan"},
+		{name: "above schedule", face: 100000, wam: 360, target: []float64{100000}, wantErr: "period 1: target balance 100000.00 is above the scheduled balance"},
+		{name: "negative target", face: 100000, wam: 360, target: []float64{99000, -1}, wantErr: "period 2: target balance cannot be negative"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := SolveSMMVector(tc.face, 6.0, tc.wam, tc.target)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Pricing holds the valuation of a table's cashflows
+type Pricing struct {
+	PV       float64 `json:"pv"`       // Present value at the start of the table
+	WAL      float64 `json:"wal"`      // Weighted average life in years
+	Duration float64 `json:"duration"` // Macaulay duration in years
+}
+
+// Cashflows returns the investor's total cashflow in each period: interest,
+// scheduled principal, prepayment, any prepayment penalty, and the recovery
+// on any defaults. Tables with delinquency curves count the interest
+// collected in cash rather than the interest accrued.
+func (a *AmortizationTable) Cashflows() []float64 {
+	interest := a.Interest
+	if a.CollectedInterest != nil {
+		interest = a.CollectedInterest
+	}
+
+	flows := make([]float64, len(a.Period))
+	for i := range flows {
+		flows[i] = interest[i] + a.Principal[i] + a.PrepayAmountArr[i]
+		if i < len(a.PenaltyArr) {
+			flows[i] += a.PenaltyArr[i]
+		}
+		if i < len(a.DefaultAmountArr) {
+			flows[i] += a.DefaultAmountArr[i] - a.LossArr[i]
+		}
+	}
+	return flows
+}
+
+// CashflowsAfter returns a new table holding only the periods that pay after
+// settle, for valuing the loan from a forward settlement date. Periods paying
+// on or before settle are dropped; their period numbers are kept on the rest.
+// Settling before the first payment returns every period and settling after
+// maturity returns none. A table without payment dates (an undated loan) has
+// nothing to filter on and is returned whole.
+func (a *AmortizationTable) CashflowsAfter(settle time.Time) AmortizationTable {
+	k := 0
+	if a.PaymentDates != nil {
+		k = len(a.PaymentDates)
+		for i, date := range a.PaymentDates {
+			if date.After(settle) {
+				k = i
+				break
+			}
+		}
+	}
+
+	after := *a
+	after.Period = tailOf(a.Period, k)
+	after.PaymentDates = tailOf(a.PaymentDates, k)
+	for _, col := range []*Amounts{
+		&after.BegBal, &after.Interest, &after.Principal, &after.Payment, &after.SchedBal,
+		&after.PrepayAmountArr, &after.EndBal, &after.PenaltyArr, &after.EscrowArr, &after.TotalPayment,
+		&after.DefaultAmountArr, &after.LossArr, &after.CumulativeDefault, &after.CumulativeLoss,
+		&after.CollectedInterest, &after.InterestArrears,
+	} {
+		*col = tailOf(*col, k)
+	}
+	after.FactorArr = tailOf(a.FactorArr, k)
+	for _, col := range []*[]float64{
+		&after.DelinqArrays.PerfArr, &after.DelinqArrays.DQ30Arr, &after.DelinqArrays.DQ60Arr, &after.DelinqArrays.DQ90Arr,
+		&after.DelinqArrays.DQ120Arr, &after.DelinqArrays.DQ150Arr, &after.DelinqArrays.DQ180Arr, &after.DelinqArrays.DefaultArr,
+	} {
+		*col = tailOf(*col, k)
+	}
+
+	after.SMMCapped = nil
+	for _, period := range a.SMMCapped {
+		if period > k {
+			after.SMMCapped = append(after.SMMCapped, period)
+		}
+	}
+	if after.EffectiveMaturity <= k {
+		after.EffectiveMaturity = 0
+	}
+	return after
+}
+
+// tailOf returns a copy of col from index k on, or nil for a nil column
+func tailOf[S ~[]E, E any](col S, k int) S {
+	if col == nil {
+		return nil
+	}
+	return slices.Clone(col[min(k, len(col)):])
+}
+
+// periodDiscountRate returns the rate one period of the table discounts at
+// for an annual rate in percentage points. A biweekly period discounts over
+// the 14/365 of a year it accrues, so a loan priced at its own coupon comes
+// out at par.
+func (a *AmortizationTable) periodDiscountRate(ratePct float64) float64 {
+	if a.PeriodsPerYear == biweeklyPeriodsPerYear {
+		return ratePct / 100.0 * biweeklyYearFraction
+	}
+	return ratePct / 12.0 / 100.0
+}
+
+// YieldDiscountFactors returns one discount factor per period of the table at
+// a flat annual yield in percentage points, compounded each period
+func (a *AmortizationTable) YieldDiscountFactors(yieldPct float64) []float64 {
+	rate := a.periodDiscountRate(yieldPct)
+	factors := make([]float64, len(a.Period))
+	discount := 1.0
+	for i := range factors {
+		discount /= 1 + rate
+		factors[i] = discount
+	}
+	return factors
+}
+
+// CurveDiscountFactors returns one discount factor per period of the table
+// from a zero curve of annual rates in percentage points, one per month and
+// compounded each period. Each period takes the rate of the month its tenor
+// falls in; the last rate extends flat past the end of the curve.
+func (a *AmortizationTable) CurveDiscountFactors(zeroCurve []float64) ([]float64, error) {
+	if len(zeroCurve) == 0 {
+		return nil, fmt.Errorf("zero curve is empty")
+	}
+
+	perYear := int(a.periodsPerYear())
+	factors := make([]float64, len(a.Period))
+	for i := range factors {
+		month := ((i+1)*12+perYear-1)/perYear - 1
+		rate := zeroCurve[min(month, len(zeroCurve)-1)]
+		if rate <= -1200.0 {
+			return nil, fmt.Errorf("zero rate %f at period %d is not a valid rate", rate, i+1)
+		}
+		factors[i] = math.Pow(1+a.periodDiscountRate(rate), -float64(i+1))
+	}
+	return factors, nil
+}
+
+// Price discounts the table's cashflows with one discount factor per period
+func (a *AmortizationTable) Price(discountFactors []float64) Pricing {
+	pv := 0.0
+	weightedTime := 0.0
+	for i, flow := range a.Cashflows() {
+		if i >= len(discountFactors) {
+			break
+		}
+		discounted := flow * discountFactors[i]
+		pv += discounted
+		weightedTime += float64(a.Period[i]) / a.periodsPerYear() * discounted
+	}
+
+	pricing := Pricing{PV: pv, WAL: a.WAL()}
+	if pv != 0 {
+		pricing.Duration = weightedTime / pv
+	}
+	return pricing
+}
+
+// AccruedInterest returns the interest accrued from the start of the period
+// containing settle up to settle, on that period's beginning balance. Periods
+// follow the loan's accrual dates and accrue under its DayCount, or 30/360
+// when none is set, and its compounding convention.
+func (l *LoanInfo) AccruedInterest(table AmortizationTable, settle time.Time) (float64, error) {
+	if l.OriginationDate.IsZero() {
+		return 0, fmt.Errorf("origination date is required to accrue interest")
+	}
+	if settle.Before(l.OriginationDate) {
+		return 0, fmt.Errorf("settle date %s is before origination", settle.Format(time.DateOnly))
+	}
+
+	dayCount, ok := l.dayCountFraction()
+	if !ok {
+		dayCount = thirty360
+	}
+
+	coupons := l.couponSchedule()
+	for j := range table.Period {
+		start, end := l.accrualPeriod(j)
+		if settle.Before(end) {
+			coupon := l.CouponPct()
+			if coupons != nil {
+				coupon = coupons[j]
+			}
+			return roundToCent(table.BegBal[j] * l.accrualRate(coupon, dayCount(start, settle))), nil
+		}
+	}
+	return 0, nil // Settles after maturity
+}
+
+// breakEvenStep is the CPR spacing BreakEvenCPR scans for a crossing before
+// bisecting it
+const breakEvenStep = 0.01
+
+// BreakEvenCPR returns the flat CPR in decimals at which loanA and loanB have
+// the same present value at yield, in percentage points compounded each period.
+// Each loan's own prepayment assumptions are replaced by the flat CPR. The
+// range [0, 1) is scanned in steps of breakEvenStep for the first change in
+// sign of the PV difference, which is then bisected; an error is returned
+// when the PVs do not cross in that range.
+func BreakEvenCPR(loanA, loanB LoanInfo, yield float64) (float64, error) {
+	if err := loanA.Validate(); err != nil {
+		return 0, fmt.Errorf("loan %s: %w", loanA.ID, err)
+	}
+	if err := loanB.Validate(); err != nil {
+		return 0, fmt.Errorf("loan %s: %w", loanB.ID, err)
+	}
+
+	spread := func(cpr float64) float64 {
+		return pvAtCPR(loanA, cpr, yield) - pvAtCPR(loanB, cpr, yield)
+	}
+
+	lo, fLo := 0.0, spread(0)
+	if fLo == 0 {
+		return 0, nil
+	}
+	for hi := breakEvenStep; hi < 1; hi += breakEvenStep {
+		fHi := spread(hi)
+		if math.Signbit(fLo) == math.Signbit(fHi) && fHi != 0 {
+			lo, fLo = hi, fHi
+			continue
+		}
+		for hi-lo > 1e-7 {
+			mid := (lo + hi) / 2
+			fMid := spread(mid)
+			if math.Signbit(fMid) == math.Signbit(fLo) {
+				lo, fLo = mid, fMid
+			} else {
+				hi = mid
+			}
+		}
+		return (lo + hi) / 2, nil
+	}
+	return 0, fmt.Errorf("present values of %s and %s do not cross for CPR in [0, 1)", loanA.ID, loanB.ID)
+}
+
+// pvAtCPR prices the loan's cashflows at a flat CPR and yield in percentage
+// points
+func pvAtCPR(loan LoanInfo, cpr, yield float64) float64 {
+	loan.PrepayCPR = cpr
+	loan.PrepayModel = nil
+	loan.SMMArr = nil
+	table := loan.GetAmortizationTable()
+	return table.Price(table.YieldDiscountFactors(yield)).PV
+}
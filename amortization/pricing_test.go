@@ -0,0 +1,169 @@
+package amortization
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrice_AtCouponIsPar(t *testing.T) {
+	loan := &LoanInfo{ID: "PRICE001", Wam: 360, Wac: 6.0, Face: 200000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.08}}
+	table := loan.GetAmortizationTable()
+
+	pricing := table.Price(table.YieldDiscountFactors(6.0))
+	if math.Abs(pricing.PV-200000.0) > 0.005*360 {
+		t.Errorf("Expected PV at the coupon near par, got %.2f", pricing.PV)
+	}
+	if pricing.Duration <= 0 || pricing.Duration >= table.WAL() {
+		t.Errorf("Expected duration between 0 and WAL %.2f, got %.2f", table.WAL(), pricing.Duration)
+	}
+
+	// A higher yield prices below par
+	if higher := table.Price(table.YieldDiscountFactors(7.0)); higher.PV >= pricing.PV {
+		t.Errorf("Expected higher yield to lower PV, got %.2f vs %.2f", higher.PV, pricing.PV)
+	}
+}
+
+func TestCurveDiscountFactors_FlatCurveMatchesYield(t *testing.T) {
+	for _, frequency := range []string{FrequencyMonthly, FrequencyBiweekly} {
+		loan := &LoanInfo{ID: "CURVE001", Wam: 24, Wac: 5.0, Face: 10000.0, PaymentFrequency: frequency}
+		table := loan.GetAmortizationTable()
+		byYield := table.YieldDiscountFactors(5.0)
+		byCurve, err := table.CurveDiscountFactors([]float64{5.0})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", frequency, err)
+		}
+		if len(byCurve) != len(table.Period) {
+			t.Fatalf("%s: expected %d factors, got %d", frequency, len(table.Period), len(byCurve))
+		}
+		for i := range byYield {
+			if math.Abs(byYield[i]-byCurve[i]) > 1e-12 {
+				t.Fatalf("%s period %d: expected %.12f, got %.12f", frequency, i+1, byYield[i], byCurve[i])
+			}
+		}
+	}
+
+	table := (&LoanInfo{ID: "CURVE002", Wam: 12, Wac: 5.0, Face: 10000.0}).GetAmortizationTable()
+	if _, err := table.CurveDiscountFactors(nil); err == nil {
+		t.Error("Expected error for an empty curve")
+	}
+}
+
+func TestAccruedInterest(t *testing.T) {
+	loan := &LoanInfo{
+		ID:              "PRICE002",
+		Wam:             360,
+		Wac:             6.0,
+		Face:            100000.0,
+		OriginationDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	table := loan.GetAmortizationTable()
+
+	// Settling on February 16 accrues 15 days (30/360) on February's opening balance
+	accrued, err := loan.AccruedInterest(table, time.Date(2024, 2, 16, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := roundToCent(table.BegBal[1] * 0.06 * 15.0 / 360.0)
+	if accrued != expected {
+		t.Errorf("Expected accrued interest %.2f, got %.2f", expected, accrued)
+	}
+
+	undated := &LoanInfo{ID: "PRICE003", Wam: 12, Wac: 6.0, Face: 1000.0}
+	if _, err := undated.AccruedInterest(table, time.Now()); err == nil {
+		t.Error("Expected error without an origination date")
+	}
+}
+
+func TestCashflowsAfter(t *testing.T) {
+	loan := &LoanInfo{
+		ID:              "SETTLE001",
+		Wam:             120,
+		Wac:             5.5,
+		Face:            200000.0,
+		OrigFace:        200000.0,
+		OriginationDate: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+	}
+	loan.PrepayCPR = 0.08
+	table := loan.GetAmortizationTable()
+
+	// Period 1 pays 2024-02-15; settling on the 2025-03-15 payment date drops
+	// that period 14 along with the thirteen before it
+	after := table.CashflowsAfter(time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC))
+	if len(after.Period) != 106 {
+		t.Fatalf("Expected 106 remaining periods, got %d", len(after.Period))
+	}
+	if after.Period[0] != 15 || !after.PaymentDates[0].Equal(time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected period 15 paying 2025-04-15 first, got period %d paying %s", after.Period[0], after.PaymentDates[0])
+	}
+	if after.BegBal[0] != table.EndBal[13] {
+		t.Errorf("Expected the remaining table to open at %.2f, got %.2f", table.EndBal[13], after.BegBal[0])
+	}
+	for _, col := range [][]float64{after.Interest, after.Principal, after.PrepayAmountArr, after.EndBal, after.FactorArr} {
+		if len(col) != 106 {
+			t.Fatalf("Expected every column trimmed to 106 periods, got %d", len(col))
+		}
+	}
+	if err := after.Check(); err != nil {
+		t.Error(err)
+	}
+
+	// The result is a copy; the original table is untouched
+	after.Interest[0] = -1
+	if table.Interest[14] == -1 || len(table.Period) != 120 {
+		t.Error("Expected CashflowsAfter not to modify the original table")
+	}
+
+	if full := table.CashflowsAfter(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)); len(full.Period) != 120 {
+		t.Errorf("Expected settlement before origination to keep all 120 periods, got %d", len(full.Period))
+	}
+	if none := table.CashflowsAfter(time.Date(2035, 1, 16, 0, 0, 0, 0, time.UTC)); len(none.Period) != 0 || len(none.Interest) != 0 {
+		t.Errorf("Expected settlement after maturity to leave no periods, got %d", len(none.Period))
+	}
+
+	undated := (&LoanInfo{ID: "SETTLE002", Wam: 12, Wac: 6.0, Face: 1000.0}).GetAmortizationTable()
+	if whole := undated.CashflowsAfter(time.Now()); len(whole.Period) != 12 {
+		t.Errorf("Expected an undated table to be returned whole, got %d periods", len(whole.Period))
+	}
+}
+
+func TestBreakEvenCPR_Crossing(t *testing.T) {
+	// The premium 7% loan is worth more slowly prepaying; the larger 4.5% loan
+	// is worth more once both return their face quickly
+	premium := LoanInfo{ID: "BE-A", Wam: 360, Wac: 7.0, Face: 100000.0}
+	discount := LoanInfo{ID: "BE-B", Wam: 360, Wac: 4.5, Face: 110000.0}
+
+	cpr, err := BreakEvenCPR(premium, discount, 5.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpr <= 0 || cpr >= 1 {
+		t.Fatalf("Expected a break-even CPR in (0, 1), got %f", cpr)
+	}
+
+	if diff := pvAtCPR(premium, cpr, 5.0) - pvAtCPR(discount, cpr, 5.0); math.Abs(diff) > 1.0 {
+		t.Errorf("Expected equal PVs at CPR %f, got a difference of %.2f", cpr, diff)
+	}
+	if pvAtCPR(premium, cpr-0.02, 5.0) <= pvAtCPR(discount, cpr-0.02, 5.0) {
+		t.Error("Expected the premium loan to be worth more below the break-even CPR")
+	}
+	if pvAtCPR(premium, cpr+0.02, 5.0) >= pvAtCPR(discount, cpr+0.02, 5.0) {
+		t.Error("Expected the discount loan to be worth more above the break-even CPR")
+	}
+}
+
+func TestBreakEvenCPR_NoCrossing(t *testing.T) {
+	// With equal faces the 7% loan is worth more than the 3% loan at any speed
+	high := LoanInfo{ID: "BE-C", Wam: 360, Wac: 7.0, Face: 100000.0}
+	low := LoanInfo{ID: "BE-D", Wam: 360, Wac: 3.0, Face: 100000.0}
+
+	if _, err := BreakEvenCPR(high, low, 5.0); err == nil || !strings.Contains(err.Error(), "do not cross") {
+		t.Errorf("Expected a no-crossing error, got %v", err)
+	}
+
+	invalid := LoanInfo{ID: "BE-E", Wam: 360, Wac: 5.0, Face: -1}
+	if _, err := BreakEvenCPR(invalid, low, 5.0); err == nil || !strings.HasPrefix(err.Error(), "loan BE-E:") {
+		t.Errorf("Expected a validation error for BE-E, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package amortization
+
+// PeriodRecord is one period of an amortization table in a flat,
+// wire-friendly form: fixed-width scalars only, with the payment date as
+// Unix seconds rather than a time.Time, so it maps field for field onto a
+// protobuf message. Absent columns are zero.
+type PeriodRecord struct {
+	Period       int32   `json:"period"`
+	BegBal       float64 `json:"beg_bal"`
+	Interest     float64 `json:"interest"`
+	Principal    float64 `json:"principal"`
+	Payment      float64 `json:"payment"`
+	SchedBal     float64 `json:"sched_bal"`
+	PrepayAmount float64 `json:"prepay_amount"`
+	EndBal       float64 `json:"end_bal"`
+	Penalty      float64 `json:"penalty"`
+	Default      float64 `json:"default"`
+	Loss         float64 `json:"loss"`
+	Factor       float64 `json:"factor"`
+	Escrow       float64 `json:"escrow"`
+	TotalPayment float64 `json:"total_payment"`
+	PaymentDate  int64   `json:"payment_date"` // Unix seconds, or 0 for undated loans
+}
+
+// ToRecords returns the table as one PeriodRecord per period, decoupling the
+// wire representation from the column arrays
+func (a *AmortizationTable) ToRecords() []PeriodRecord {
+	rows := a.Rows()
+	records := make([]PeriodRecord, len(rows))
+	for i, row := range rows {
+		records[i] = PeriodRecord{
+			Period:       int32(row.Period),
+			BegBal:       row.BegBal,
+			Interest:     row.Interest,
+			Principal:    row.Principal,
+			Payment:      row.Payment,
+			SchedBal:     row.SchedBal,
+			PrepayAmount: row.PrepayAmount,
+			EndBal:       row.EndBal,
+			Penalty:      row.Penalty,
+			Default:      row.Default,
+			Loss:         row.Loss,
+			Factor:       row.Factor,
+			Escrow:       row.Escrow,
+			TotalPayment: row.TotalPayment,
+		}
+		if !row.PaymentDate.IsZero() {
+			records[i].PaymentDate = row.PaymentDate.Unix()
+		}
+	}
+	return records
+}
@@ -0,0 +1,55 @@
+package amortization
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAmortizationTable_ToRecords(t *testing.T) {
+	loan := LoanInfo{
+		ID: "REC001", Wam: 60, Wac: 5.0, Face: 40000.0, OrigFace: 40000.0,
+		OriginationDate: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		MonthlyEscrow:   150.0,
+		PrepayInfo:      PrepayInfo{PrepayCPR: 0.08, PrepayPenaltyPct: 0.01, PrepayPenaltyMonths: 12},
+		DefaultInfo:     DefaultInfo{DefaultCDR: 0.02, SeverityPct: 0.4},
+	}
+	table := loan.GetAmortizationTable()
+	records := table.ToRecords()
+
+	if len(records) != len(table.Period) {
+		t.Fatalf("Expected %d records, got %d", len(table.Period), len(records))
+	}
+	for i, r := range records {
+		want := PeriodRecord{
+			Period:       int32(table.Period[i]),
+			BegBal:       table.BegBal[i],
+			Interest:     table.Interest[i],
+			Principal:    table.Principal[i],
+			Payment:      table.Payment[i],
+			SchedBal:     table.SchedBal[i],
+			PrepayAmount: table.PrepayAmountArr[i],
+			EndBal:       table.EndBal[i],
+			Penalty:      table.PenaltyArr[i],
+			Default:      table.DefaultAmountArr[i],
+			Loss:         table.LossArr[i],
+			Factor:       table.FactorArr[i],
+			Escrow:       table.EscrowArr[i],
+			TotalPayment: table.TotalPayment[i],
+			PaymentDate:  table.PaymentDates[i].Unix(),
+		}
+		if r != want {
+			t.Fatalf("Record %d: expected %+v, got %+v", i, want, r)
+		}
+	}
+}
+
+func TestAmortizationTable_ToRecordsUndated(t *testing.T) {
+	loan := LoanInfo{ID: "REC002", Wam: 12, Wac: 4.0, Face: 10000.0}
+	table := loan.GetAmortizationTable()
+
+	for i, r := range table.ToRecords() {
+		if r.PaymentDate != 0 || r.Escrow != 0 || r.Penalty != 0 {
+			t.Errorf("Record %d: expected absent columns to be zero, got %+v", i, r)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package amortization
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DayCountFraction returns the fraction of a year between two dates under a
+// day-count convention
+type DayCountFraction func(start, end time.Time) float64
+
+// Capabilities lists the models and conventions the engine supports, as
+// registered alongside their implementations
+type Capabilities struct {
+	PrepayModels       []string `json:"prepay_models"`
+	DayCounts          []string `json:"day_counts"`
+	PaymentFrequencies []string `json:"payment_frequencies"`
+	DefaultModels      []string `json:"default_models"`
+}
+
+var (
+	registryMu sync.RWMutex
+
+	dayCounts = map[string]DayCountFraction{
+		"30/360":  thirty360,
+		"ACT/360": func(start, end time.Time) float64 { return actualDays(start, end) / 360.0 },
+		"ACT/365": func(start, end time.Time) float64 { return actualDays(start, end) / 365.0 },
+
+		"ACT/ACT ISDA": actActISDA,
+	}
+
+	// Periods per year for each payment frequency
+	paymentFrequencies = map[string]int{
+		"monthly":  12,
+		"biweekly": 26,
+	}
+
+	prepayModels = map[string]struct{}{
+		"flat_cpr":   {},
+		"psa":        {},
+		"cpr_vector": {},
+	}
+
+	defaultModels = map[string]struct{}{
+		"constant_cdr": {},
+	}
+)
+
+// RegisterDayCount adds (or replaces) a day-count convention
+func RegisterDayCount(name string, fn DayCountFraction) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	dayCounts[name] = fn
+}
+
+// LookupDayCount returns the day-count convention registered under name
+func LookupDayCount(name string) (DayCountFraction, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	fn, ok := dayCounts[name]
+	return fn, ok
+}
+
+// RegisterPrepayModel advertises a prepayment model by name
+func RegisterPrepayModel(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	prepayModels[name] = struct{}{}
+}
+
+// RegisterPaymentFrequency adds a payment frequency with its periods per year
+func RegisterPaymentFrequency(name string, periodsPerYear int) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	paymentFrequencies[name] = periodsPerYear
+}
+
+// RegisterDefaultModel advertises a default-modeling capability by name
+func RegisterDefaultModel(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	defaultModels[name] = struct{}{}
+}
+
+// SupportedCapabilities returns everything currently registered, sorted by name
+func SupportedCapabilities() Capabilities {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return Capabilities{
+		PrepayModels:       sortedKeys(prepayModels),
+		DayCounts:          sortedKeys(dayCounts),
+		PaymentFrequencies: sortedKeys(paymentFrequencies),
+		DefaultModels:      sortedKeys(defaultModels),
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// actualDays returns the number of calendar days between two dates, ignoring
+// the time of day and any daylight-saving shift
+func actualDays(start, end time.Time) float64 {
+	y1, m1, d1 := start.Date()
+	y2, m2, d2 := end.Date()
+	days := time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC).Sub(time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC))
+	return days.Hours() / 24.0
+}
+
+// actActISDA implements the ACT/ACT ISDA convention: days falling in a leap
+// year count 1/366 of a year and all other days 1/365, so a period spanning a
+// year end is split at January 1
+func actActISDA(start, end time.Time) float64 {
+	if !end.After(start) {
+		return 0.0
+	}
+
+	fraction := 0.0
+	for start.Before(end) {
+		yearEnd := time.Date(start.Year()+1, time.January, 1, 0, 0, 0, 0, start.Location())
+		segmentEnd := end
+		if yearEnd.Before(end) {
+			segmentEnd = yearEnd
+		}
+		fraction += actualDays(start, segmentEnd) / daysInYear(start.Year())
+		start = segmentEnd
+	}
+	return fraction
+}
+
+// daysInYear returns 366 for leap years and 365 otherwise
+func daysInYear(year int) float64 {
+	if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+		return 366.0
+	}
+	return 365.0
+}
+
+// thirty360 implements the 30/360 US (bond basis) convention
+func thirty360(start, end time.Time) float64 {
+	y1, m1, d1 := start.Date()
+	y2, m2, d2 := end.Date()
+	if d1 == 31 {
+		d1 = 30
+	}
+	if d2 == 31 && d1 == 30 {
+		d2 = 30
+	}
+	days := 360*(y2-y1) + 30*(int(m2)-int(m1)) + (d2 - d1)
+	return float64(days) / 360.0
+}
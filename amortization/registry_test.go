@@ -0,0 +1,176 @@
+package amortization
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSupportedCapabilities_BuiltIns(t *testing.T) {
+	caps := SupportedCapabilities()
+
+	if !containsString(caps.DayCounts, "30/360") || !containsString(caps.DayCounts, "ACT/360") {
+		t.Errorf("Expected built-in day counts, got %v", caps.DayCounts)
+	}
+	if !containsString(caps.PrepayModels, "flat_cpr") {
+		t.Errorf("Expected flat_cpr prepay model, got %v", caps.PrepayModels)
+	}
+	if !containsString(caps.PaymentFrequencies, "monthly") {
+		t.Errorf("Expected monthly payment frequency, got %v", caps.PaymentFrequencies)
+	}
+}
+
+func TestRegisterDayCount(t *testing.T) {
+	RegisterDayCount("TEST/100", func(start, end time.Time) float64 { return 0.01 })
+
+	fn, ok := LookupDayCount("TEST/100")
+	if !ok {
+		t.Fatal("Expected registered day count to be found")
+	}
+	if got := fn(time.Now(), time.Now()); got != 0.01 {
+		t.Errorf("Expected registered function to be returned, got %f", got)
+	}
+	if !containsString(SupportedCapabilities().DayCounts, "TEST/100") {
+		t.Error("Expected registered day count in capabilities")
+	}
+}
+
+func TestDayCountFractions(t *testing.T) {
+	start := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		expected float64
+	}{
+		{name: "30/360", expected: 60.0 / 360.0},
+		{name: "ACT/360", expected: 60.0 / 360.0},
+		{name: "ACT/365", expected: 60.0 / 365.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fn, ok := LookupDayCount(tc.name)
+			if !ok {
+				t.Fatalf("Day count %s not registered", tc.name)
+			}
+			if got := fn(start, end); math.Abs(got-tc.expected) > 1e-12 {
+				t.Errorf("Expected %.8f, got %.8f", tc.expected, got)
+			}
+		})
+	}
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestActActISDA(t *testing.T) {
+	testCases := []struct {
+		name     string
+		start    time.Time
+		end      time.Time
+		expected float64
+	}{
+		{
+			name:     "leap february",
+			start:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			end:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			expected: 29.0 / 366.0,
+		},
+		{
+			name:     "non-leap february",
+			start:    time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+			end:      time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+			expected: 28.0 / 365.0,
+		},
+		{
+			name:     "spans year end into leap year",
+			start:    time.Date(2023, 12, 15, 0, 0, 0, 0, time.UTC),
+			end:      time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			expected: 17.0/365.0 + 14.0/366.0,
+		},
+		{
+			name:     "full leap year",
+			start:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			end:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: 1.0,
+		},
+	}
+
+	fn, ok := LookupDayCount("ACT/ACT ISDA")
+	if !ok {
+		t.Fatal("ACT/ACT ISDA not registered")
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fn(tc.start, tc.end); math.Abs(got-tc.expected) > 1e-12 {
+				t.Errorf("Expected %.10f, got %.10f", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetAmortizationTable_ActActLeapFebruary(t *testing.T) {
+	loan := &LoanInfo{
+		ID:              "ACTACT001",
+		Wam:             360,
+		Wac:             6.0,
+		Face:            300000.0,
+		DayCount:        "ACT/ACT ISDA",
+		OriginationDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := loan.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	table := loan.GetAmortizationTable()
+
+	// Period 1 accrues January (31 days), period 2 February 2024 (29 days)
+	january := roundToCent(300000.0 * 0.06 * 31.0 / 366.0)
+	if table.Interest[0] != january {
+		t.Errorf("Expected January interest %.2f, got %.2f", january, table.Interest[0])
+	}
+	february := roundToCent(table.BegBal[1] * 0.06 * 29.0 / 366.0)
+	if math.Abs(table.Interest[1]-february) > 0.01 {
+		t.Errorf("Expected February interest %.2f, got %.2f", february, table.Interest[1])
+	}
+	if table.Interest[1] >= table.Interest[0] {
+		t.Errorf("Expected the 29-day February to accrue less than January, got %.2f vs %.2f", table.Interest[1], table.Interest[0])
+	}
+	if err := table.Check(); err != nil {
+		t.Errorf("Table failed check: %v", err)
+	}
+}
+
+func TestValidate_DayCount(t *testing.T) {
+	loan := &LoanInfo{ID: "ACTACT002", Wam: 12, Wac: 5.0, Face: 1000.0, DayCount: "ACT/ACT ISDA"}
+	if err := loan.Validate(); err == nil {
+		t.Error("Expected error for day count without an origination date")
+	}
+
+	loan.DayCount = "BUS/252"
+	loan.OriginationDate = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := loan.Validate(); err == nil {
+		t.Error("Expected error for unknown day count")
+	}
+}
+
+func TestAddMonths_ClampsToMonthEnd(t *testing.T) {
+	start := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	expected := []time.Time{
+		time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC),
+	}
+	for i, months := range []int{1, 2, 3, 13} {
+		if got := addMonths(start, months); !got.Equal(expected[i]) {
+			t.Errorf("addMonths(%d): expected %s, got %s", months, expected[i].Format("2006-01-02"), got.Format("2006-01-02"))
+		}
+	}
+}
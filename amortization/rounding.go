@@ -0,0 +1,52 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// RoundingMode selects how amounts are rounded to the cent
+type RoundingMode string
+
+const (
+	// RoundHalfUp rounds halves away from zero (2.125 becomes 2.13). The default.
+	RoundHalfUp RoundingMode = "half_up"
+	// RoundHalfEven rounds halves to the even cent (2.125 becomes 2.12),
+	// known as banker's rounding
+	RoundHalfEven RoundingMode = "half_even"
+)
+
+// halfEven is set when RoundHalfEven is selected; it is read on every
+// rounding, so it is atomic rather than guarded by registryMu
+var halfEven atomic.Bool
+
+// SetRoundingMode selects the rounding applied to every amount the package
+// produces. It affects all subsequent calculations process-wide.
+func SetRoundingMode(mode RoundingMode) error {
+	switch mode {
+	case RoundHalfUp:
+		halfEven.Store(false)
+	case RoundHalfEven:
+		halfEven.Store(true)
+	default:
+		return fmt.Errorf("unknown rounding mode %q", mode)
+	}
+	return nil
+}
+
+// CurrentRoundingMode returns the rounding mode in effect
+func CurrentRoundingMode() RoundingMode {
+	if halfEven.Load() {
+		return RoundHalfEven
+	}
+	return RoundHalfUp
+}
+
+// round rounds x to an integer under the selected rounding mode
+func round(x float64) float64 {
+	if halfEven.Load() {
+		return math.RoundToEven(x)
+	}
+	return math.Round(x)
+}
@@ -0,0 +1,74 @@
+package amortization
+
+import "testing"
+
+// useRoundingMode selects mode for the duration of the test
+func useRoundingMode(t *testing.T, mode RoundingMode) {
+	original := CurrentRoundingMode()
+	if err := SetRoundingMode(mode); err != nil {
+		t.Fatalf("SetRoundingMode(%q) failed: %v", mode, err)
+	}
+	t.Cleanup(func() { SetRoundingMode(original) })
+}
+
+func TestRoundToCent_Modes(t *testing.T) {
+	// Each value is exactly representable, so value*100 is an exact half
+	testCases := []struct {
+		value    float64
+		halfUp   float64
+		halfEven float64
+	}{
+		{value: 2.125, halfUp: 2.13, halfEven: 2.12},
+		{value: 2.375, halfUp: 2.38, halfEven: 2.38},
+		{value: 10.625, halfUp: 10.63, halfEven: 10.62},
+		{value: -2.125, halfUp: -2.13, halfEven: -2.12},
+		{value: 2.126, halfUp: 2.13, halfEven: 2.13},
+	}
+
+	for _, tc := range testCases {
+		useRoundingMode(t, RoundHalfUp)
+		if got := roundToCent(tc.value); got != tc.halfUp {
+			t.Errorf("half_up: roundToCent(%v) = %v, want %v", tc.value, got, tc.halfUp)
+		}
+		useRoundingMode(t, RoundHalfEven)
+		if got := roundToCent(tc.value); got != tc.halfEven {
+			t.Errorf("half_even: roundToCent(%v) = %v, want %v", tc.value, got, tc.halfEven)
+		}
+	}
+}
+
+func TestGetAmortizationTable_RoundingModes(t *testing.T) {
+	// 10.25 over two zero-coupon periods is 5.125 of principal a period
+	testCases := []struct {
+		mode  RoundingMode
+		first float64
+	}{
+		{mode: RoundHalfUp, first: 5.13},
+		{mode: RoundHalfEven, first: 5.12},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			useRoundingMode(t, tc.mode)
+			for _, cents := range []bool{false, true} {
+				loan := &LoanInfo{ID: "HALF", Wam: 2, Wac: 0.0, Face: 10.25, IntegerCents: cents}
+				table := loan.GetAmortizationTable()
+				if table.Principal[0] != tc.first {
+					t.Errorf("integer cents %v: expected first principal %.2f, got %.2f", cents, tc.first, table.Principal[0])
+				}
+				if table.EndBal[1] != 0.0 {
+					t.Errorf("integer cents %v: expected the loan to pay off, got %.2f", cents, table.EndBal[1])
+				}
+			}
+		})
+	}
+}
+
+func TestSetRoundingMode_Unknown(t *testing.T) {
+	if err := SetRoundingMode("half_down"); err == nil {
+		t.Error("Expected an error for an unknown rounding mode")
+	}
+	if got := CurrentRoundingMode(); got != RoundHalfUp {
+		t.Errorf("Expected the default mode to be unchanged, got %q", got)
+	}
+}
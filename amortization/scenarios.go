@@ -0,0 +1,81 @@
+package amortization
+
+import (
+	"fmt"
+	"math"
+)
+
+// CPRScenario is one prepayment outcome in a probability-weighted set
+type CPRScenario struct {
+	CPR    float64 `json:"cpr"`    // Flat annual CPR in decimals
+	Weight float64 `json:"weight"` // Probability of the scenario
+}
+
+// scenarioWeightTolerance absorbs rounding in supplied weights, e.g. thirds
+// that sum to 0.9999999
+const scenarioWeightTolerance = 1e-6
+
+// ValidateScenarios checks that there is at least one scenario, every CPR is
+// in [0, 1), no weight is negative, and the weights sum to 1
+func ValidateScenarios(scenarios []CPRScenario) error {
+	if len(scenarios) == 0 {
+		return fmt.Errorf("at least one scenario is required")
+	}
+	sum := 0.0
+	for i, s := range scenarios {
+		if s.CPR < 0 || s.CPR >= 1 {
+			return fmt.Errorf("scenario %d: CPR must be between 0 and 1, got %f", i+1, s.CPR)
+		}
+		if s.Weight < 0 {
+			return fmt.Errorf("scenario %d: weight cannot be negative, got %f", i+1, s.Weight)
+		}
+		sum += s.Weight
+	}
+	if math.Abs(sum-1.0) > scenarioWeightTolerance {
+		return fmt.Errorf("scenario weights must sum to 1, got %f", sum)
+	}
+	return nil
+}
+
+// WeightedAmortization amortizes the loan once per scenario at its flat CPR,
+// replacing the loan's own prepayment assumptions, and blends the tables
+// period by period by the scenario weights. The result is the expected
+// cashflow across the prepayment scenarios; like a pool table it carries the
+// balance and cashflow columns but no factors or SMM caps. Scenarios are
+// checked with ValidateScenarios.
+func WeightedAmortization(l LoanInfo, scenarios []CPRScenario) (AmortizationTable, error) {
+	if err := ValidateScenarios(scenarios); err != nil {
+		return AmortizationTable{}, err
+	}
+
+	acc := newPoolAccumulator(l.numPeriods())
+	for _, s := range scenarios {
+		scenario := l
+		scenario.PrepayCPR = s.CPR
+		scenario.PrepayModel = nil
+		scenario.SMMArr = nil
+		acc.add(weightedTable(scenario.GetAmortizationTable(), s.Weight))
+	}
+	return acc.result(), nil
+}
+
+// weightedTable returns the balance and cashflow columns of t scaled by
+// weight, to the cent
+func weightedTable(t AmortizationTable, weight float64) AmortizationTable {
+	scaled := AmortizationTable{Period: t.Period}
+	for _, col := range []struct{ dst, src *Amounts }{
+		{&scaled.BegBal, &t.BegBal}, {&scaled.Interest, &t.Interest}, {&scaled.Principal, &t.Principal},
+		{&scaled.SchedBal, &t.SchedBal}, {&scaled.PrepayAmountArr, &t.PrepayAmountArr}, {&scaled.EndBal, &t.EndBal},
+		{&scaled.PenaltyArr, &t.PenaltyArr}, {&scaled.EscrowArr, &t.EscrowArr},
+		{&scaled.DefaultAmountArr, &t.DefaultAmountArr}, {&scaled.LossArr, &t.LossArr},
+	} {
+		if *col.src == nil {
+			continue
+		}
+		*col.dst = make(Amounts, len(*col.src))
+		for i, v := range *col.src {
+			(*col.dst)[i] = roundToCent(v * weight)
+		}
+	}
+	return scaled
+}
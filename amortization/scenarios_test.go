@@ -0,0 +1,67 @@
+package amortization
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWeightedAmortization_EqualWeightsAverage(t *testing.T) {
+	loan := LoanInfo{ID: "SCEN001", Wam: 360, Wac: 6.0, Face: 300000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.5}}
+
+	blended, err := WeightedAmortization(loan, []CPRScenario{{CPR: 0.04, Weight: 0.5}, {CPR: 0.20, Weight: 0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slow, fast := loan, loan
+	slow.PrepayCPR, fast.PrepayCPR = 0.04, 0.20
+	slowTable, fastTable := slow.GetAmortizationTable(), fast.GetAmortizationTable()
+
+	if len(blended.Period) != 360 {
+		t.Fatalf("Expected 360 periods, got %d", len(blended.Period))
+	}
+	for i := range blended.Period {
+		for _, col := range []struct {
+			name            string
+			got, slow, fast Amounts
+		}{
+			{"interest", blended.Interest, slowTable.Interest, fastTable.Interest},
+			{"principal", blended.Principal, slowTable.Principal, fastTable.Principal},
+			{"prepay", blended.PrepayAmountArr, slowTable.PrepayAmountArr, fastTable.PrepayAmountArr},
+			{"end_bal", blended.EndBal, slowTable.EndBal, fastTable.EndBal},
+		} {
+			// Each half is rounded to the cent before summing, so the blend
+			// can differ from the exact average by up to a cent
+			if want := (col.slow[i] + col.fast[i]) / 2; math.Abs(col.got[i]-want) > 0.01+1e-9 {
+				t.Fatalf("Period %d: expected %s %.2f, got %.2f", i+1, col.name, want, col.got[i])
+			}
+		}
+	}
+	if blended.BegBal[0] != loan.Face {
+		t.Errorf("Expected the blend to open at the face, got %.2f", blended.BegBal[0])
+	}
+}
+
+func TestWeightedAmortization_InvalidScenarios(t *testing.T) {
+	loan := LoanInfo{ID: "SCEN002", Wam: 360, Wac: 6.0, Face: 300000.0}
+	testCases := []struct {
+		name      string
+		scenarios []CPRScenario
+		wantErr   string
+	}{
+		{name: "none", scenarios: nil, wantErr: "at least one scenario"},
+		{name: "weights sum to 0.9", scenarios: []CPRScenario{{CPR: 0.05, Weight: 0.4}, {CPR: 0.1, Weight: 0.5}}, wantErr: "must sum to 1, got 0.900000"},
+		{name: "negative weight", scenarios: []CPRScenario{{CPR: 0.05, Weight: 1.5}, {CPR: 0.1, Weight: -0.5}}, wantErr: "scenario 2: weight cannot be negative"},
+		{name: "CPR of one", scenarios: []CPRScenario{{CPR: 1, Weight: 1}}, wantErr: "scenario 1: CPR must be between 0 and 1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := WeightedAmortization(loan, tc.scenarios)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
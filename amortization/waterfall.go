@@ -0,0 +1,78 @@
+package amortization
+
+import "sort"
+
+// Tranche is one class of a senior/subordinate structure. Tranches with a
+// lower Priority are senior and are paid principal first.
+type Tranche struct {
+	Name     string  `json:"name"`
+	Size     float64 `json:"size"`     // Original balance of the tranche
+	Priority int     `json:"priority"` // 1 is the most senior
+}
+
+// TrancheCashflow is the share of the pool cashflows allocated to a tranche
+type TrancheCashflow struct {
+	Name      string    `json:"name"`
+	BegBal    []float64 `json:"beg_bal"`   // Tranche balance at the start of each period
+	Interest  []float64 `json:"interest"`  // Interest allocated in each period
+	Principal []float64 `json:"principal"` // Scheduled principal and prepayments allocated in each period
+	EndBal    []float64 `json:"end_bal"`   // Tranche balance at the end of each period
+}
+
+// ApplyWaterfall splits an aggregated pool table across tranches. Each
+// period the pool's scheduled principal and prepayments pay down the tranches
+// sequentially by priority, and the pool's interest is shared pro rata by the
+// tranches' outstanding balances at the start of the period. Results are
+// returned in the order the tranches were given.
+func ApplyWaterfall(agg AmortizationTable, tranches []Tranche) []TrancheCashflow {
+	n := len(agg.Period)
+	flows := make([]TrancheCashflow, len(tranches))
+	balances := make([]float64, len(tranches))
+	for k, tr := range tranches {
+		flows[k] = TrancheCashflow{
+			Name:      tr.Name,
+			BegBal:    make([]float64, n),
+			Interest:  make([]float64, n),
+			Principal: make([]float64, n),
+			EndBal:    make([]float64, n),
+		}
+		balances[k] = tr.Size
+	}
+
+	// Principal is paid senior first; ties keep their given order
+	order := make([]int, len(tranches))
+	for k := range order {
+		order[k] = k
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return tranches[order[a]].Priority < tranches[order[b]].Priority
+	})
+
+	for i := 0; i < n; i++ {
+		outstanding := 0.0
+		for k := range tranches {
+			flows[k].BegBal[i] = balances[k]
+			outstanding += balances[k]
+		}
+
+		if outstanding > 0 {
+			for k := range tranches {
+				flows[k].Interest[i] = roundToCent(agg.Interest[i] * balances[k] / outstanding)
+			}
+		}
+
+		available := agg.Principal[i] + agg.PrepayAmountArr[i]
+		for _, k := range order {
+			paid := min(balances[k], available)
+			flows[k].Principal[i] = roundToCent(paid)
+			balances[k] = roundToCent(balances[k] - paid)
+			available -= paid
+		}
+
+		for k := range tranches {
+			flows[k].EndBal[i] = balances[k]
+		}
+	}
+
+	return flows
+}
@@ -0,0 +1,59 @@
+package amortization
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApplyWaterfall_SeniorPaysDownFirst(t *testing.T) {
+	loans := []LoanInfo{
+		{ID: "LOAN001", Wam: 120, Wac: 5.0, Face: 600000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.10}},
+		{ID: "LOAN002", Wam: 120, Wac: 7.0, Face: 400000.0, PrepayInfo: PrepayInfo{PrepayCPR: 0.10}},
+	}
+	tables := make([]AmortizationTable, len(loans))
+	for i := range loans {
+		tables[i] = loans[i].GetAmortizationTable()
+	}
+	pool := AggregateTables(tables)
+
+	// Subordinate listed first to check allocation follows priority, not order
+	flows := ApplyWaterfall(pool, []Tranche{
+		{Name: "B", Size: 200000.0, Priority: 2},
+		{Name: "A", Size: 800000.0, Priority: 1},
+	})
+	sub, senior := flows[0], flows[1]
+	if sub.Name != "B" || senior.Name != "A" {
+		t.Fatalf("Expected results in the given order, got %s, %s", sub.Name, senior.Name)
+	}
+
+	// Interest is shared 80/20 while both tranches are outstanding
+	if got := senior.Interest[0] / pool.Interest[0]; math.Abs(got-0.8) > 1e-6 {
+		t.Errorf("Expected senior to receive 80%% of first-period interest, got %.6f", got)
+	}
+
+	paidOff := -1
+	for i := range pool.Period {
+		if senior.EndBal[i] > 0 && sub.Principal[i] != 0 {
+			t.Fatalf("Period %d: subordinate received principal before the senior was retired", i+1)
+		}
+		if paidOff < 0 && senior.EndBal[i] == 0 {
+			paidOff = i
+		}
+
+		allocated := senior.Principal[i] + sub.Principal[i]
+		if want := pool.Principal[i] + pool.PrepayAmountArr[i]; math.Abs(allocated-want) > 0.011 {
+			t.Errorf("Period %d: allocated principal %.2f, pool paid %.2f", i+1, allocated, want)
+		}
+	}
+	if paidOff < 0 || paidOff == len(pool.Period)-1 {
+		t.Fatalf("Expected the senior to pay off before the pool, got period %d", paidOff+1)
+	}
+	if sub.BegBal[paidOff] != 200000.0 {
+		t.Errorf("Expected the subordinate untouched until the senior pays off, got %.2f", sub.BegBal[paidOff])
+	}
+
+	last := len(pool.Period) - 1
+	if math.Abs(sub.EndBal[last]) > 0.01 {
+		t.Errorf("Expected the subordinate retired with the pool, got %.2f", sub.EndBal[last])
+	}
+}
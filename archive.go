@@ -0,0 +1,91 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// maxArchiveLoans bounds an archive request, since every table is held in
+// memory until the ZIP has been written
+const maxArchiveLoans = 500
+
+// archiveLoans serves POST /loans/archive. The loans are calculated
+// synchronously and returned as a ZIP holding one JSON file per loan, named
+// and enveloped like the files written under OUTPUT_PATH. Loans are not
+// added to the stored book.
+func archiveLoans(c *gin.Context) {
+	loans, ok := bindLoans(c)
+	if !ok {
+		return
+	}
+	if len(loans) > maxArchiveLoans {
+		respondError(c, http.StatusRequestEntityTooLarge, codeBatchTooLarge,
+			fmt.Sprintf("archive requests are limited to %d loans, got %d", maxArchiveLoans, len(loans)))
+		return
+	}
+	// Entry names embed the loan ID; validation rejects IDs with path
+	// separators or "..", so no entry can extract outside its directory
+	for i, loan := range loans {
+		if err := loan.Validate(); err != nil {
+			respondError(c, http.StatusBadRequest, codeValidationFailed,
+				fmt.Sprintf("Loan %d validation failed: %s", i, err.Error()))
+			return
+		}
+	}
+
+	if !admitBatch(c, len(loans)) {
+		return
+	}
+	defer releaseLoans(len(loans))
+
+	reqLog := requestLogger(c)
+	runID := newRunID()
+	names := make([]string, len(loans))
+	payloads := make([]gin.H, len(loans))
+	calculateBatch(loans, func(index int, l amortization.LoanInfo) {
+		now := localNow()
+		names[index] = outputFileName(l.ID, assumptionsHash(l), now)
+
+		amortTable, err := calculateTableWithin(reqLog, &l)
+		if err != nil {
+			payloads[index] = failedResult(l.ID, err)
+			return
+		}
+		payloads[index] = gin.H{
+			"run_id":     runID,
+			"loan_id":    l.ID,
+			"local_date": formatTimestamp(now),
+			"cashflow":   amortTable,
+		}
+	}, nil)
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="cashflows_%s.zip"`, runID))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	// Headers are sent with the first entry, so failures past this point can
+	// only be logged; the client sees a truncated archive
+	archive := zip.NewWriter(c.Writer)
+	for i := range payloads {
+		entry, err := archive.Create(names[i])
+		if err == nil {
+			err = encodeOutput(entry, payloads[i])
+		}
+		if err != nil {
+			reqLog.Error("failed to write archive entry",
+				slog.String("run_id", runID),
+				slog.String("entry", names[i]),
+				slog.Any("error", err),
+			)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		reqLog.Error("failed to finish archive", slog.String("run_id", runID), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postArchive(router http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/loans/archive", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestArchiveLoans_ReturnsOneEntryPerLoan(t *testing.T) {
+	router := newTestRouter()
+	w := postArchive(router, `[
+		{"id": "ARCH1", "wam": 12, "wac": 4.5, "face": 1000},
+		{"id": "ARCH2", "wam": 24, "wac": 6.0, "face": 5000, "prepay_cpr": 0.05}
+	]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("expected application/zip, got %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="cashflows_`) {
+		t.Errorf("expected a download filename, got %q", cd)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("response is not a valid ZIP: %v", err)
+	}
+	if len(archive.File) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(archive.File))
+	}
+
+	for i, f := range archive.File {
+		wantID := fmt.Sprintf("ARCH%d", i+1)
+		if !strings.HasPrefix(f.Name, "cashflow_"+wantID+"_") || !strings.HasSuffix(f.Name, ".json") {
+			t.Errorf("unexpected entry name %q", f.Name)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", f.Name, err)
+		}
+
+		var envelope struct {
+			RunID    string `json:"run_id"`
+			LoanID   string `json:"loan_id"`
+			Cashflow struct {
+				EndBal []float64 `json:"end_bal"`
+			} `json:"cashflow"`
+		}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			t.Fatalf("%s is not valid JSON: %v", f.Name, err)
+		}
+		if envelope.LoanID != wantID || envelope.RunID == "" || len(envelope.Cashflow.EndBal) == 0 {
+			t.Errorf("%s: unexpected envelope %+v", f.Name, envelope)
+		}
+	}
+}
+
+func TestArchiveLoans_RejectsOversizedBatch(t *testing.T) {
+	router := newTestRouter()
+	loans := make([]string, maxArchiveLoans+1)
+	for i := range loans {
+		loans[i] = `{"id": "LOAN", "wam": 12, "wac": 4.5, "face": 1000}`
+	}
+
+	w := postArchive(router, "["+strings.Join(loans, ",")+"]")
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), codeBatchTooLarge) {
+		t.Errorf("expected %s, got %s", codeBatchTooLarge, w.Body.String())
+	}
+}
+
+func TestArchiveLoans_RejectsZipSlipIDs(t *testing.T) {
+	router := newTestRouter()
+	for _, id := range []string{"../../evil", `..\\evil`, "nested/evil"} {
+		w := postArchive(router, fmt.Sprintf(`[{"id": %q, "wam": 12, "wac": 4.5, "face": 1000}]`, id))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("ID %q: expected status 400, got %d", id, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct == "application/zip" {
+			t.Errorf("ID %q: expected no archive, got %s", id, ct)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// useAuthToken sets the required bearer token for the test
+func useAuthToken(t *testing.T, token string) {
+	original := authToken
+	authToken = token
+	t.Cleanup(func() { authToken = original })
+}
+
+func TestRequireToken(t *testing.T) {
+	useAuthToken(t, "s3cret")
+	router := newTestRouter()
+
+	tests := []struct {
+		name          string
+		method, path  string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "missing token", method: http.MethodGet, path: "/loans", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, path: "/loans", authorization: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "wrong scheme", method: http.MethodGet, path: "/loans", authorization: "Basic s3cret", wantStatus: http.StatusUnauthorized},
+		{name: "nested route", method: http.MethodGet, path: "/loans/ANY/summary", wantStatus: http.StatusUnauthorized},
+		{name: "post without token", method: http.MethodPost, path: "/loans", wantStatus: http.StatusUnauthorized},
+		{name: "authorized", method: http.MethodGet, path: "/loans", authorization: "Bearer s3cret", wantStatus: http.StatusOK},
+		{name: "authorized post", method: http.MethodPost, path: "/loans", authorization: "Bearer s3cret", wantStatus: http.StatusOK},
+		{name: "info is public", method: http.MethodGet, path: "/info", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`[{"id": "AUTH001", "wam": 12, "wac": 4.5, "face": 1000}]`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusUnauthorized {
+				return
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("response is not valid JSON: %v", err)
+			}
+			if body["code"] != codeUnauthorized {
+				t.Errorf("expected code %q, got %v", codeUnauthorized, body["code"])
+			}
+			if w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestRequireToken_DisabledByDefault(t *testing.T) {
+	useAuthToken(t, "")
+	router := newTestRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/loans", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 without a configured token, got %d", w.Code)
+	}
+}
+
+func TestAuthTokenFromConfig(t *testing.T) {
+	t.Setenv("AUTH_TOKEN", "")
+	if token, err := authTokenFromConfig(map[string]interface{}{}); err != nil || token != "" {
+		t.Errorf("expected auth disabled when unset, got %q, %v", token, err)
+	}
+	if token, err := authTokenFromConfig(map[string]interface{}{"AUTH_TOKEN": "from-config"}); err != nil || token != "from-config" {
+		t.Errorf("expected the configured token, got %q, %v", token, err)
+	}
+	if _, err := authTokenFromConfig(map[string]interface{}{"AUTH_TOKEN": 42.0}); err == nil {
+		t.Error("expected an error for a non-string token")
+	}
+
+	t.Setenv("AUTH_TOKEN", "from-env")
+	if token, err := authTokenFromConfig(map[string]interface{}{"AUTH_TOKEN": "from-config"}); err != nil || token != "from-env" {
+		t.Errorf("expected the environment to override the config, got %q, %v", token, err)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	config := map[string]interface{}{"AUTH_TOKEN": "s3cret", "MAX_WORKERS": 4.0}
+	redacted := redactConfig(config)
+	if redacted["AUTH_TOKEN"] != "[redacted]" || redacted["MAX_WORKERS"] != 4.0 {
+		t.Errorf("expected only the token masked, got %v", redacted)
+	}
+	if config["AUTH_TOKEN"] != "s3cret" {
+		t.Error("expected the original config to be left unmodified")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// batchEntry is one independent calculation in a POST /batch request: a loan
+// and the options shaping its result
+type batchEntry struct {
+	Loan    amortization.LoanInfo `json:"loan"`
+	Summary bool                  `json:"summary,omitempty"` // Return the summary instead of the table
+	Trim    bool                  `json:"trim,omitempty"`    // Drop trailing zero-balance periods
+	Lean    bool                  `json:"lean,omitempty"`    // Omit empty and zero-valued fields from the table
+}
+
+// calculateBatchQueries serves POST /batch. The body maps caller-chosen
+// request IDs to entries, and the response maps the same IDs to each entry's
+// table or summary. Entries are validated and calculated independently, so a
+// failing entry is reported under its ID without failing the others. Unlike
+// POST /loans nothing is stored or written to OUTPUT_PATH.
+func calculateBatchQueries(c *gin.Context) {
+	var entries map[string]batchEntry
+	err := c.ShouldBindJSON(&entries)
+	switch {
+	case errors.Is(err, io.EOF), err == nil && entries == nil:
+		respondError(c, http.StatusBadRequest, codeInvalidJSON, "request body must be a JSON object of request IDs to calculations")
+		return
+	case err != nil:
+		respondBindError(c, err)
+		return
+	}
+
+	results := make(map[string]gin.H, len(entries))
+	var ids []string
+	var loans []amortization.LoanInfo
+	for id, entry := range entries {
+		if err := entry.Loan.Validate(); err != nil {
+			results[id] = gin.H{"loan_id": entry.Loan.ID, "status": "failed", "code": codeValidationFailed, "error": err.Error()}
+			continue
+		}
+		ids = append(ids, id)
+	}
+	// Schedule in ID order so runs of the same batch are reproducible
+	sort.Strings(ids)
+	for _, id := range ids {
+		loans = append(loans, entries[id].Loan)
+	}
+
+	if !admitBatch(c, len(loans)) {
+		return
+	}
+	defer releaseLoans(len(loans))
+
+	reqLog := requestLogger(c)
+	computed := make([]gin.H, len(loans))
+	calculateBatch(loans, func(index int, l amortization.LoanInfo) {
+		entry := entries[ids[index]]
+		amortTable, err := calculateTableWithin(reqLog, &l)
+		if err != nil {
+			computed[index] = failedResult(l.ID, err)
+			return
+		}
+		if entry.Trim {
+			amortTable.Trim()
+		}
+
+		result := gin.H{"loan_id": l.ID}
+		if entry.Summary {
+			result["summary"] = amortTable.Summary()
+		} else {
+			result["cashflow"] = leanIf(entry.Lean, &amortTable)
+		}
+		computed[index] = result
+	}, nil)
+	for i, id := range ids {
+		results[id] = computed[i]
+	}
+
+	failed := len(entries) - len(loans)
+	for _, result := range computed {
+		if result["status"] == "failed" {
+			failed++
+		}
+	}
+	respondJSON(c, http.StatusOK, gin.H{
+		"count":   len(entries),
+		"failed":  failed,
+		"results": results,
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postBatch(router http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCalculateBatchQueries_IndependentEntries(t *testing.T) {
+	router := newTestRouter()
+	w := postBatch(router, `{
+		"q1": {"loan": {"id": "BATCH001", "wam": 12, "wac": 6.0, "face": 12000}},
+		"q2": {"loan": {"id": "BATCH002", "wam": 360, "wac": 5.0, "face": -5}},
+		"q3": {"loan": {"id": "BATCH003", "wam": 360, "wac": 5.0, "face": 100000}, "summary": true}
+	}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Count   int `json:"count"`
+		Failed  int `json:"failed"`
+		Results map[string]struct {
+			LoanID   string `json:"loan_id"`
+			Status   string `json:"status"`
+			Code     string `json:"code"`
+			Cashflow *struct {
+				EndBal []float64 `json:"end_bal"`
+			} `json:"cashflow"`
+			Summary *struct {
+				TotalInterest float64 `json:"total_interest"`
+			} `json:"summary"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.Count != 3 || resp.Failed != 1 || len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results with 1 failure, got %+v", resp)
+	}
+
+	if q1 := resp.Results["q1"]; q1.LoanID != "BATCH001" || q1.Cashflow == nil || len(q1.Cashflow.EndBal) != 12 {
+		t.Errorf("expected a 12-period table for q1, got %+v", q1)
+	}
+	if q2 := resp.Results["q2"]; q2.Status != "failed" || q2.Code != codeValidationFailed || q2.Cashflow != nil {
+		t.Errorf("expected q2 to fail validation, got %+v", q2)
+	}
+	if q3 := resp.Results["q3"]; q3.Summary == nil || q3.Summary.TotalInterest <= 0 || q3.Cashflow != nil {
+		t.Errorf("expected only a summary for q3, got %+v", q3)
+	}
+
+	// Nothing is stored
+	if _, ok := findLoan("BATCH001"); ok {
+		t.Error("expected /batch not to store its loans")
+	}
+}
+
+func TestCalculateBatchQueries_RejectsNonObjectBody(t *testing.T) {
+	router := newTestRouter()
+	for _, body := range []string{"", "null", `[{"loan": {}}]`} {
+		if w := postBatch(router, body); w.Code != http.StatusBadRequest {
+			t.Errorf("body %q: expected status 400, got %d", body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// defaultCacheSize is the number of tables kept when CACHE_SIZE is not configured
+const defaultCacheSize = 512
+
+// tableCache keeps the most recently calculated tables keyed by the loan's
+// assumptions, evicting the least recently used beyond its capacity. A
+// capacity of zero disables it. It is safe for concurrent use.
+//
+// Cached tables and SMMs are shared between hits and must be treated as
+// read-only; reslicing them, as Trim does, is fine.
+type tableCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Front is the most recently used
+	entries  map[[sha256.Size]byte]*list.Element
+}
+
+// cachedTable is a calculated table with what the engine resolved on the loan
+type cachedTable struct {
+	key    [sha256.Size]byte
+	face   float64
+	smmArr []float64
+	table  amortization.AmortizationTable
+}
+
+func newTableCache(capacity int) *tableCache {
+	return &tableCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// resultCache caches tables across requests; sized from CACHE_SIZE
+var resultCache = newTableCache(defaultCacheSize)
+
+// cacheKey digests every assumption the engine reads from the loan. The ID
+// and tags label a loan without changing its cashflows, so they are left out
+// and identical loans share an entry. A loan with a custom PrepayModel cannot
+// be keyed, since the model is not serialized, and is reported uncacheable.
+func cacheKey(l amortization.LoanInfo) ([sha256.Size]byte, bool) {
+	if l.PrepayModel != nil {
+		return [sha256.Size]byte{}, false
+	}
+	l.ID = ""
+	l.Tags = nil
+	encoded, err := json.Marshal(l)
+	if err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256(encoded), true
+}
+
+// get returns the cached table for key and applies the resolved face and
+// SMMs to l, as calculating it would have
+func (c *tableCache) get(key [sha256.Size]byte, l *amortization.LoanInfo) (amortization.AmortizationTable, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return amortization.AmortizationTable{}, false
+	}
+	c.order.MoveToFront(elem)
+
+	entry := elem.Value.(*cachedTable)
+	l.Face = entry.face
+	l.SMMArr = entry.smmArr
+	return entry.table, true
+}
+
+// put records the table calculated for the loan under key
+func (c *tableCache) put(key [sha256.Size]byte, l amortization.LoanInfo, table amortization.AmortizationTable) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cachedTable{key: key, face: l.Face, smmArr: l.SMMArr, table: table})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedTable).key)
+	}
+}
+
+// len returns the number of cached tables
+func (c *tableCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// cacheSizeFromConfig reads CACHE_SIZE from the config, falling back to
+// defaultCacheSize when unset. Zero disables the cache.
+func cacheSizeFromConfig(config map[string]interface{}) (int, error) {
+	raw, ok := config["CACHE_SIZE"]
+	if !ok {
+		return defaultCacheSize, nil
+	}
+
+	value, ok := raw.(float64)
+	if !ok || value != math.Trunc(value) || value < 0 {
+		return 0, fmt.Errorf("CACHE_SIZE must be a non-negative integer, got %v", raw)
+	}
+
+	return int(value), nil
+}
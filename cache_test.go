@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// useResultCache swaps in an empty cache of the given capacity for the test
+func useResultCache(t *testing.T, capacity int) *tableCache {
+	original := resultCache
+	resultCache = newTableCache(capacity)
+	t.Cleanup(func() { resultCache = original })
+	return resultCache
+}
+
+// countCalculations wraps calculateTable with a counter for the test
+func countCalculations(t *testing.T) *int32 {
+	var calls int32
+	original := calculateTable
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		atomic.AddInt32(&calls, 1)
+		return original(l)
+	}
+	t.Cleanup(func() { calculateTable = original })
+	return &calls
+}
+
+func TestRequestCashflow_RepeatedLoanHitsCache(t *testing.T) {
+	useResultCache(t, 16)
+	calls := countCalculations(t)
+	router := newTestRouter()
+
+	body := `[{"id": "CACHE001", "wam": 360, "wac": 6.5, "face": 250000, "prepay_cpr": 0.06}]`
+	first := postLoans(t, router, body)
+	second := postLoans(t, router, body)
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d and %d", first.Code, second.Code)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Fatalf("expected the repeated loan to be calculated once, got %d calculations", n)
+	}
+
+	var a, b struct {
+		Results []struct {
+			Cashflow amortization.AmortizationTable `json:"cashflow"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(first.Body.Bytes(), &a); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if err := json.Unmarshal(second.Body.Bytes(), &b); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if a.Results[0].Cashflow.TotalInterest() != b.Results[0].Cashflow.TotalInterest() {
+		t.Error("expected the cached table to match the calculated one")
+	}
+
+	// A different CPR is a different assumption set
+	postLoans(t, router, `[{"id": "CACHE001", "wam": 360, "wac": 6.5, "face": 250000, "prepay_cpr": 0.08}]`)
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("expected a new CPR to miss the cache, got %d calculations", n)
+	}
+}
+
+func TestRequestCashflow_CacheDisabled(t *testing.T) {
+	useResultCache(t, 0)
+	calls := countCalculations(t)
+	router := newTestRouter()
+
+	body := `[{"id": "NOCACHE", "wam": 12, "wac": 4.5, "face": 1000}]`
+	postLoans(t, router, body)
+	postLoans(t, router, body)
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("expected every request to calculate with the cache disabled, got %d calculations", n)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	base := amortization.LoanInfo{ID: "A", Wam: 360, Wac: 5.0, Face: 100000.0}
+	key, ok := cacheKey(base)
+	if !ok {
+		t.Fatal("expected a plain loan to be cacheable")
+	}
+
+	relabeled := base
+	relabeled.ID = "B"
+	relabeled.Tags = map[string]string{"servicer": "XYZ"}
+	if k, _ := cacheKey(relabeled); k != key {
+		t.Error("expected the ID and tags not to change the key")
+	}
+
+	variants := map[string]func(*amortization.LoanInfo){
+		"cpr":          func(l *amortization.LoanInfo) { l.PrepayCPR = 0.06 },
+		"wac":          func(l *amortization.LoanInfo) { l.Wac = 5.125 },
+		"face":         func(l *amortization.LoanInfo) { l.Face = 100000.01 },
+		"smm cap":      func(l *amortization.LoanInfo) { l.SMMCap = 0.5 },
+		"escrow":       func(l *amortization.LoanInfo) { l.MonthlyEscrow = 250 },
+		"rate reset":   func(l *amortization.LoanInfo) { l.RateResets = []amortization.RateReset{{Period: 61, Rate: 7}} },
+		"integer cent": func(l *amortization.LoanInfo) { l.IntegerCents = true },
+	}
+	for name, change := range variants {
+		loan := base
+		change(&loan)
+		if k, _ := cacheKey(loan); k == key {
+			t.Errorf("%s: expected a different key", name)
+		}
+	}
+
+	custom := base
+	custom.PrepayModel = amortization.FlatCPR{CPR: 0.06}
+	if _, ok := cacheKey(custom); ok {
+		t.Error("expected a loan with a custom prepayment model to be uncacheable")
+	}
+}
+
+func TestTableCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newTableCache(2)
+	loans := []amortization.LoanInfo{
+		{ID: "A", Wam: 12, Wac: 4.0, Face: 1000.0},
+		{ID: "B", Wam: 12, Wac: 5.0, Face: 1000.0},
+		{ID: "C", Wam: 12, Wac: 6.0, Face: 1000.0},
+	}
+	keys := make([][sha256.Size]byte, len(loans))
+	for i, loan := range loans {
+		keys[i], _ = cacheKey(loan)
+	}
+
+	var scratch amortization.LoanInfo
+	cache.put(keys[0], loans[0], amortization.AmortizationTable{})
+	cache.put(keys[1], loans[1], amortization.AmortizationTable{})
+	cache.get(keys[0], &scratch) // A is now more recent than B
+	cache.put(keys[2], loans[2], amortization.AmortizationTable{})
+
+	if cache.len() != 2 {
+		t.Fatalf("expected 2 cached tables, got %d", cache.len())
+	}
+	if _, ok := cache.get(keys[1], &scratch); ok {
+		t.Error("expected the least recently used table to be evicted")
+	}
+	for _, i := range []int{0, 2} {
+		if _, ok := cache.get(keys[i], &scratch); !ok {
+			t.Errorf("expected loan %s to stay cached", loans[i].ID)
+		}
+	}
+}
+
+func TestCacheSizeFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    int
+		wantErr bool
+	}{
+		{name: "unset uses default", config: map[string]interface{}{}, want: defaultCacheSize},
+		{name: "configured", config: map[string]interface{}{"CACHE_SIZE": float64(64)}, want: 64},
+		{name: "zero disables", config: map[string]interface{}{"CACHE_SIZE": float64(0)}, want: 0},
+		{name: "negative", config: map[string]interface{}{"CACHE_SIZE": float64(-1)}, wantErr: true},
+		{name: "fractional", config: map[string]interface{}{"CACHE_SIZE": 1.5}, wantErr: true},
+		{name: "not a number", config: map[string]interface{}{"CACHE_SIZE": "64"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cacheSizeFromConfig(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// calculateFromQuery serves GET /calculate, amortizing one loan described by
+// query parameters (e.g. ?wam=360&wac=4.5&face=250000&cpr=0.05) for ad-hoc
+// checks and shareable links. wam, wac and face are required; cpr defaults to
+// no prepayment and id to "adhoc". The loan is not stored. With format=md
+// the schedule is returned as a Markdown table.
+func calculateFromQuery(c *gin.Context) {
+	markdown, maxRows, ok := markdownRequested(c)
+	if !ok {
+		return
+	}
+	loan := amortization.LoanInfo{ID: c.DefaultQuery("id", "adhoc")}
+
+	var err error
+	for _, param := range []struct {
+		name     string
+		dst      *float64
+		required bool
+	}{
+		{"wac", &loan.Wac, true},
+		{"face", &loan.Face, true},
+		{"cpr", &loan.PrepayCPR, false},
+	} {
+		if *param.dst, err = queryNumber(c, param.name, param.required); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidQuery, err.Error())
+			return
+		}
+	}
+	raw, ok := c.GetQuery("wam")
+	if !ok {
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, "wam is required")
+		return
+	}
+	if loan.Wam, err = strconv.ParseInt(raw, 10, 64); err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, fmt.Sprintf("wam must be a whole number of months, got %q", raw))
+		return
+	}
+
+	if err := loan.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, codeValidationFailed, err.Error())
+		return
+	}
+
+	amortTable, ok := calculateAdmitted(c, &loan)
+	if !ok {
+		return
+	}
+	if markdown {
+		respondMarkdownTable(c, &amortTable, maxRows)
+		return
+	}
+	respondJSON(c, http.StatusOK, gin.H{
+		"loan":     loan,
+		"cashflow": amortTable,
+	})
+}
+
+// queryNumber parses the named query parameter as a number. A missing
+// parameter is an error when required and 0 otherwise.
+func queryNumber(c *gin.Context, name string, required bool) (float64, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok {
+		if required {
+			return 0, fmt.Errorf("%s is required", name)
+		}
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number, got %q", name, raw)
+	}
+	return value, nil
+}
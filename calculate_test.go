@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+func getCalculate(router http.Handler, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/calculate?"+query, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCalculateFromQuery(t *testing.T) {
+	router := newTestRouter()
+	w := getCalculate(router, "wam=360&wac=4.5&face=250000&cpr=0.05")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Loan struct {
+			ID        string  `json:"id"`
+			Wam       int     `json:"wam"`
+			PrepayCPR float64 `json:"prepay_cpr"`
+		} `json:"loan"`
+		Cashflow struct {
+			BegBal   []float64 `json:"beg_bal"`
+			Interest []float64 `json:"interest"`
+		} `json:"cashflow"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.Loan.ID != "adhoc" || resp.Loan.Wam != 360 || resp.Loan.PrepayCPR != 0.05 {
+		t.Errorf("expected the loan parsed from the query, got %+v", resp.Loan)
+	}
+	if len(resp.Cashflow.BegBal) == 0 || resp.Cashflow.BegBal[0] != 250000 {
+		t.Fatalf("expected a table starting at 250000, got %v", resp.Cashflow.BegBal)
+	}
+	// 250000 * 4.5% / 12
+	if resp.Cashflow.Interest[0] != 937.5 {
+		t.Errorf("expected first interest 937.50, got %.2f", resp.Cashflow.Interest[0])
+	}
+}
+
+func TestCalculateFromQuery_BadParams(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    string
+		wantCode string
+		wantErr  string
+	}{
+		{name: "malformed wac", query: "wam=360&wac=abc&face=250000", wantCode: codeInvalidQuery, wantErr: `wac must be a number, got "abc"`},
+		{name: "missing face", query: "wam=360&wac=4.5", wantCode: codeInvalidQuery, wantErr: "face is required"},
+		{name: "missing wam", query: "wac=4.5&face=250000", wantCode: codeInvalidQuery, wantErr: "wam is required"},
+		{name: "fractional wam", query: "wam=360.5&wac=4.5&face=250000", wantCode: codeInvalidQuery, wantErr: "wam must be a whole number of months"},
+		{name: "invalid loan", query: "wam=360&wac=4.5&face=-1", wantCode: codeValidationFailed, wantErr: "face"},
+	}
+
+	router := newTestRouter()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := getCalculate(router, tc.query)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Code  string `json:"code"`
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response is not valid JSON: %v", err)
+			}
+			if resp.Code != tc.wantCode || !strings.Contains(resp.Error, tc.wantErr) {
+				t.Errorf("expected %s containing %q, got %s %q", tc.wantCode, tc.wantErr, resp.Code, resp.Error)
+			}
+		})
+	}
+}
+
+func TestCalculateFromQuery_RequiresToken(t *testing.T) {
+	useAuthToken(t, "s3cret")
+	router := newTestRouter()
+	if w := getCalculate(router, "wam=360&wac=4.5&face=250000"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without a token, got %d", w.Code)
+	}
+}
+
+func TestCalculateFromQuery_Timeout(t *testing.T) {
+	useResultCache(t, 0) // The stubbed calculation must run
+	originalPool, originalCalc, originalTimeout := workerPool, calculateTable, loanTimeout
+	workerPool = make(chan struct{}, 1)
+	loanTimeout = 20 * time.Millisecond
+	hang := make(chan struct{})
+	t.Cleanup(func() {
+		close(hang) // Let the abandoned calculation finish
+		workerPool, calculateTable, loanTimeout = originalPool, originalCalc, originalTimeout
+	})
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		<-hang
+		return l.GetAmortizationTable()
+	}
+
+	w := getCalculate(newTestRouter(), "wam=360&wac=4.5&face=250000")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), codeTimeout) {
+		t.Errorf("expected code %s, got %s", codeTimeout, w.Body.String())
+	}
+	if n := len(workerPool); n != 0 {
+		t.Errorf("expected the worker slot to be released, %d still held", n)
+	}
+	if n := pendingLoans.Load(); n != 0 {
+		t.Errorf("expected the queue reservation to be released, %d pending", n)
+	}
+}
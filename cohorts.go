@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// defaultBandWidth is the coupon band width used when ?band= is omitted
+const defaultBandWidth = 0.5
+
+// cohort is the aggregate of the stored loans in one coupon band
+type cohort struct {
+	Band  string  `json:"band"`  // Coupon band label, e.g. "3.50-4.00"
+	Count int     `json:"count"` // Number of loans in the band
+	Face  float64 `json:"face"`  // Total current face of the band
+	WAC   float64 `json:"wac"`   // Face-weighted average coupon of the band
+}
+
+// getLoanCohorts serves GET /loans/cohorts, grouping the stored loans into
+// coupon bands ?band= percentage points wide, ordered by coupon
+func getLoanCohorts(c *gin.Context) {
+	bandWidth := defaultBandWidth
+	if raw, ok := c.GetQuery("band"); ok {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 {
+			respondError(c, http.StatusBadRequest, codeInvalidQuery, fmt.Sprintf("band must be a positive number, got %q", raw))
+			return
+		}
+		bandWidth = parsed
+	}
+
+	buckets := amortization.BucketByWAC(loanSnapshot(), bandWidth)
+
+	cohorts := make([]cohort, 0, len(buckets))
+	for band, loans := range buckets {
+		face := 0.0
+		for _, loan := range loans {
+			face += loan.CurrentFace()
+		}
+		cohorts = append(cohorts, cohort{
+			Band:  band,
+			Count: len(loans),
+			Face:  face,
+			WAC:   amortization.PoolWAC(loans),
+		})
+	}
+	sort.Slice(cohorts, func(i, j int) bool { return cohorts[i].WAC < cohorts[j].WAC })
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"band_width": bandWidth,
+		"cohorts":    cohorts,
+	})
+}
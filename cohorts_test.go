@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetLoanCohorts(t *testing.T) {
+	router := newTestRouter()
+	body := `[
+		{"id": "COHORT001", "wam": 360, "wac": 13.1, "face": 100000, "tags": {"suite": "cohorts"}},
+		{"id": "COHORT002", "wam": 360, "wac": 13.4, "face": 50000, "tags": {"suite": "cohorts"}},
+		{"id": "COHORT003", "wam": 360, "wac": 14.0, "face": 25000, "tags": {"suite": "cohorts"}}
+	]`
+	if w := postLoans(t, router, body); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/loans/cohorts?band=0.5", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Cohorts []cohort `json:"cohorts"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+
+	// Other tests store loans too; these coupons are above any of theirs
+	bands := map[string]cohort{}
+	for _, c := range resp.Cohorts {
+		bands[c.Band] = c
+	}
+	if got := bands["13.00-13.50"]; got.Count != 2 || got.Face != 150000 {
+		t.Errorf("expected 2 loans with face 150000 in 13.00-13.50, got %+v", got)
+	}
+	if got := bands["14.00-14.50"]; got.Count != 1 || got.Face != 25000 {
+		t.Errorf("expected 1 loan with face 25000 in 14.00-14.50, got %+v", got)
+	}
+
+	for i := 1; i < len(resp.Cohorts); i++ {
+		if resp.Cohorts[i].WAC < resp.Cohorts[i-1].WAC {
+			t.Errorf("expected cohorts ordered by coupon, got %v", resp.Cohorts)
+			break
+		}
+	}
+}
+
+func TestGetLoanCohorts_InvalidBand(t *testing.T) {
+	router := newTestRouter()
+	for _, band := range []string{"0", "-1", "abc"} {
+		req := httptest.NewRequest(http.MethodGet, "/loans/cohorts?band="+band, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("band=%s: expected status 400, got %d", band, w.Code)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// compareRequest names the two loans POST /loans/compare runs. Each side is
+// given either inline or as the ID of a stored loan.
+type compareRequest struct {
+	Base      *amortization.LoanInfo `json:"base,omitempty"`
+	Compare   *amortization.LoanInfo `json:"compare,omitempty"`
+	BaseID    string                 `json:"base_id,omitempty"`
+	CompareID string                 `json:"compare_id,omitempty"`
+}
+
+// compareLoans serves POST /loans/compare: both loans are amortized and the
+// response carries each summary with the per-period and aggregate deltas of
+// compare against base
+func compareLoans(c *gin.Context) {
+	var req compareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	base, ok := resolveCompareSide(c, "base", req.Base, req.BaseID)
+	if !ok {
+		return
+	}
+	compare, ok := resolveCompareSide(c, "compare", req.Compare, req.CompareID)
+	if !ok {
+		return
+	}
+
+	loans := []amortization.LoanInfo{base, compare}
+	if !admitBatch(c, len(loans)) {
+		return
+	}
+	defer releaseLoans(len(loans))
+
+	reqLog := requestLogger(c)
+	tables := make([]amortization.AmortizationTable, len(loans))
+	errs := make([]error, len(loans))
+	calculateBatch(loans, func(index int, l amortization.LoanInfo) {
+		tables[index], errs[index] = calculateTableWithin(reqLog, &l)
+	}, nil)
+	for i, err := range errs {
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, failureCode(err),
+				fmt.Sprintf("loan %s: %s", loans[i].ID, err.Error()))
+			return
+		}
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"base":    gin.H{"loan_id": base.ID, "summary": tables[0].Summary()},
+		"compare": gin.H{"loan_id": compare.ID, "summary": tables[1].Summary()},
+		"diff":    amortization.DiffTables(tables[0], tables[1]),
+	})
+}
+
+// resolveCompareSide returns the loan for one side of a comparison, from the
+// inline loan or the stored loan with the ID, responding with the error and
+// returning false when neither or both are given, the ID is unknown, or the
+// inline loan is invalid
+func resolveCompareSide(c *gin.Context, side string, inline *amortization.LoanInfo, id string) (amortization.LoanInfo, bool) {
+	switch {
+	case (inline == nil) == (id == ""):
+		respondError(c, http.StatusBadRequest, codeValidationFailed,
+			fmt.Sprintf("exactly one of %s and %s_id is required", side, side))
+		return amortization.LoanInfo{}, false
+	case inline == nil:
+		loan, ok := findLoan(id)
+		if !ok {
+			respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("loan %s not found", id))
+		}
+		return loan, ok
+	}
+
+	if err := inline.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, codeValidationFailed,
+			fmt.Sprintf("%s loan validation failed: %s", side, err.Error()))
+		return amortization.LoanInfo{}, false
+	}
+	return *inline, true
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+func postCompare(router http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/loans/compare", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompareLoans_HigherCPRShortensWAL(t *testing.T) {
+	router := newTestRouter()
+	w := postCompare(router, `{
+		"base":    {"id": "CMP-BASE", "wam": 360, "wac": 6.0, "face": 300000, "prepay_cpr": 0.06},
+		"compare": {"id": "CMP-FAST", "wam": 360, "wac": 6.0, "face": 300000, "prepay_cpr": 0.20}
+	}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Base    struct{ Summary amortization.TableSummary } `json:"base"`
+		Compare struct{ Summary amortization.TableSummary } `json:"compare"`
+		Diff    amortization.TableDiff                      `json:"diff"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.Diff.WAL >= 0 {
+		t.Errorf("expected a negative WAL delta for the higher CPR, got %.4f", resp.Diff.WAL)
+	}
+	if want := resp.Compare.Summary.WAL - resp.Base.Summary.WAL; resp.Diff.WAL != want {
+		t.Errorf("expected the WAL delta to match the summaries, got %.6f vs %.6f", resp.Diff.WAL, want)
+	}
+	if resp.Diff.TotalInterest >= 0 {
+		t.Errorf("expected less interest at the higher CPR, got a delta of %.2f", resp.Diff.TotalInterest)
+	}
+	if len(resp.Diff.Interest) != 360 {
+		t.Errorf("expected 360 per-period deltas, got %d", len(resp.Diff.Interest))
+	}
+}
+
+func TestCompareLoans_StoredLoanByID(t *testing.T) {
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "CMP-STORED", "wam": 120, "wac": 5.0, "face": 100000}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	w := postCompare(router, `{"base_id": "CMP-STORED", "compare": {"id": "CMP-SHIFT", "wam": 120, "wac": 5.5, "face": 100000}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Diff amortization.TableDiff `json:"diff"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.Diff.TotalInterest <= 0 {
+		t.Errorf("expected a higher coupon to add interest, got a delta of %.2f", resp.Diff.TotalInterest)
+	}
+}
+
+func TestCompareLoans_RejectsBadInput(t *testing.T) {
+	router := newTestRouter()
+	valid := `{"id": "CMP-OK", "wam": 120, "wac": 5.0, "face": 100000}`
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "missing compare", body: `{"base": ` + valid + `}`, wantStatus: http.StatusBadRequest, wantCode: codeValidationFailed},
+		{name: "both inline and id", body: `{"base": ` + valid + `, "base_id": "X", "compare": ` + valid + `}`, wantStatus: http.StatusBadRequest, wantCode: codeValidationFailed},
+		{name: "invalid inline loan", body: `{"base": ` + valid + `, "compare": {"id": "BAD", "wam": 0, "wac": 5.0, "face": 1000}}`, wantStatus: http.StatusBadRequest, wantCode: codeValidationFailed},
+		{name: "unknown stored loan", body: `{"base": ` + valid + `, "compare_id": "CMP-MISSING"}`, wantStatus: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "malformed JSON", body: `{"base": `, wantStatus: http.StatusBadRequest, wantCode: codeInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postCompare(router, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("response is not valid JSON: %v", err)
+			}
+			if body["code"] != tt.wantCode {
+				t.Errorf("expected code %q, got %v", tt.wantCode, body["code"])
+			}
+		})
+	}
+}
@@ -2,13 +2,16 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
-// typeAssertion tries to convert an interface{} to a proper Go type and returns the string representation.
-// You can modify it to return the actual type instead of a string if needed.
+// convertTypes walks a decoded config value, keeping JSON's maps, arrays,
+// numbers, strings and booleans and stringifying anything else.
 func convertTypes(val interface{}) interface{} {
 	switch v := val.(type) {
 	case map[string]interface{}:
@@ -29,28 +32,70 @@ func convertTypes(val interface{}) interface{} {
 		return v
 	case string:
 		return v
+	case bool:
+		return v
 	default:
 		return fmt.Sprintf("%v", v)
 	}
 }
 
+// ReadConfig reads config.json from the working directory, or from
+// CONFIG_PATH when OCP_ENV is set, overlaid by config.<PROFILE>.json when
+// PROFILE names one. When CONFIG_DIR is set the config is instead read from
+// a directory of key files, as Kubernetes mounts a ConfigMap. A missing or
+// malformed file is returned as an error.
 func ReadConfig() (map[string]interface{}, error) {
+	if CONFIG_DIR := os.Getenv("CONFIG_DIR"); CONFIG_DIR != "" {
+		log.Println("Reading in config keys from:", CONFIG_DIR)
+		result, err := readConfigDir(CONFIG_DIR)
+		if err != nil {
+			return nil, err
+		}
+		return convertTypes(result).(map[string]interface{}), nil
+	}
+
 	OCP_ENV := os.Getenv("OCP_ENV")
 	CONFIG_PATH := os.Getenv("CONFIG_PATH")
+	PROFILE := os.Getenv("PROFILE")
 
-	var config_path_file = ""
+	var config_dir = "./"
 
-	if OCP_ENV == "" {
-		config_path_file = "./config.json"
-	} else {
-		config_path_file = CONFIG_PATH + "config.json"
+	if OCP_ENV != "" {
+		config_dir = CONFIG_PATH
 	}
 
+	config_path_file := config_dir + "config.json"
 	log.Println("Reading in config from:", config_path_file)
-	file, err := os.Open(config_path_file)
+	result, err := readConfigFile(config_path_file)
+	if err != nil {
+		return nil, err
+	}
+
+	// A profile overlays config.<profile>.json from the same directory
+	if PROFILE != "" {
+		profile_path_file := config_dir + "config." + PROFILE + ".json"
+		overrides, err := readConfigFile(profile_path_file)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			log.Println("No config for profile", PROFILE, "at", profile_path_file, "- using base config only")
+		case err != nil:
+			return nil, err
+		default:
+			log.Println("Applying config profile from:", profile_path_file)
+			result = mergeConfig(result, overrides)
+		}
+	}
+
+	result = convertTypes(result).(map[string]interface{})
+
+	return result, nil
+}
 
+// readConfigFile decodes a JSON config file into a map
+func readConfigFile(path string) (map[string]interface{}, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer file.Close()
 
@@ -58,10 +103,75 @@ func ReadConfig() (map[string]interface{}, error) {
 	var result map[string]interface{}
 	decoder := json.NewDecoder(file)
 	if err := decoder.Decode(&result); err != nil {
-		panic(err)
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
 	}
+	return result, nil
+}
 
-	result = convertTypes(result).(map[string]interface{})
+// readConfigDir reads each regular file in dir as a key named by the file
+// and valued by its contents. Values that parse as a JSON number or boolean
+// are coerced to one, so "400" reads as 400 just as it would from
+// config.json; anything else is kept as a string with surrounding
+// whitespace trimmed. Hidden entries, such as the ..data links Kubernetes
+// maintains, and subdirectories are skipped.
+func readConfigDir(dir string) (map[string]interface{}, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// ConfigMap keys are symlinks into ..data, so stat through them
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		result[entry.Name()] = coerceValue(strings.TrimSpace(string(raw)))
+	}
+	return result, nil
+}
+
+// coerceValue returns value as a float64 or bool when it is a JSON number or
+// boolean literal, and unchanged otherwise
+func coerceValue(value string) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+		switch decoded.(type) {
+		case float64, bool:
+			return decoded
+		}
+	}
+	return value
+}
 
-	return result, err
+// mergeConfig overlays overrides onto base. Nested objects are merged key by
+// key; any other override value replaces the base value.
+func mergeConfig(base, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			merged[key] = mergeConfig(baseMap, overrideMap)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
 }
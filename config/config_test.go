@@ -2,8 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -109,3 +111,159 @@ func TestConvertTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestReadConfig_Profile(t *testing.T) {
+	dir := t.TempDir()
+	writeTempConfig(t, dir, map[string]interface{}{
+		"LOG_PATH":    "./",
+		"MAX_WORKERS": 100,
+		"TUNING":      map[string]interface{}{"batch": 10, "retries": 3},
+	})
+	prodConfig, _ := json.Marshal(map[string]interface{}{
+		"MAX_WORKERS":  400,
+		"COMPACT_JSON": true,
+		"TUNING":       map[string]interface{}{"batch": 50},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "config.prod.json"), prodConfig, 0644); err != nil {
+		t.Fatalf("Failed to write profile config: %v", err)
+	}
+
+	t.Setenv("OCP_ENV", "true")
+	t.Setenv("CONFIG_PATH", dir+string(os.PathSeparator))
+	t.Setenv("PROFILE", "prod")
+
+	result, err := ReadConfig()
+	if err != nil {
+		t.Fatalf("ReadConfig returned error: %v", err)
+	}
+
+	// Overridden keys win
+	if result["MAX_WORKERS"] != float64(400) {
+		t.Errorf("Expected profile MAX_WORKERS 400, got %v", result["MAX_WORKERS"])
+	}
+	if result["COMPACT_JSON"] != true {
+		t.Errorf("Expected profile COMPACT_JSON true, got %v", result["COMPACT_JSON"])
+	}
+	// Base keys the profile does not mention remain
+	if result["LOG_PATH"] != "./" {
+		t.Errorf("Expected base LOG_PATH ./, got %v", result["LOG_PATH"])
+	}
+	tuning := result["TUNING"].(map[string]interface{})
+	if tuning["batch"] != float64(50) || tuning["retries"] != float64(3) {
+		t.Errorf("Expected nested profile override merged onto base, got %v", tuning)
+	}
+}
+
+func TestReadConfig_MissingProfileFallsBack(t *testing.T) {
+	dir := t.TempDir()
+	writeTempConfig(t, dir, map[string]interface{}{"MAX_WORKERS": 100})
+
+	t.Setenv("OCP_ENV", "true")
+	t.Setenv("CONFIG_PATH", dir+string(os.PathSeparator))
+	t.Setenv("PROFILE", "staging")
+
+	result, err := ReadConfig()
+	if err != nil {
+		t.Fatalf("ReadConfig returned error: %v", err)
+	}
+	if result["MAX_WORKERS"] != float64(100) {
+		t.Errorf("Expected base MAX_WORKERS 100, got %v", result["MAX_WORKERS"])
+	}
+}
+
+func TestReadConfig_MissingFileReturnsError(t *testing.T) {
+	t.Setenv("OCP_ENV", "true")
+	t.Setenv("CONFIG_PATH", t.TempDir()+string(os.PathSeparator))
+	t.Setenv("PROFILE", "")
+
+	result, err := ReadConfig()
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a not-exist error instead of a panic, got %v", err)
+	}
+	if result != nil {
+		t.Errorf("Expected no config on error, got %v", result)
+	}
+}
+
+func TestReadConfig_MalformedProfileReturnsError(t *testing.T) {
+	dir := t.TempDir()
+	writeTempConfig(t, dir, map[string]interface{}{"MAX_WORKERS": 100})
+	if err := os.WriteFile(filepath.Join(dir, "config.prod.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to write profile config: %v", err)
+	}
+
+	t.Setenv("OCP_ENV", "true")
+	t.Setenv("CONFIG_PATH", dir+string(os.PathSeparator))
+	t.Setenv("PROFILE", "prod")
+
+	if _, err := ReadConfig(); err == nil || !strings.Contains(err.Error(), "config.prod.json") {
+		t.Errorf("Expected a decoding error naming the profile file, got %v", err)
+	}
+}
+
+func TestReadConfig_ConfigDir(t *testing.T) {
+	// Mimic a mounted ConfigMap: each key is a symlink into a ..data
+	// directory that Kubernetes swaps atomically on update
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2026_10_16_00_00_00.000000001")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	keys := map[string]string{
+		"MAX_WORKERS":   "400\n",
+		"COMPACT_JSON":  "true",
+		"LOG_PATH":      "/var/log/andy-warhol/\n",
+		"ROUNDING_MODE": "half_even",
+		"BUILD":         "0x1F",
+	}
+	for key, value := range keys {
+		if err := os.WriteFile(filepath.Join(data, key), []byte(value), 0644); err != nil {
+			t.Fatalf("Failed to write key file: %v", err)
+		}
+		if err := os.Symlink(filepath.Join(filepath.Base(data), key), filepath.Join(dir, key)); err != nil {
+			t.Fatalf("Failed to link key file: %v", err)
+		}
+	}
+	if err := os.Symlink(filepath.Base(data), filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("Failed to link data dir: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+
+	// The directory wins over any config.json
+	cfgDir := t.TempDir()
+	writeTempConfig(t, cfgDir, map[string]interface{}{"MAX_WORKERS": 100, "FROM_FILE": true})
+	t.Setenv("OCP_ENV", "true")
+	t.Setenv("CONFIG_PATH", cfgDir+string(os.PathSeparator))
+	t.Setenv("CONFIG_DIR", dir)
+
+	result, err := ReadConfig()
+	if err != nil {
+		t.Fatalf("ReadConfig returned error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"MAX_WORKERS":   float64(400),
+		"COMPACT_JSON":  true,
+		"LOG_PATH":      "/var/log/andy-warhol/",
+		"ROUNDING_MODE": "half_even",
+		"BUILD":         "0x1F",
+	}
+	if len(result) != len(expected) {
+		t.Errorf("Expected %d keys, got %v", len(expected), result)
+	}
+	for key, want := range expected {
+		if result[key] != want {
+			t.Errorf("Key %s: expected %#v, got %#v", key, want, result[key])
+		}
+	}
+}
+
+func TestReadConfig_MissingConfigDir(t *testing.T) {
+	t.Setenv("CONFIG_DIR", filepath.Join(t.TempDir(), "absent"))
+
+	if _, err := ReadConfig(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+	}
+}
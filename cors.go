@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Methods and headers a browser may use cross-origin when the config does
+// not list its own
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", requestIDHeader}
+)
+
+// corsPolicy says which browser origins may call the API. A nil policy
+// disables CORS, so browsers block cross-origin calls as before.
+type corsPolicy struct {
+	origins map[string]bool // "*" allows any origin
+	methods string
+	headers string
+}
+
+// cors is the policy applied to every request; set from CORS_ALLOWED_ORIGINS
+var cors *corsPolicy
+
+// allows reports whether origin may call the API
+func (p *corsPolicy) allows(origin string) bool {
+	return origin != "" && (p.origins["*"] || p.origins[origin])
+}
+
+// handleCORS adds the CORS response headers for allowed origins and answers
+// their preflight OPTIONS requests with 204. Requests from other origins, and
+// every request while CORS is disabled, pass through untouched.
+func handleCORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if cors == nil || !cors.allows(origin) {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+		c.Header("Access-Control-Expose-Headers", requestIDHeader+", Retry-After")
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", cors.methods)
+			c.Header("Access-Control-Allow-Headers", cors.headers)
+			c.Header("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// corsFromConfig reads CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS and
+// CORS_ALLOWED_HEADERS from the config. Without any allowed origins CORS is
+// disabled and nil is returned; methods and headers default to
+// defaultCORSMethods and defaultCORSHeaders.
+func corsFromConfig(config map[string]interface{}) (*corsPolicy, error) {
+	origins, err := stringListFromConfig(config, "CORS_ALLOWED_ORIGINS", nil)
+	if err != nil || len(origins) == 0 {
+		return nil, err
+	}
+	methods, err := stringListFromConfig(config, "CORS_ALLOWED_METHODS", defaultCORSMethods)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := stringListFromConfig(config, "CORS_ALLOWED_HEADERS", defaultCORSHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &corsPolicy{
+		origins: make(map[string]bool, len(origins)),
+		methods: strings.Join(methods, ", "),
+		headers: strings.Join(headers, ", "),
+	}
+	for _, origin := range origins {
+		policy.origins[origin] = true
+	}
+	return policy, nil
+}
+
+// stringListFromConfig reads key as an array of strings, returning fallback
+// when it is unset
+func stringListFromConfig(config map[string]interface{}, key string, fallback []string) ([]string, error) {
+	raw, ok := config[key]
+	if !ok {
+		return fallback, nil
+	}
+
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of strings, got %v", key, raw)
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		if values[i], ok = item.(string); !ok || values[i] == "" {
+			return nil, fmt.Errorf("%s must be an array of strings, got %v", key, raw)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// useCORS applies the CORS policy read from config for the test
+func useCORS(t *testing.T, config map[string]interface{}) {
+	policy, err := corsFromConfig(config)
+	if err != nil {
+		t.Fatalf("corsFromConfig failed: %v", err)
+	}
+	original := cors
+	cors = policy
+	t.Cleanup(func() { cors = original })
+}
+
+func preflight(router http.Handler, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/loans", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleCORS_PreflightFromConfiguredOrigin(t *testing.T) {
+	useCORS(t, map[string]interface{}{"CORS_ALLOWED_ORIGINS": []interface{}{"https://dashboard.example.com"}})
+	useAuthToken(t, "s3cret") // Preflights carry no credentials
+	router := newTestRouter()
+
+	w := preflight(router, "https://dashboard.example.com")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://dashboard.example.com" {
+		t.Errorf("expected the origin to be allowed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, OPTIONS" {
+		t.Errorf("expected the default methods, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization, X-Request-ID" {
+		t.Errorf("expected the default headers, got %q", got)
+	}
+
+	// The actual request carries the header too
+	req := httptest.NewRequest(http.MethodGet, "/info", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://dashboard.example.com" {
+		t.Errorf("expected the origin to be allowed on GET /info, got %q", got)
+	}
+}
+
+func TestHandleCORS_OtherOriginsAndDisabled(t *testing.T) {
+	useCORS(t, map[string]interface{}{
+		"CORS_ALLOWED_ORIGINS": []interface{}{"https://dashboard.example.com"},
+		"CORS_ALLOWED_METHODS": []interface{}{"GET"},
+	})
+	router := newTestRouter()
+
+	if w := preflight(router, "https://evil.example.com"); w.Header().Get("Access-Control-Allow-Origin") != "" || w.Code == http.StatusNoContent {
+		t.Errorf("expected no CORS approval for an unlisted origin, got %d %v", w.Code, w.Header())
+	}
+	if got := preflight(router, "https://dashboard.example.com").Header().Get("Access-Control-Allow-Methods"); got != "GET" {
+		t.Errorf("expected the configured methods, got %q", got)
+	}
+
+	useCORS(t, map[string]interface{}{})
+	if w := preflight(router, "https://dashboard.example.com"); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected CORS disabled by default, got %v", w.Header())
+	}
+}
+
+func TestCORSFromConfig_Rejects(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"CORS_ALLOWED_ORIGINS": "https://dashboard.example.com"},
+		{"CORS_ALLOWED_ORIGINS": []interface{}{"https://dashboard.example.com", 7.0}},
+		{"CORS_ALLOWED_ORIGINS": []interface{}{"*"}, "CORS_ALLOWED_HEADERS": []interface{}{""}},
+	} {
+		if _, err := corsFromConfig(config); err == nil {
+			t.Errorf("expected an error for %v", config)
+		}
+	}
+}
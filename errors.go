@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes returned in the "code" field of every error response. Clients
+// should branch on the code; the "error" message is for humans and may change.
+const (
+	codeInvalidJSON      = "invalid_json"
+	codeValidationFailed = "validation_failed"
+	codeInvalidQuery     = "invalid_query"
+	codeNotFound         = "not_found"
+	codeBodyTooLarge     = "body_too_large"
+	codeInternal         = "internal_error"
+	codeTimeout          = "calculation_timeout"
+	codeQueueFull        = "queue_full"
+	codeBatchTooLarge    = "batch_too_large"
+	codeUnauthorized     = "unauthorized"
+)
+
+// errorCodes documents each code for /info
+var errorCodes = map[string]string{
+	codeInvalidJSON:      "request body is not valid JSON for the endpoint",
+	codeValidationFailed: "a loan or input failed validation",
+	codeInvalidQuery:     "a query parameter is malformed or out of range",
+	codeNotFound:         "the requested resource does not exist",
+	codeBodyTooLarge:     "request body exceeds MAX_BODY_BYTES",
+	codeInternal:         "unexpected server failure; the request ID identifies it in the logs",
+	codeTimeout:          "a loan's calculation exceeded LOAN_TIMEOUT_SECONDS",
+	codeQueueFull:        "the worker pool and its queue (MAX_QUEUE_DEPTH) are full; retry after Retry-After seconds",
+	codeBatchTooLarge:    "a batch holds more loans than the worker pool and its queue (MAX_WORKERS plus MAX_QUEUE_DEPTH) can hold, or an archive request more loans than it allows; split it or raise the limits",
+	codeUnauthorized:     "the /loans, /calculate and /batch routes require the bearer token configured as AUTH_TOKEN",
+}
+
+// respondError writes the error envelope shared by every endpoint
+func respondError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, gin.H{"code": code, "error": message})
+}
+
+// respondBindError reports a request binding error: 413 when the body
+// exceeded maxBodyBytes, 400 otherwise.
+func respondBindError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge, err.Error())
+		return
+	}
+	respondError(c, http.StatusBadRequest, codeInvalidJSON, err.Error())
+}
+
+// recoverJSON turns a panic in a handler into a logged stack trace and a JSON
+// 500 in the shared error envelope, in place of gin's plain-text Recovery
+func recoverJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				requestLogger(c).Error("panic recovered",
+					slog.String("method", c.Request.Method),
+					slog.String("path", c.Request.URL.Path),
+					slog.String("panic", fmt.Sprint(r)),
+					slog.String("stack", string(debug.Stack())),
+				)
+				if !c.Writer.Written() {
+					c.Abort()
+					respondError(c, http.StatusInternalServerError, codeInternal, "internal server error")
+				}
+			}
+		}()
+		c.Next()
+	}
+}
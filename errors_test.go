@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestErrorResponses_IncludeCode(t *testing.T) {
+	original := maxBodyBytes
+	maxBodyBytes = 1024
+	t.Cleanup(func() { maxBodyBytes = original })
+
+	testCases := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "malformed json", method: http.MethodPost, url: "/loans", body: `[{"id": `, status: http.StatusBadRequest, code: codeInvalidJSON},
+		{name: "validation", method: http.MethodPost, url: "/loans", body: `[{"id": "ERR001", "wam": 0, "wac": 5.0, "face": 1000}]`, status: http.StatusBadRequest, code: codeValidationFailed},
+		{name: "bad layout", method: http.MethodPost, url: "/loans?layout=diagonal", body: `[{"id": "ERR002", "wam": 12, "wac": 5.0, "face": 1000}]`, status: http.StatusBadRequest, code: codeInvalidQuery},
+		{name: "bad tag", method: http.MethodGet, url: "/loans?tag=novalue", status: http.StatusBadRequest, code: codeInvalidQuery},
+		{name: "unknown loan", method: http.MethodGet, url: "/loans/ERR404/summary", status: http.StatusNotFound, code: codeNotFound},
+		{name: "too large", method: http.MethodPost, url: "/loans", body: "[" + strings.Repeat(" ", 2048) + "]", status: http.StatusRequestEntityTooLarge, code: codeBodyTooLarge},
+		{name: "payment below interest", method: http.MethodPost, url: "/loans/from-payment", body: `{"face": 100000, "wac": 6.0, "payment": 100}`, status: http.StatusBadRequest, code: codeValidationFailed},
+	}
+
+	router := newTestRouter()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Code  string `json:"code"`
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response is not valid JSON: %v", err)
+			}
+			if resp.Code != tc.code {
+				t.Errorf("expected code %q, got %q", tc.code, resp.Code)
+			}
+			if resp.Error == "" {
+				t.Error("expected a human-readable error message")
+			}
+		})
+	}
+}
+
+func TestGetServiceInfo_DocumentsErrorCodes(t *testing.T) {
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodGet, "/info", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var info struct {
+		ErrorCodes map[string]string `json:"error_codes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	for code := range errorCodes {
+		if info.ErrorCodes[code] == "" {
+			t.Errorf("expected /info to document error code %q", code)
+		}
+	}
+}
+
+func TestRecoverJSON_ReturnsJSONAndLogsStack(t *testing.T) {
+	buf := captureLoanLogger(t)
+
+	router := newTestRouter()
+	router.GET("/panic", func(c *gin.Context) {
+		var columns []float64
+		_ = columns[3] // Index out of range
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", w.Code)
+	}
+	var resp struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v (%s)", err, w.Body.String())
+	}
+	if resp.Code != codeInternal {
+		t.Errorf("expected code %q, got %q", codeInternal, resp.Code)
+	}
+
+	logged := buf.String()
+	if !strings.Contains(logged, `"msg":"panic recovered"`) || !strings.Contains(logged, "index out of range") {
+		t.Errorf("expected logged panic, got %s", logged)
+	}
+	if !strings.Contains(logged, `"stack":"goroutine`) || !strings.Contains(logged, "errors_test.go") {
+		t.Errorf("expected logged stack trace, got %s", logged)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Status transitions a loan passes through while a cashflow request is
+// processed: queued when accepted, processing once it holds a worker, then
+// done or failed
+const (
+	statusQueued     = "queued"
+	statusProcessing = "processing"
+	statusDone       = "done"
+	statusFailed     = "failed"
+)
+
+// subscriberBuffer bounds the events held for a slow subscriber; beyond it
+// events are dropped for that subscriber rather than stalling the workers
+const subscriberBuffer = 256
+
+// keepAliveInterval is how often an idle event stream sends a comment so
+// proxies don't time the connection out
+var keepAliveInterval = 15 * time.Second
+
+// statusEvent is one loan status transition, pushed to /loans/events
+type statusEvent struct {
+	RunID  string `json:"run_id"`
+	LoanID string `json:"loan_id"`
+	Status string `json:"status"`
+	Time   string `json:"time"`
+}
+
+// statusBroker fans loan status transitions out to event stream subscribers.
+// It is safe for concurrent use.
+type statusBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan statusEvent]struct{}
+}
+
+func newStatusBroker() *statusBroker {
+	return &statusBroker{subscribers: make(map[chan statusEvent]struct{})}
+}
+
+// loanEvents carries the status transitions of every cashflow request
+var loanEvents = newStatusBroker()
+
+// subscribe registers a new subscriber. The returned function unregisters it
+// and must be called once the subscriber stops reading.
+func (b *statusBroker) subscribe() (<-chan statusEvent, func()) {
+	ch := make(chan statusEvent, subscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// publish sends the transition to every subscriber without blocking
+func (b *statusBroker) publish(runID, loanID, status string) {
+	event := statusEvent{RunID: runID, LoanID: loanID, Status: status, Time: formatTimestamp(clock())}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default: // Subscriber is full; drop rather than stall the batch
+		}
+	}
+}
+
+// streamLoanEvents serves loan status transitions as server-sent events until
+// the client disconnects
+func streamLoanEvents(c *gin.Context) {
+	events, unsubscribe := loanEvents.subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			c.SSEvent("status", event)
+		case <-keepAlive.C:
+			if _, err := c.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamLoanEvents_PushesStatusTransitions(t *testing.T) {
+	server := httptest.NewServer(newTestRouter())
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/loans/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+
+	post, err := http.Post(server.URL+"/loans", "application/json",
+		strings.NewReader(`[{"id": "SSE-1", "wam": 12, "wac": 4.5, "face": 1000}]`))
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	post.Body.Close()
+
+	var statuses []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event statusEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("event is not valid JSON: %v", err)
+		}
+		if event.LoanID != "SSE-1" {
+			continue
+		}
+		statuses = append(statuses, event.Status)
+		if event.Status == statusDone {
+			break
+		}
+	}
+
+	want := []string{statusQueued, statusProcessing, statusDone}
+	if strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Errorf("expected transitions %v, got %v", want, statuses)
+	}
+}
+
+func TestStreamLoanEvents_UnsubscribesOnDisconnect(t *testing.T) {
+	server := httptest.NewServer(newTestRouter())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/loans/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	if n := subscriberCount(); n != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", n)
+	}
+
+	cancel()
+	resp.Body.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for subscriberCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscriber was not removed after the client disconnected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func subscriberCount() int {
+	loanEvents.mu.Lock()
+	defer loanEvents.mu.Unlock()
+	return len(loanEvents.subscribers)
+}
+
+func TestStatusBroker_DropsForFullSubscriber(t *testing.T) {
+	broker := newStatusBroker()
+	events, unsubscribe := broker.subscribe()
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer+10; i++ {
+			broker.publish("run", "LOAN", statusQueued)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("publish blocked on a subscriber that stopped reading")
+	}
+	if len(events) != subscriberBuffer {
+		t.Errorf("expected %d buffered events, got %d", subscriberBuffer, len(events))
+	}
+}
+
+// loanStatuses subscribes to loan events and returns a function that
+// collects, once the request has been served, the statuses published for
+// loanID in order
+func loanStatuses(t *testing.T, loanID string) func() []string {
+	events, unsubscribe := loanEvents.subscribe()
+	t.Cleanup(unsubscribe)
+	return func() []string {
+		var statuses []string
+		for {
+			select {
+			case event := <-events:
+				if event.LoanID == loanID {
+					statuses = append(statuses, event.Status)
+				}
+			default:
+				return statuses
+			}
+		}
+	}
+}
+
+func TestRequestCashflow_WriteFailurePublishesFailed(t *testing.T) {
+	useOutputDir(t)
+	createOutputFile = func(name string) (*os.File, error) {
+		return nil, os.ErrPermission
+	}
+	collect := loanStatuses(t, "SSE-WRITE")
+
+	w := postLoans(t, newTestRouter(), `[{"id": "SSE-WRITE", "wam": 12, "wac": 4.5, "face": 1000}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	want := []string{statusQueued, statusProcessing, statusFailed}
+	if got := collect(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected transitions %v, got %v", want, got)
+	}
+}
+
+func TestRequestCashflowNDJSON_PublishesStatusTransitions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		failWrite bool
+		want      []string
+	}{
+		{name: "written", want: []string{statusQueued, statusProcessing, statusDone}},
+		{name: "write fails", failWrite: true, want: []string{statusQueued, statusProcessing, statusFailed}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			useOutputDir(t)
+			if tc.failWrite {
+				createOutputFile = func(name string) (*os.File, error) {
+					return nil, os.ErrPermission
+				}
+			}
+			collect := loanStatuses(t, "SSE-NDJSON")
+
+			w := postNDJSON(newTestRouter(), `{"id": "SSE-NDJSON", "wam": 12, "wac": 4.5, "face": 1000}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			if got := collect(); strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("expected transitions %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
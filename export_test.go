@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+	"github.com/jiangshenghai57/andy-warhol/parquet"
+)
+
+func TestExportLoansParquet(t *testing.T) {
+	mu.Lock()
+	original := mortgages
+	mortgages = []amortization.LoanInfo{
+		{ID: "PQ1", Wam: 360, Wac: 6.5, Face: 250000},
+		{ID: "PQ2", Wam: 180, Wac: 0.045, WacIsDecimal: true, Face: 100000, PrepayInfo: amortization.PrepayInfo{PrepayCPR: 0.1}},
+	}
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		mortgages = original
+		mu.Unlock()
+	})
+
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodGet, "/loans/export.parquet", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != parquetContentType {
+		t.Errorf("expected %s, got %q", parquetContentType, ct)
+	}
+
+	columns, err := parquet.Read(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("response is not readable parquet: %v", err)
+	}
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+		if col.Len() != 2 {
+			t.Errorf("column %s: expected 2 rows, got %d", col.Name, col.Len())
+		}
+	}
+	if want := []string{"id", "wam", "wac", "face", "wal", "total_interest"}; !slices.Equal(names, want) {
+		t.Fatalf("expected columns %v, got %v", want, names)
+	}
+
+	if !slices.Equal(columns[0].Strings, []string{"PQ1", "PQ2"}) {
+		t.Errorf("unexpected ids %v", columns[0].Strings)
+	}
+	if !slices.Equal(columns[1].Int64s, []int64{360, 180}) {
+		t.Errorf("unexpected wams %v", columns[1].Int64s)
+	}
+	if !slices.Equal(columns[2].Doubles, []float64{6.5, 4.5}) {
+		t.Errorf("expected WACs in percentage points, got %v", columns[2].Doubles)
+	}
+
+	loan := amortization.LoanInfo{ID: "PQ1", Wam: 360, Wac: 6.5, Face: 250000}
+	table := loan.GetAmortizationTable()
+	if columns[4].Doubles[0] != table.WAL() || columns[5].Doubles[0] != table.TotalInterest() {
+		t.Errorf("expected WAL %f and interest %f, got %f and %f",
+			table.WAL(), table.TotalInterest(), columns[4].Doubles[0], columns[5].Doubles[0])
+	}
+}
+
+func TestExportLoansParquet_ReportsFailureCode(t *testing.T) {
+	useEmptyBook(t)
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "PQ3", "wam": 360, "wac": 5.0, "face": 100000}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 storing loans, got %d: %s", w.Code, w.Body.String())
+	}
+
+	useResultCache(t, 0) // The stubbed calculation must run
+	originalCalc, originalTimeout := calculateTable, loanTimeout
+	loanTimeout = 20 * time.Millisecond
+	hang := make(chan struct{})
+	t.Cleanup(func() {
+		close(hang) // Let the abandoned calculation finish
+		calculateTable, loanTimeout = originalCalc, originalTimeout
+	})
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		<-hang
+		return l.GetAmortizationTable()
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/loans/export.parquet", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if want := failureCode(errLoanTimeout); body.Code != want {
+		t.Errorf("expected code %s, got %s", want, body.Code)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// outputSuffix matches what outputFileName appends after the loan ID:
+// the assumptions digest, the timestamp and the sequence number
+var outputSuffix = regexp.MustCompile(`^[0-9a-f]{8}_\d{8}_\d{6}\.\d{3}_\d+\.json$`)
+
+// outputFile describes one persisted cashflow file
+type outputFile struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// isLoanOutputFile reports whether name is an output file written for
+// loanID. Checking the whole suffix keeps loan "A" from claiming the files
+// of loan "A_B".
+func isLoanOutputFile(loanID, name string) bool {
+	suffix, ok := strings.CutPrefix(name, "cashflow_"+loanID+"_")
+	return ok && outputSuffix.MatchString(suffix)
+}
+
+// listLoanFiles serves GET /loans/:id/files, listing the cashflow files
+// written under OUTPUT_PATH for the loan, oldest first
+func listLoanFiles(c *gin.Context) {
+	if outputDir == "" {
+		respondError(c, http.StatusNotFound, codeNotFound, "output files are not enabled")
+		return
+	}
+
+	loanID := c.Param("id")
+	entries, err := os.ReadDir(outputDir)
+	if err != nil && !os.IsNotExist(err) {
+		respondError(c, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	files := []outputFile{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isLoanOutputFile(loanID, entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed since the directory was read
+		}
+		files = append(files, outputFile{Name: entry.Name(), Size: info.Size(), Modified: info.ModTime().In(location)})
+	}
+	// Names embed the write time and sequence, so name order is write order
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	respondJSON(c, http.StatusOK, gin.H{"loan_id": loanID, "files": files})
+}
+
+// getLoanFile serves GET /loans/:id/files/:name. Only names listed for the
+// loan are served, which rules out path traversal through :name.
+func getLoanFile(c *gin.Context) {
+	loanID, name := c.Param("id"), c.Param("name")
+	if outputDir == "" || !isLoanOutputFile(loanID, name) || filepath.Base(name) != name {
+		respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("file %s not found for loan %s", name, loanID))
+		return
+	}
+
+	path := filepath.Join(outputDir, name)
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("file %s not found for loan %s", name, loanID))
+		return
+	}
+	c.File(path)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func getPath(router http.Handler, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoanFiles_ListAndFetch(t *testing.T) {
+	dir := useOutputDir(t)
+	router := newTestRouter()
+
+	for _, id := range []string{"FILES001", "FILES001_B"} {
+		if w := postLoans(t, router, `[{"id": "`+id+`", "wam": 12, "wac": 5.0, "face": 10000}]`); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := getPath(router, "/loans/FILES001/files")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var listing struct {
+		LoanID string       `json:"loan_id"`
+		Files  []outputFile `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if len(listing.Files) != 1 {
+		t.Fatalf("expected only FILES001's file, got %+v", listing.Files)
+	}
+	file := listing.Files[0]
+	if file.Size == 0 || file.Modified.IsZero() {
+		t.Errorf("expected size and modification time, got %+v", file)
+	}
+
+	w = getPath(router, "/loans/FILES001/files/"+file.Name)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	onDisk, err := os.ReadFile(filepath.Join(dir, file.Name))
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	if w.Body.String() != string(onDisk) {
+		t.Errorf("expected the file contents to be served unchanged")
+	}
+
+	// Another loan's file is not served under this loan
+	w = getPath(router, "/loans/FILES001_B/files/"+file.Name)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another loan's file, got %d", w.Code)
+	}
+}
+
+func TestLoanFiles_RejectsOtherFiles(t *testing.T) {
+	dir := useOutputDir(t)
+	router := newTestRouter()
+
+	if err := os.WriteFile(filepath.Join(dir, "secret.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		"/loans/FILES002/files/secret.json",
+		"/loans/FILES002/files/..%2Fsecret.json",
+		"/loans/FILES002/files/cashflow_FILES002_00000000_20240101_000000.000_1.json",
+	} {
+		if w := getPath(router, path); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, w.Code)
+		}
+	}
+
+	for _, name := range []string{
+		"../cashflow_FILES002_00000000_20240101_000000.000_1.json",
+		"cashflow_FILES002_00000000_20240101_000000.000_1.json/..",
+		"cashflow_FILES002_../../etc/passwd",
+	} {
+		if isLoanOutputFile("FILES002", name) {
+			t.Errorf("expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestLoanFiles_DisabledWithoutOutputDir(t *testing.T) {
+	original := outputDir
+	outputDir = ""
+	t.Cleanup(func() { outputDir = original })
+
+	if w := getPath(newTestRouter(), "/loans/FILES003/files"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when output files are disabled, got %d", w.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// gridRequest gives the axes of a sensitivity grid
+type gridRequest struct {
+	CPRs []float64 `json:"cprs"` // Flat CPRs in decimals
+	WACs []float64 `json:"wacs"` // Coupons in percentage points
+}
+
+// sensitivityGrid serves POST /loans/:id/grid, summarizing the most recent
+// stored loan with the given ID at every CPR and WAC combination. Each cell
+// counts as one loan against the worker queue.
+func sensitivityGrid(c *gin.Context) {
+	var req gridRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := amortization.ValidateGrid(req.CPRs, req.WACs); err != nil {
+		respondError(c, http.StatusBadRequest, codeValidationFailed, err.Error())
+		return
+	}
+
+	loan, ok := findLoan(c.Param("id"))
+	if !ok {
+		respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("loan %s not found", c.Param("id")))
+		return
+	}
+
+	cells := len(req.CPRs) * len(req.WACs)
+	if !admitBatch(c, cells) {
+		return
+	}
+	defer releaseLoans(cells)
+
+	// Cells run on the worker pool under LOAN_TIMEOUT, like any other batch
+	reqLog := requestLogger(c)
+	scenarios := amortization.GridScenarios(loan, req.CPRs, req.WACs)
+	tables := make([]amortization.AmortizationTable, len(scenarios))
+	errs := make([]error, len(scenarios))
+	calculateBatch(scenarios, func(index int, l amortization.LoanInfo) {
+		tables[index], errs[index] = calculateTableWithin(reqLog, &l)
+	}, nil)
+	for _, err := range errs {
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, failureCode(err),
+				fmt.Sprintf("loan %s: %s", loan.ID, err.Error()))
+			return
+		}
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"loan_id": loan.ID,
+		"cprs":    req.CPRs,
+		"wacs":    req.WACs,
+		"grid":    amortization.GridSummaries(loan, tables, req.CPRs, req.WACs),
+	})
+}
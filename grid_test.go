@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+func postGrid(router http.Handler, id, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/loans/"+id+"/grid", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSensitivityGrid_TwoByTwo(t *testing.T) {
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "GRID001", "wam": 360, "wac": 6.0, "face": 250000, "prepay_cpr": 0.06}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := postGrid(router, "GRID001", `{"cprs": [0.0, 0.25], "wacs": [4.0, 8.0]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		LoanID string                        `json:"loan_id"`
+		Grid   [][]amortization.TableSummary `json:"grid"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.LoanID != "GRID001" {
+		t.Errorf("expected loan_id GRID001, got %q", resp.LoanID)
+	}
+	if len(resp.Grid) != 2 || len(resp.Grid[0]) != 2 || len(resp.Grid[1]) != 2 {
+		t.Fatalf("expected a 2x2 grid, got %d rows", len(resp.Grid))
+	}
+	low, high := resp.Grid[0][0], resp.Grid[1][1]
+	if low.WAL == high.WAL || low.TotalInterest == high.TotalInterest || low.PV == high.PV {
+		t.Errorf("expected the corner cells to differ, got %+v and %+v", low, high)
+	}
+}
+
+func TestSensitivityGrid_RejectsBadAxes(t *testing.T) {
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "GRID002", "wam": 120, "wac": 5.0, "face": 100000}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		wantStatus int
+	}{
+		{name: "empty axis", id: "GRID002", body: `{"cprs": [], "wacs": [5.0]}`, wantStatus: http.StatusBadRequest},
+		{name: "CPR out of range", id: "GRID002", body: `{"cprs": [1.5], "wacs": [5.0]}`, wantStatus: http.StatusBadRequest},
+		{name: "WAC out of range", id: "GRID002", body: `{"cprs": [0.1], "wacs": [45.0]}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown loan", id: "GRID-MISSING", body: `{"cprs": [0.1], "wacs": [5.0]}`, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := postGrid(router, tt.id, tt.body); w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSensitivityGrid_RunsOnWorkerPool(t *testing.T) {
+	useResultCache(t, 0) // The stubbed calculation must run for every cell
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "GRID003", "wam": 120, "wac": 5.0, "face": 100000}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	originalPool, originalCalc := workerPool, calculateTable
+	workerPool = make(chan struct{}, 2)
+	t.Cleanup(func() { workerPool, calculateTable = originalPool, originalCalc })
+
+	var inFlight, peak, calls int32
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		atomic.AddInt32(&calls, 1)
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond) // Give other cells a chance to exceed the bound
+		atomic.AddInt32(&inFlight, -1)
+		return l.GetAmortizationTable()
+	}
+
+	cprs, wacs := []float64{0, 0.05, 0.1}, []float64{4.0, 5.0, 6.0}
+	w := postGrid(router, "GRID003", `{"cprs": [0, 0.05, 0.1], "wacs": [4.0, 5.0, 6.0]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if n := atomic.LoadInt32(&calls); n != 9 {
+		t.Errorf("expected each of 9 cells to go through calculateTable, got %d calls", n)
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Errorf("expected at most 2 cells in flight, peak was %d", p)
+	}
+
+	var resp struct {
+		Grid [][]amortization.TableSummary `json:"grid"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	loan, _ := findLoan("GRID003")
+	want := amortization.SensitivityGrid(loan, cprs, wacs)
+	for i := range want {
+		for j := range want[i] {
+			if resp.Grid[i][j].PV != want[i][j].PV || resp.Grid[i][j].WAL != want[i][j].WAL {
+				t.Errorf("cell [%d][%d]: expected %+v, got %+v", i, j, want[i][j], resp.Grid[i][j])
+			}
+		}
+	}
+}
@@ -11,15 +11,82 @@ import (
 
 type Logger struct {
 	*slog.Logger
+	file *os.File  // Log file opened by NewLogger; nil when wrapping another handler
+	out  io.Writer // Destination of the records written by NewLogger's handler
 }
 
-// NewLogger creates a structured logger with dual output (file + stdout)
-func NewLogger(logDir string) (*Logger, error) {
+// Format selects how log records are encoded
+type Format string
+
+const (
+	FormatJSON Format = "json" // One JSON object per line (default)
+	FormatText Format = "text" // slog key=value text, easier to read locally
+)
+
+// options holds the settings applied by Option values
+type options struct {
+	format       Format
+	redactedKeys []string
+	fileName     string
+	clock        func() time.Time
+}
+
+// Option customizes a logger created by NewLogger
+type Option func(*options)
+
+// WithFormat selects the log encoding; unknown formats fall back to JSON
+func WithFormat(format Format) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithRedactedKeys masks the values of the given attribute keys (e.g. loan_id,
+// face) in every record; see RedactHandler
+func WithRedactedKeys(keys ...string) Option {
+	return func(o *options) {
+		o.redactedKeys = append(o.redactedKeys, keys...)
+	}
+}
+
+// WithFileName writes to a fixed file in the log directory instead of one
+// named for the day the logger was created
+func WithFileName(name string) Option {
+	return func(o *options) {
+		o.fileName = name
+	}
+}
+
+// WithClock takes the current time from clock instead of time.Now, for the
+// daily file name and each record's time, so tests can pin "today"
+func WithClock(clock func() time.Time) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// NewLogger creates a structured logger with dual output (file + stdout). The
+// file is named for the current date (2006-01-02.log) unless WithFileName is
+// given.
+func NewLogger(logDir string, opts ...Option) (*Logger, error) {
+	cfg := options{format: FormatJSON}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return nil, err
 	}
 
-	logFile := filepath.Join(logDir, time.Now().Format("2006-01-02")+".log")
+	now := time.Now
+	if cfg.clock != nil {
+		now = cfg.clock
+	}
+	name := cfg.fileName
+	if name == "" {
+		name = now().Format("2006-01-02") + ".log"
+	}
+	logFile := filepath.Join(logDir, name)
 	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, err
@@ -28,12 +95,53 @@ func NewLogger(logDir string) (*Logger, error) {
 	// Dual output: file (JSON) + stdout (text for readability)
 	multiWriter := io.MultiWriter(file, os.Stdout)
 
-	handler := slog.NewJSONHandler(multiWriter, &slog.HandlerOptions{
+	handlerOpts := &slog.HandlerOptions{
 		Level:     slog.LevelInfo,
 		AddSource: true, // Include file:line in logs
-	})
+	}
+	if cfg.clock != nil {
+		handlerOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				a.Value = slog.TimeValue(cfg.clock())
+			}
+			return a
+		}
+	}
 
-	return &Logger{slog.New(handler)}, nil
+	var handler slog.Handler
+	if cfg.format == FormatText {
+		handler = slog.NewTextHandler(multiWriter, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(multiWriter, handlerOpts)
+	}
+	if len(cfg.redactedKeys) > 0 {
+		handler = NewRedactHandler(handler, cfg.redactedKeys...)
+	}
+
+	return &Logger{Logger: slog.New(handler), file: file, out: multiWriter}, nil
+}
+
+// Writer returns the file and stdout writer behind a logger created by
+// NewLogger, for output that is not structured (e.g. an HTTP access log).
+// Loggers wrapping another handler write to stdout.
+func (l *Logger) Writer() io.Writer {
+	if l.out == nil {
+		return os.Stdout
+	}
+	return l.out
+}
+
+// Close closes the log file opened by NewLogger. It is safe to call more than
+// once and on loggers without a file. Each logger holds its own descriptor, so
+// closing one does not affect other loggers appending to the same daily file.
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	if err := l.file.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
 }
 
 // Usage example
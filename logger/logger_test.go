@@ -2,6 +2,7 @@ package logger
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -71,6 +72,53 @@ func TestNewLogger_CreatesLogFile(t *testing.T) {
 	}
 }
 
+func TestNewLogger_WithFileName(t *testing.T) {
+	tempDir := t.TempDir()
+
+	logger, err := NewLogger(tempDir, WithFileName("andy-warhol.log"))
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("fixed file")
+	fmt.Fprintln(logger.Writer(), "access log line")
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "andy-warhol.log"))
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(content), `"msg":"fixed file"`) || !strings.Contains(string(content), "access log line") {
+		t.Errorf("log file missing records, got: %s", content)
+	}
+
+	// No daily file is created alongside the fixed one
+	daily := filepath.Join(tempDir, time.Now().Format("2006-01-02")+".log")
+	if _, err := os.Stat(daily); !os.IsNotExist(err) {
+		t.Errorf("expected no daily log file, got %v", err)
+	}
+}
+
+func TestNewLogger_WithClock(t *testing.T) {
+	tempDir := t.TempDir()
+	fixed := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	logger, err := NewLogger(tempDir, WithClock(func() time.Time { return fixed }))
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	defer logger.Close()
+	logger.Info("pinned")
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "2024-03-15.log"))
+	if err != nil {
+		t.Fatalf("expected the log file named for the fixed day: %v", err)
+	}
+	if !strings.Contains(string(content), `"time":"2024-03-15T10:30:00Z"`) {
+		t.Errorf("expected the record stamped with the fixed time, got: %s", content)
+	}
+}
+
 func TestNewLogger_InvalidPermissions(t *testing.T) {
 	if os.Getuid() == 0 {
 		t.Skip("skipping test when running as root")
@@ -381,6 +429,127 @@ func TestLogger_ConcurrentWrites(t *testing.T) {
 	}
 }
 
+func TestNewLogger_TextFormat(t *testing.T) {
+	tempDir := t.TempDir()
+
+	logger, err := NewLogger(tempDir, WithFormat(FormatText))
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+
+	logger.Info("processing loan", slog.String("loan_id", "LOAN001"))
+
+	// Read log file
+	logFile := filepath.Join(tempDir, time.Now().Format("2006-01-02")+".log")
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	logContent := string(content)
+
+	if json.Valid(content) {
+		t.Errorf("expected text output, got valid JSON: %s", logContent)
+	}
+	if !strings.Contains(logContent, "level=INFO") {
+		t.Errorf("log missing text level, got: %s", logContent)
+	}
+	if !strings.Contains(logContent, `msg="processing loan"`) || !strings.Contains(logContent, "loan_id=LOAN001") {
+		t.Errorf("log missing text fields, got: %s", logContent)
+	}
+}
+
+func TestNewLogger_RedactsConfiguredKeys(t *testing.T) {
+	tempDir := t.TempDir()
+
+	logger, err := NewLogger(tempDir, WithRedactedKeys("loan_id"))
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+
+	logger.With(slog.String("loan_id", "LOAN009")).Info("batch started")
+	logger.Info("processing loan",
+		slog.String("loan_id", "LOAN001"),
+		slog.Float64("face", 250000),
+		slog.Group("loan", slog.String("loan_id", "LOAN002")),
+	)
+
+	// Read log file
+	logFile := filepath.Join(tempDir, time.Now().Format("2006-01-02")+".log")
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	logContent := string(content)
+
+	for _, id := range []string{"LOAN001", "LOAN002", "LOAN009"} {
+		if strings.Contains(logContent, id) {
+			t.Errorf("log contains cleartext %s: %s", id, logContent)
+		}
+	}
+	if !strings.Contains(logContent, `"loan_id":"redacted:`) {
+		t.Errorf("log missing masked loan_id field, got: %s", logContent)
+	}
+	// Keys that are not configured are written unchanged
+	if !strings.Contains(logContent, `"face":250000`) {
+		t.Errorf("log missing face field, got: %s", logContent)
+	}
+}
+
+func TestLogger_Close(t *testing.T) {
+	tempDir := t.TempDir()
+
+	logger1, err := NewLogger(tempDir)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	logger2, err := NewLogger(tempDir)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+
+	logger1.Info("before close", slog.String("batch", "1"))
+	if err := logger1.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if err := logger1.Close(); err != nil {
+		t.Errorf("second Close() should be a no-op, got %v", err)
+	}
+
+	// Other loggers on the same daily file keep writing
+	logger2.Info("after close", slog.String("batch", "2"))
+
+	// Reopening the file appends to it
+	logger3, err := NewLogger(tempDir)
+	if err != nil {
+		t.Fatalf("NewLogger() after Close failed: %v", err)
+	}
+	defer logger3.Close()
+	logger3.Info("reopened", slog.String("batch", "3"))
+
+	// Read log file
+	logFile := filepath.Join(tempDir, time.Now().Format("2006-01-02")+".log")
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	logContent := string(content)
+
+	for _, msg := range []string{"before close", "after close", "reopened"} {
+		if !strings.Contains(logContent, msg) {
+			t.Errorf("log file missing %q", msg)
+		}
+	}
+
+	// Loggers that did not open a file close cleanly
+	wrapped := &Logger{Logger: slog.Default()}
+	if err := wrapped.Close(); err != nil {
+		t.Errorf("Close() on a wrapped logger failed: %v", err)
+	}
+}
+
 func BenchmarkLogger_Info(b *testing.B) {
 	tempDir := b.TempDir()
 
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+)
+
+// RedactHandler wraps a slog.Handler and masks the values of configured
+// attribute keys before they are written. Masked values are replaced by a short
+// hash so records about the same loan can still be correlated.
+type RedactHandler struct {
+	next slog.Handler
+	keys map[string]struct{}
+}
+
+// NewRedactHandler returns a handler that masks the given keys, at any group
+// depth, before passing records to next
+func NewRedactHandler(next slog.Handler, keys ...string) *RedactHandler {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return &RedactHandler{next: next, keys: set}
+}
+
+// Enabled implements slog.Handler
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs implements slog.Handler
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = h.redact(a)
+	}
+	return &RedactHandler{next: h.next.WithAttrs(masked), keys: h.keys}
+}
+
+// WithGroup implements slog.Handler
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{next: h.next.WithGroup(name), keys: h.keys}
+}
+
+// redact masks a if its key is configured, descending into groups
+func (h *RedactHandler) redact(a slog.Attr) slog.Attr {
+	value := a.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		group := value.Group()
+		masked := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			masked[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(masked...)}
+	}
+
+	if _, ok := h.keys[a.Key]; !ok {
+		return a
+	}
+	sum := sha256.Sum256([]byte(value.String()))
+	return slog.String(a.Key, "redacted:"+hex.EncodeToString(sum[:4]))
+}
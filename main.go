@@ -1,105 +1,763 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jiangshenghai57/andy-warhol/amortization"
 	"github.com/jiangshenghai57/andy-warhol/config"
+	"github.com/jiangshenghai57/andy-warhol/logger"
 )
 
+// highPrepayCPR is the CPR above which a loan's prepayment assumption is flagged
+const highPrepayCPR = 0.25
+
+// defaultMaxWorkers is the worker pool size used when MAX_WORKERS is not configured
+const defaultMaxWorkers = 100
+
+// defaultMaxFileWrites is the write pool size used when MAX_FILE_WRITES is not configured
+const defaultMaxFileWrites = 8
+
+// shutdownTimeout bounds how long in-flight requests may run after a shutdown signal
+const shutdownTimeout = 30 * time.Second
+
+// defaultLoanTimeout bounds a single loan's calculation when LOAN_TIMEOUT_SECONDS is not configured
+const defaultLoanTimeout = 30 * time.Second
+
+// defaultMaxBodyBytes caps request bodies when MAX_BODY_BYTES is not configured
+const defaultMaxBodyBytes = 32 << 20 // 32 MiB
+
 var (
 	mortgages  = []amortization.LoanInfo{}
-	mu         sync.RWMutex // Protect the mortgages slice
-	workerPool = make(chan struct{}, 100)
+	mu         sync.RWMutex // Protect the mortgages slice; see loanSnapshot
+	workerPool              = make(chan struct{}, defaultMaxWorkers)
+	loanLogger              = &logger.Logger{Logger: slog.Default()}
+	location                = time.Local // Zone used for response timestamps, set from TIMEZONE
+
+	// maxBodyBytes is the largest request body accepted; set from MAX_BODY_BYTES
+	maxBodyBytes int64 = defaultMaxBodyBytes
+
+	// loanTimeout bounds each loan's calculation; set from LOAN_TIMEOUT_SECONDS
+	loanTimeout = defaultLoanTimeout
+
+	// compactJSON drops indentation from saved files; set from COMPACT_JSON
+	compactJSON = false
+
+	// calculateTable generates a loan's amortization table; swappable in tests
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		return l.GetAmortizationTable()
+	}
 )
 
+// respondJSON writes obj as compact JSON, or indented for humans when the
+// request asks with pretty=true
+func respondJSON(c *gin.Context, code int, obj interface{}) {
+	if c.Query("pretty") == "true" {
+		c.IndentedJSON(code, obj)
+		return
+	}
+	c.JSON(code, obj)
+}
+
 func getLoans(c *gin.Context) {
+	loans := loanSnapshot()
+	lean := leanRequested(c)
+
+	tag, filtered := c.GetQuery("tag")
+	if !filtered {
+		respondJSON(c, http.StatusOK, leanIf(lean, loans))
+		return
+	}
+
+	key, value, ok := strings.Cut(tag, ":")
+	if !ok || key == "" {
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, fmt.Sprintf("tag filter must be key:value, got %q", tag))
+		return
+	}
+
+	// Only the matching loans are collected; the stored slice is never copied
+	matches := []amortization.LoanInfo{}
+	for i := range loans {
+		if v, ok := loans[i].Tags[key]; ok && v == value {
+			matches = append(matches, loans[i])
+		}
+	}
+	respondJSON(c, http.StatusOK, leanIf(lean, matches))
+}
+
+// leanRequested reports whether lean=true asks for responses without empty
+// arrays and zero-value fields
+func leanRequested(c *gin.Context) bool {
+	return c.Query("lean") == "true"
+}
+
+// leanIf wraps obj for lean serialization when lean is set
+func leanIf(lean bool, obj interface{}) interface{} {
+	if lean {
+		return amortization.Lean{V: obj}
+	}
+	return obj
+}
+
+// loanSnapshot returns the stored loans without holding the lock while the
+// caller serializes them. mortgages is append-only and stored entries are
+// never modified, so the slice read under mu stays valid after the lock is
+// released: a concurrent append either writes past its length or moves to a
+// new array, and neither touches the elements the snapshot covers.
+func loanSnapshot() []amortization.LoanInfo {
+	mu.RLock()
+	defer mu.RUnlock()
+	return mortgages
+}
+
+// limitBodySize caps every request body at maxBodyBytes so an oversized
+// upload fails while binding instead of exhausting memory.
+func limitBodySize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes)
+		}
+		c.Next()
+	}
+}
+
+// rowLayoutRequested reports whether the layout query parameter asks for
+// row-major tables. The default "columns" layout is the table's own JSON form.
+func rowLayoutRequested(c *gin.Context) (bool, error) {
+	switch layout := c.DefaultQuery("layout", "columns"); layout {
+	case "columns":
+		return false, nil
+	case "rows":
+		return true, nil
+	default:
+		return false, fmt.Errorf("layout must be rows or columns, got %q", layout)
+	}
+}
+
+// fieldsRequested parses the comma-separated fields query parameter, which
+// projects cashflow tables to the named columns. It returns nil when absent.
+func fieldsRequested(c *gin.Context) ([]string, error) {
+	raw, ok := c.GetQuery("fields")
+	if !ok {
+		return nil, nil
+	}
+
+	known := make(map[string]bool)
+	for _, name := range amortization.TableFields() {
+		known[name] = true
+	}
+
+	var fields []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if !known[name] {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", name, strings.Join(amortization.TableFields(), ", "))
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// findLoan returns the most recently stored loan with the given ID
+func findLoan(id string) (amortization.LoanInfo, bool) {
 	mu.RLock()
 	defer mu.RUnlock()
-	c.IndentedJSON(http.StatusOK, mortgages)
+	for i := len(mortgages) - 1; i >= 0; i-- {
+		if mortgages[i].ID == id {
+			return mortgages[i], true
+		}
+	}
+	return amortization.LoanInfo{}, false
 }
 
+func getLoanSummary(c *gin.Context) {
+	loan, ok := findLoan(c.Param("id"))
+	if !ok {
+		respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("loan %s not found", c.Param("id")))
+		return
+	}
+
+	amortTable, ok := calculateAdmitted(c, &loan)
+	if !ok {
+		return
+	}
+	respondJSON(c, http.StatusOK, gin.H{
+		"loan_id": loan.ID,
+		"summary": amortTable.Summary(),
+	})
+}
+
+// noLoansMessage explains the rejection of an empty body or a null batch
+const noLoansMessage = "request body must be a JSON array of loans; send [] for an empty batch"
+
+// bindLoans binds the request body as an array of loans, responding with the
+// error and returning false when it cannot. An empty array is a valid batch
+// of zero loans; an empty body or a bare null is rejected with 400.
+func bindLoans(c *gin.Context) ([]amortization.LoanInfo, bool) {
+	var loans []amortization.LoanInfo
+	err := c.ShouldBindJSON(&loans)
+	switch {
+	case errors.Is(err, io.EOF), err == nil && loans == nil:
+		respondError(c, http.StatusBadRequest, codeInvalidJSON, noLoansMessage)
+		return nil, false
+	case err != nil:
+		respondBindError(c, err)
+		return nil, false
+	}
+	return loans, true
+}
+
+// requestCashflow serves POST /loans. It calculates every loan in the batch,
+// stores them, and responds 200 with one result per loan; an empty array
+// yields a count of 0 and no results. With format=md the response is each
+// loan's schedule as a Markdown table under a heading naming the loan.
 func requestCashflow(c *gin.Context) {
 	log.Println("requestCashflow endpoint was hit")
 
-	var loans []amortization.LoanInfo
+	if c.ContentType() == ndjsonContentType {
+		requestCashflowNDJSON(c)
+		return
+	}
 
-	// Parse JSON
-	if err := c.BindJSON(&loans); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	loans, ok := bindLoans(c)
+	if !ok {
 		return
 	}
 
 	log.Printf("Received %d loans for processing", len(loans))
+	reqLog := requestLogger(c)
+	reqLog.Info("cashflow request received", slog.Int("count", len(loans)))
 
-	// Validate and calculate
-	results := make([]gin.H, len(loans))
+	// Validate every loan before any work is scheduled
 	for i, loan := range loans {
-		// Validate loan
 		if err := loan.Validate(); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": fmt.Sprintf("Loan %d validation failed: %s", i, err.Error()),
-			})
+			respondError(c, http.StatusBadRequest, codeValidationFailed,
+				fmt.Sprintf("Loan %d validation failed: %s", i, err.Error()))
 			return
 		}
+		if loan.WacLooksLikeDecimal() {
+			reqLog.Warn("wac looks like a decimal rate, expected percentage points",
+				slog.String("loan_id", loan.ID),
+				slog.Float64("wac", loan.Wac),
+				slog.Float64("face", loan.Face),
+				slog.String("hint", "pass 4.5 for 4.5% or set wac_is_decimal"),
+			)
+		}
+	}
 
-		// Calculate amortization table
-		amortTable := loan.GetAmortizationTable()
+	summaryOnly := c.Query("summary") == "true"
+	rowLayout, err := rowLayoutRequested(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return
+	}
+	fields, err := fieldsRequested(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return
+	}
+	if fields != nil && (summaryOnly || rowLayout) {
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, "fields requires the columns layout without summary")
+		return
+	}
+	markdown, maxRows, ok := markdownRequested(c)
+	if !ok {
+		return
+	}
+	if markdown && (summaryOnly || rowLayout || fields != nil) {
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, "format=md cannot be combined with summary, layout or fields")
+		return
+	}
+	checkTables := c.Query("check") == "true"
+	trimTables := c.Query("trim") == "true"
+	lean := leanRequested(c)
 
-		// Store result
-		results[i] = gin.H{
-			"loan_id":  loan.ID,
-			"cashflow": amortTable,
-		}
+	if !admitBatch(c, len(loans)) {
+		return
+	}
+	defer releaseLoans(len(loans))
+
+	runID := newRunID()
+	for _, loan := range loans {
+		loanEvents.publish(runID, loan.ID, statusQueued)
+	}
+	results := make([]gin.H, len(loans))
+	var tables []*amortization.AmortizationTable // Kept only for format=md
+	if markdown {
+		tables = make([]*amortization.AmortizationTable, len(loans))
 	}
+	var writes sync.WaitGroup
+	calculateBatch(loans, func(index int, l amortization.LoanInfo) {
+		loanEvents.publish(runID, l.ID, statusProcessing)
+		assumptions := assumptionsHash(l)
+		amortTable, err := calculateTableWithin(reqLog, &l)
+		if err != nil {
+			results[index] = failedResult(l.ID, err)
+			loanEvents.publish(runID, l.ID, statusFailed)
+			return
+		}
+		if trimTables {
+			amortTable.Trim()
+		}
+		logAmortizationResult(reqLog, l, amortTable)
+		if markdown {
+			tables[index] = &amortTable
+		}
+
+		var checkErr error
+		if checkTables {
+			if checkErr = amortTable.Check(); checkErr != nil {
+				reqLog.Error("amortization table failed consistency check",
+					slog.String("loan_id", l.ID),
+					slog.Any("error", checkErr),
+				)
+			}
+		}
+
+		result := gin.H{"loan_id": l.ID}
+		var encodeErr error
+		switch {
+		case summaryOnly:
+			result["summary"] = amortTable.Summary()
+		case rowLayout:
+			var rows []byte
+			if rows, encodeErr = amortTable.MarshalRows(); encodeErr == nil {
+				result["cashflow"] = json.RawMessage(rows)
+			}
+		case fields != nil:
+			var columns map[string]json.RawMessage
+			if columns, encodeErr = amortTable.Project(fields); encodeErr == nil {
+				result["cashflow"] = columns
+			}
+		default:
+			result["cashflow"] = leanIf(lean, &amortTable)
+		}
+		if encodeErr != nil {
+			reqLog.Error("failed to encode cashflow table",
+				slog.String("loan_id", l.ID),
+				slog.Any("error", encodeErr),
+			)
+			result["cashflow_error"] = encodeErr.Error()
+		}
+		if checkErr != nil {
+			result["check_error"] = checkErr.Error()
+		}
+
+		results[index] = result
+		if outputDir == "" {
+			loanEvents.publish(runID, l.ID, statusDone)
+			return
+		}
+		// Write after the worker is released, so a slow disk holds a write
+		// slot rather than a calculation slot
+		writes.Add(1)
+		go func() {
+			defer writes.Done()
+			if err := persistCashflow(reqLog, result, runID, l.ID, assumptions, amortTable); err != nil {
+				loanEvents.publish(runID, l.ID, statusFailed)
+				return
+			}
+			loanEvents.publish(runID, l.ID, statusDone)
+		}()
+	}, nil)
+	writes.Wait()
 
 	// Thread-safe append to mortgages
 	mu.Lock()
 	mortgages = append(mortgages, loans...)
 	mu.Unlock()
 
+	if markdown {
+		sections := make([]markdownSection, len(results))
+		for i, result := range results {
+			sections[i] = markdownSection{loanID: loans[i].ID, table: tables[i]}
+			switch {
+			case tables[i] == nil:
+				sections[i].note = fmt.Sprintf("Failed (%s): %s", result["code"], result["error"])
+			case result["check_error"] != nil:
+				sections[i].note = fmt.Sprintf("Consistency check failed: %s", result["check_error"])
+			}
+		}
+		respondMarkdownTables(c, sections, maxRows)
+		return
+	}
+
 	// Return results
+	respondJSON(c, http.StatusOK, gin.H{
+		"run_id":     runID,
+		"count":      len(loans),
+		"local_date": formatTimestamp(clock()),
+		"results":    results,
+	})
+}
+
+// persistCashflow writes a loan's table under outputDir and records the file
+// name, or the failure, on the loan's result. The failure is also returned.
+// It waits for a slot in the write pool, so callers should not hold a worker
+// while calling it.
+func persistCashflow(reqLog *logger.Logger, result gin.H, runID, loanID, assumptions string, table amortization.AmortizationTable) error {
+	writePool <- struct{}{}
+	defer func() { <-writePool }()
+
+	path, err := writeOutputFile(reqLog, loanID, assumptions, gin.H{
+		"run_id":     runID,
+		"loan_id":    loanID,
+		"local_date": formatTimestamp(clock()),
+		"cashflow":   table,
+	})
+	if err != nil {
+		reqLog.Error("failed to persist cashflow output",
+			slog.String("loan_id", loanID),
+			slog.Any("error", err),
+		)
+		result["output_error"] = err.Error()
+		return err
+	}
+	result["output_file"] = filepath.Base(path)
+	return nil
+}
+
+// calculateBatch calls fn for every loan concurrently, bounded by the worker
+// pool, and returns once all loans have been processed. When progress is not
+// nil it is called with the running count as each loan completes; the calls
+// are made in order from a single goroutine, so progress need not be safe
+// for concurrent use, and workers never wait on it.
+func calculateBatch(loans []amortization.LoanInfo, fn func(index int, l amortization.LoanInfo), progress func(done, total int)) {
+	var completed chan struct{}
+	reported := make(chan struct{})
+	if progress != nil {
+		// Buffered for every loan so a slow callback never holds a worker
+		completed = make(chan struct{}, len(loans))
+		go func() {
+			defer close(reported)
+			done := 0
+			for range completed {
+				done++
+				progress(done, len(loans))
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for i, loan := range loans {
+		wg.Add(1)
+
+		go func(index int, l amortization.LoanInfo) {
+			// Acquire worker from pool
+			workerPool <- struct{}{}
+			defer func() {
+				<-workerPool // Release worker
+				if completed != nil {
+					completed <- struct{}{}
+				}
+				wg.Done()
+			}()
+
+			fn(index, l)
+		}(i, loan)
+	}
+	wg.Wait()
+
+	if completed != nil {
+		close(completed)
+		<-reported
+	}
+}
+
+// logAmortizationResult records the resolved assumptions and headline results
+// of a single loan calculation for auditing.
+func logAmortizationResult(reqLog *logger.Logger, loan amortization.LoanInfo, table amortization.AmortizationTable) {
+	smm := 0.0
+	if len(loan.SMMArr) > 0 {
+		smm = loan.SMMArr[0]
+	}
+
+	if loan.PrepayCPR > highPrepayCPR {
+		reqLog.Warn("high prepayment rate detected",
+			slog.String("loan_id", loan.ID),
+			slog.Float64("prepay_cpr", loan.PrepayCPR),
+			slog.Float64("threshold", highPrepayCPR),
+		)
+	}
+
+	if table.EffectiveMaturity > 0 {
+		reqLog.Warn("prepayments retired loan before maturity",
+			slog.String("loan_id", loan.ID),
+			slog.Int("effective_maturity", table.EffectiveMaturity),
+			slog.Int64("wam", loan.Wam),
+		)
+	}
+
+	reqLog.Info("amortization calculated",
+		slog.String("loan_id", loan.ID),
+		slog.Float64("smm", smm),
+		slog.Float64("total_interest", table.TotalInterest()),
+		slog.Float64("wal", table.WAL()),
+		slog.Float64("final_end_bal", table.FinalEndBal()),
+	)
+}
+
+func getServiceInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"count":   len(loans),
-		"results": results,
+		"service":         "andy-warhol",
+		"max_workers":     cap(workerPool),
+		"max_file_writes": cap(writePool),
+		"max_queue_depth": maxQueueDepth,
+		"auth_required":   authToken != "",
+		"capabilities":    amortization.SupportedCapabilities(),
+		"error_codes":     errorCodes,
+		"conventions": gin.H{
+			"wac":        "annual coupon in percentage points (e.g. 4.5); set wac_is_decimal to pass 0.045",
+			"wam":        "remaining term in months",
+			"face":       "current balance",
+			"prepay_cpr": "annual CPR as a decimal (e.g. 0.06)",
+			"rounding":   amortization.CurrentRoundingMode(),
+		},
 	})
 }
 
-func multiLog() *gin.Engine {
-	config, _ := config.ReadConfig()
+// maxWorkersFromConfig reads MAX_WORKERS from the config, falling back to
+// defaultMaxWorkers when unset. The value must be a positive integer.
+func maxWorkersFromConfig(config map[string]interface{}) (int, error) {
+	raw, ok := config["MAX_WORKERS"]
+	if !ok {
+		return defaultMaxWorkers, nil
+	}
+
+	value, ok := raw.(float64)
+	if !ok || value != math.Trunc(value) || value < 1 {
+		return 0, fmt.Errorf("MAX_WORKERS must be a positive integer, got %v", raw)
+	}
+
+	return int(value), nil
+}
+
+// maxFileWritesFromConfig reads MAX_FILE_WRITES from the config, falling
+// back to defaultMaxFileWrites when unset. The value must be a positive
+// integer.
+func maxFileWritesFromConfig(config map[string]interface{}) (int, error) {
+	raw, ok := config["MAX_FILE_WRITES"]
+	if !ok {
+		return defaultMaxFileWrites, nil
+	}
+
+	value, ok := raw.(float64)
+	if !ok || value != math.Trunc(value) || value < 1 {
+		return 0, fmt.Errorf("MAX_FILE_WRITES must be a positive integer, got %v", raw)
+	}
+
+	return int(value), nil
+}
+
+// maxBodyBytesFromConfig reads MAX_BODY_BYTES from the config, falling back to
+// defaultMaxBodyBytes when unset. The value must be a positive integer.
+func maxBodyBytesFromConfig(config map[string]interface{}) (int64, error) {
+	raw, ok := config["MAX_BODY_BYTES"]
+	if !ok {
+		return defaultMaxBodyBytes, nil
+	}
+
+	value, ok := raw.(float64)
+	if !ok || value != math.Trunc(value) || value < 1 {
+		return 0, fmt.Errorf("MAX_BODY_BYTES must be a positive integer, got %v", raw)
+	}
+
+	return int64(value), nil
+}
 
-	LOG_PATH := config["LOG_PATH"]
-	log_path, _ := LOG_PATH.(string)
-	LOG_FILE := config["LOG_FILE"]
-	log_file, _ := LOG_FILE.(string)
+// loanTimeoutFromConfig reads LOAN_TIMEOUT_SECONDS from the config, falling
+// back to defaultLoanTimeout when unset. The value must be positive.
+func loanTimeoutFromConfig(config map[string]interface{}) (time.Duration, error) {
+	raw, ok := config["LOAN_TIMEOUT_SECONDS"]
+	if !ok {
+		return defaultLoanTimeout, nil
+	}
 
-	f, _ := os.Create(log_path + log_file)
+	value, ok := raw.(float64)
+	if !ok || value <= 0 {
+		return 0, fmt.Errorf("LOAN_TIMEOUT_SECONDS must be a positive number, got %v", raw)
+	}
 
-	mw := io.MultiWriter(f, os.Stdout)
+	return time.Duration(value * float64(time.Second)), nil
+}
+
+// locationFromConfig loads the TIMEZONE config value (e.g. "Asia/Tokyo"),
+// falling back to time.Local when it is unset or cannot be loaded.
+func locationFromConfig(config map[string]interface{}) *time.Location {
+	name, _ := config["TIMEZONE"].(string)
+	if name == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loanLogger.Warn("invalid TIMEZONE, falling back to local time",
+			slog.String("timezone", name),
+			slog.Any("error", err),
+		)
+		return time.Local
+	}
+	return loc
+}
+
+// loggerFromConfig opens the service logger in the LOG_PATH directory (the
+// working directory when unset). Records go to LOG_FILE in that directory
+// when it is configured, otherwise to a file named for the day. LOG_FORMAT
+// selects "json" (the default) or "text" records, and the values of the
+// attribute keys listed in LOG_REDACT_KEYS are masked in every record.
+func loggerFromConfig(config map[string]interface{}) (*logger.Logger, error) {
+	dir, _ := config["LOG_PATH"].(string)
+	if dir == "" {
+		dir = "."
+	}
+
+	// Through a closure so a clock swapped in later still names the file
+	opts := []logger.Option{logger.WithClock(func() time.Time { return clock() })}
+	if name, _ := config["LOG_FILE"].(string); name != "" {
+		opts = append(opts, logger.WithFileName(name))
+	}
+
+	if raw, ok := config["LOG_FORMAT"]; ok {
+		switch format, _ := raw.(string); logger.Format(format) {
+		case logger.FormatJSON, logger.FormatText:
+			opts = append(opts, logger.WithFormat(logger.Format(format)))
+		default:
+			return nil, fmt.Errorf("LOG_FORMAT must be %q or %q, got %v", logger.FormatJSON, logger.FormatText, raw)
+		}
+	}
 
-	gin.DefaultWriter = mw
-	gin.DefaultErrorWriter = mw
-	log.Println(config)
+	redactKeys, err := stringListFromConfig(config, "LOG_REDACT_KEYS", nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(redactKeys) > 0 {
+		opts = append(opts, logger.WithRedactedKeys(redactKeys...))
+	}
+	return logger.NewLogger(dir, opts...)
+}
 
-	r := gin.New()
-	r.Use(gin.Logger(), gin.Recovery())
+// newRouter returns the engine with gin's access log written alongside
+// loanLogger's records
+func newRouter() *gin.Engine {
+	gin.DefaultWriter = loanLogger.Writer()
+	gin.DefaultErrorWriter = loanLogger.Writer()
 
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Logger(), recoverJSON())
 
 	return router
 }
 
 func main() {
+	config, err := config.ReadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if loanLogger, err = loggerFromConfig(config); err != nil {
+		log.Fatal(err)
+	}
+	// The standard log package and the engine's warnings go through the
+	// same handler
+	slog.SetDefault(loanLogger.Logger)
+	loanLogger.Info("loaded config", slog.Any("config", redactConfig(config)))
 
-	router := multiLog()
-	router.GET("/loans", getLoans)
-	router.POST("/loans", requestCashflow)
+	maxWorkers, err := maxWorkersFromConfig(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	workerPool = make(chan struct{}, maxWorkers)
+	maxFileWrites, err := maxFileWritesFromConfig(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	writePool = make(chan struct{}, maxFileWrites)
+	if maxBodyBytes, err = maxBodyBytesFromConfig(config); err != nil {
+		log.Fatal(err)
+	}
+	if loanTimeout, err = loanTimeoutFromConfig(config); err != nil {
+		log.Fatal(err)
+	}
+	if maxQueueDepth, err = maxQueueDepthFromConfig(config); err != nil {
+		log.Fatal(err)
+	}
+	cacheSize, err := cacheSizeFromConfig(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	resultCache = newTableCache(cacheSize)
+	if authToken, err = authTokenFromConfig(config); err != nil {
+		log.Fatal(err)
+	}
+	if cors, err = corsFromConfig(config); err != nil {
+		log.Fatal(err)
+	}
+	if wacFractionThreshold, err = wacFractionThresholdFromConfig(config); err != nil {
+		log.Fatal(err)
+	}
+	if mode, ok := config["ROUNDING_MODE"].(string); ok {
+		if err := amortization.SetRoundingMode(amortization.RoundingMode(mode)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	location = locationFromConfig(config)
+	outputDir, _ = config["OUTPUT_PATH"].(string)
+	compactJSON, _ = config["COMPACT_JSON"].(bool)
+
+	router := newRouter()
+	router.Use(handleCORS(), requestID(), limitBodySize())
+	router.GET("/info", getServiceInfo)
+	router.GET("/calculate", requireToken(), calculateFromQuery)
+	router.POST("/batch", requireToken(), calculateBatchQueries)
+	loans := router.Group("/loans", requireToken())
+	loans.GET("", getLoans)
+	loans.POST("", requestCashflow)
+	loans.POST("/recalculate", recalculateLoans)
+	loans.POST("/replay", replayLoans)
+	loans.POST("/archive", archiveLoans)
+	loans.POST("/compare", compareLoans)
+	loans.POST("/from-payment", amortizeFromPayment)
+	loans.GET("/cohorts", getLoanCohorts)
+	loans.GET("/events", streamLoanEvents)
+	loans.GET("/export.parquet", exportLoansParquet)
+	loans.GET("/:id/summary", getLoanSummary)
+	loans.GET("/:id/files", listLoanFiles)
+	loans.GET("/:id/files/:name", getLoanFile)
+	loans.POST("/:id/price", priceLoan)
+	loans.POST("/:id/grid", sensitivityGrid)
 
-	router.Run("localhost:8080")
+	server := &http.Server{Addr: "localhost:8080", Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// Drain in-flight requests on SIGINT/SIGTERM, then flush and close the logger
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server shutdown: %v", err)
+	}
+	if err := loanLogger.Close(); err != nil {
+		log.Printf("closing logger: %v", err)
+	}
 }
@@ -0,0 +1,1057 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+	"github.com/jiangshenghai57/andy-warhol/logger"
+)
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(recoverJSON(), handleCORS(), requestID(), limitBodySize())
+	router.GET("/info", getServiceInfo)
+	router.GET("/calculate", requireToken(), calculateFromQuery)
+	router.POST("/batch", requireToken(), calculateBatchQueries)
+	loans := router.Group("/loans", requireToken())
+	loans.GET("", getLoans)
+	loans.POST("", requestCashflow)
+	loans.POST("/recalculate", recalculateLoans)
+	loans.POST("/replay", replayLoans)
+	loans.POST("/archive", archiveLoans)
+	loans.POST("/compare", compareLoans)
+	loans.POST("/from-payment", amortizeFromPayment)
+	loans.GET("/cohorts", getLoanCohorts)
+	loans.GET("/events", streamLoanEvents)
+	loans.GET("/export.parquet", exportLoansParquet)
+	loans.GET("/:id/summary", getLoanSummary)
+	loans.GET("/:id/files", listLoanFiles)
+	loans.GET("/:id/files/:name", getLoanFile)
+	loans.POST("/:id/price", priceLoan)
+	loans.POST("/:id/grid", sensitivityGrid)
+	return router
+}
+
+// captureLoanLogger swaps loanLogger for a JSON logger writing into a buffer
+func captureLoanLogger(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	original := loanLogger
+	loanLogger = &logger.Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+	t.Cleanup(func() { loanLogger = original })
+	return &buf
+}
+
+func postLoans(t *testing.T, router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/loans", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestCashflow_LogsHighPrepayWarning(t *testing.T) {
+	buf := captureLoanLogger(t)
+	router := newTestRouter()
+
+	body := `[
+		{"id": "FAST", "wam": 360, "wac": 4.5, "face": 250000, "prepay_cpr": 0.30},
+		{"id": "SLOW", "wam": 360, "wac": 4.5, "face": 250000, "prepay_cpr": 0.05}
+	]`
+	w := postLoans(t, router, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	warned := map[string]bool{}
+	calculated := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log output is not valid JSON: %v", err)
+		}
+		loanID, _ := entry["loan_id"].(string)
+		switch {
+		case entry["level"] == "WARN" && entry["msg"] == "high prepayment rate detected":
+			warned[loanID] = true
+		case entry["level"] == "INFO" && entry["msg"] == "amortization calculated":
+			calculated[loanID] = true
+			for _, field := range []string{"smm", "total_interest", "wal", "final_end_bal"} {
+				if _, ok := entry[field]; !ok {
+					t.Errorf("loan %s: log entry missing field %s", loanID, field)
+				}
+			}
+		}
+	}
+
+	if !warned["FAST"] {
+		t.Error("expected WARN for loan with CPR above threshold")
+	}
+	if warned["SLOW"] {
+		t.Error("unexpected WARN for loan with CPR below threshold")
+	}
+	if !calculated["FAST"] || !calculated["SLOW"] {
+		t.Errorf("expected INFO result entries for both loans, got %v", calculated)
+	}
+}
+
+func TestRequestCashflow_LogsEarlyPayoffOnce(t *testing.T) {
+	buf := captureLoanLogger(t)
+	router := newTestRouter()
+
+	body := `[
+		{"id": "PAIDOFF", "wam": 360, "wac": 6.0, "face": 200000, "prepay_cpr": 0.60},
+		{"id": "FULLTERM", "wam": 360, "wac": 6.0, "face": 200000}
+	]`
+	w := postLoans(t, router, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	warnings := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log output is not valid JSON: %v", err)
+		}
+		if entry["level"] != "WARN" || entry["msg"] != "prepayments retired loan before maturity" {
+			continue
+		}
+		loanID, _ := entry["loan_id"].(string)
+		warnings[loanID]++
+		for _, field := range []string{"request_id", "effective_maturity", "wam"} {
+			if _, ok := entry[field]; !ok {
+				t.Errorf("loan %s: early payoff entry missing field %s", loanID, field)
+			}
+		}
+	}
+
+	if warnings["PAIDOFF"] != 1 {
+		t.Errorf("expected one early payoff WARN for PAIDOFF, got %d", warnings["PAIDOFF"])
+	}
+	if warnings["FULLTERM"] != 0 {
+		t.Error("unexpected early payoff WARN for a full-term loan")
+	}
+}
+
+func TestMaxWorkersFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    int
+		wantErr bool
+	}{
+		{name: "unset uses default", config: map[string]interface{}{}, want: defaultMaxWorkers},
+		{name: "configured", config: map[string]interface{}{"MAX_WORKERS": float64(8)}, want: 8},
+		{name: "zero", config: map[string]interface{}{"MAX_WORKERS": float64(0)}, wantErr: true},
+		{name: "negative", config: map[string]interface{}{"MAX_WORKERS": float64(-4)}, wantErr: true},
+		{name: "fractional", config: map[string]interface{}{"MAX_WORKERS": 2.5}, wantErr: true},
+		{name: "string", config: map[string]interface{}{"MAX_WORKERS": "10"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := maxWorkersFromConfig(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %d workers, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRequestCashflow_BoundedByWorkerPool(t *testing.T) {
+	const poolSize = 2
+	const numLoans = 6
+
+	useResultCache(t, 0) // The stubbed calculation must run for every loan
+
+	originalPool, originalCalc := workerPool, calculateTable
+	workerPool = make(chan struct{}, poolSize)
+	t.Cleanup(func() { workerPool, calculateTable = originalPool, originalCalc })
+
+	var inFlight, peak int32
+	release := make(chan struct{})
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release // barrier: hold the worker slot until the test releases it
+		atomic.AddInt32(&inFlight, -1)
+		return l.GetAmortizationTable()
+	}
+
+	router := newTestRouter()
+	loans := make([]string, numLoans)
+	for i := range loans {
+		loans[i] = `{"id": "LOAN", "wam": 12, "wac": 4.5, "face": 1000}`
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- postLoans(t, router, "["+strings.Join(loans, ",")+"]")
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&inFlight) < poolSize {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d workers to start", poolSize)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Give the remaining goroutines a chance to exceed the bound
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&inFlight); n != poolSize {
+		t.Errorf("expected %d calculations in flight, got %d", poolSize, n)
+	}
+
+	close(release)
+	w := <-done
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if p := atomic.LoadInt32(&peak); p > poolSize {
+		t.Errorf("expected at most %d concurrent calculations, peak was %d", poolSize, p)
+	}
+}
+
+func TestCalculateBatch_ReportsProgress(t *testing.T) {
+	const numLoans = 6
+
+	originalPool := workerPool
+	workerPool = make(chan struct{}, 2)
+	t.Cleanup(func() { workerPool = originalPool })
+
+	loans := make([]amortization.LoanInfo, numLoans)
+	var calculated int32
+	allCalculated := make(chan struct{})
+
+	var reports []int
+	calculateBatch(loans, func(index int, l amortization.LoanInfo) {
+		if atomic.AddInt32(&calculated, 1) == numLoans {
+			close(allCalculated)
+		}
+	}, func(done, total int) {
+		if total != numLoans {
+			t.Errorf("expected total %d, got %d", numLoans, total)
+		}
+		// Hold the first report until every loan has been calculated: the
+		// batch only finishes if workers don't wait on the callback
+		if done == 1 {
+			select {
+			case <-allCalculated:
+			case <-time.After(2 * time.Second):
+				t.Error("workers waited on the progress callback")
+			}
+		}
+		reports = append(reports, done)
+	})
+
+	if len(reports) != numLoans {
+		t.Fatalf("expected %d progress reports, got %d", numLoans, len(reports))
+	}
+	for i, done := range reports {
+		if done != i+1 {
+			t.Errorf("expected report %d to be %d done, got %d", i, i+1, done)
+		}
+	}
+}
+
+func TestGetServiceInfo_ReportsMaxWorkers(t *testing.T) {
+	originalPool := workerPool
+	workerPool = make(chan struct{}, 7)
+	t.Cleanup(func() { workerPool = originalPool })
+
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodGet, "/info", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var info map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if info["max_workers"] != float64(7) {
+		t.Errorf("expected max_workers 7, got %v", info["max_workers"])
+	}
+}
+
+func TestGetLoanSummary_OmitsPeriodArrays(t *testing.T) {
+	router := newTestRouter()
+	w := postLoans(t, router, `[{"id": "SUMMARY001", "wam": 360, "wac": 4.5, "face": 250000, "prepay_cpr": 0.06}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/loans/SUMMARY001/summary", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		LoanID  string                 `json:"loan_id"`
+		Summary map[string]interface{} `json:"summary"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	for _, field := range []string{"wal", "total_interest", "factor_curve"} {
+		if _, ok := body.Summary[field]; !ok {
+			t.Errorf("summary missing field %s", field)
+		}
+	}
+	for _, column := range []string{"beg_bal", "interest", "principal", "end_bal", "period"} {
+		if _, ok := body.Summary[column]; ok {
+			t.Errorf("summary unexpectedly contains per-period column %s", column)
+		}
+	}
+	if strings.Contains(w.Body.String(), "cashflow") {
+		t.Error("summary response unexpectedly contains the full cashflow table")
+	}
+}
+
+func TestGetLoanSummary_UnknownID(t *testing.T) {
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodGet, "/loans/DOES-NOT-EXIST/summary", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestGetLoanSummary_Timeout(t *testing.T) {
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "SUMMARY003", "wam": 360, "wac": 5.0, "face": 100000}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	useResultCache(t, 0) // The stubbed calculation must run
+	originalCalc, originalTimeout := calculateTable, loanTimeout
+	loanTimeout = 20 * time.Millisecond
+	hang := make(chan struct{})
+	t.Cleanup(func() {
+		close(hang) // Let the abandoned calculation finish
+		calculateTable, loanTimeout = originalCalc, originalTimeout
+	})
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		<-hang
+		return l.GetAmortizationTable()
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/loans/SUMMARY003/summary", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), codeTimeout) {
+		t.Errorf("expected code %s, got %s", codeTimeout, w.Body.String())
+	}
+}
+
+func TestRequestCashflow_SummaryQuery(t *testing.T) {
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodPost, "/loans?summary=true",
+		strings.NewReader(`[{"id": "SUMMARY002", "wam": 120, "wac": 5.0, "face": 100000}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "cashflow") || strings.Contains(w.Body.String(), "beg_bal") {
+		t.Error("summary=true response unexpectedly contains per-period arrays")
+	}
+	if !strings.Contains(w.Body.String(), "factor_curve") {
+		t.Error("summary=true response missing summary fields")
+	}
+}
+
+func TestGetLoans_FilterByTag(t *testing.T) {
+	router := newTestRouter()
+	body := `[
+		{"id": "TAG001", "wam": 120, "wac": 4.0, "face": 100000, "tags": {"servicer": "XYZ", "vintage": "2021"}},
+		{"id": "TAG002", "wam": 120, "wac": 4.0, "face": 100000, "tags": {"servicer": "ABC", "vintage": "2021"}},
+		{"id": "TAG003", "wam": 120, "wac": 4.0, "face": 100000, "tags": {"servicer": "XYZ", "vintage": "2022"}},
+		{"id": "TAG004", "wam": 120, "wac": 4.0, "face": 100000}
+	]`
+	if w := postLoans(t, router, body); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "servicer:XYZ", want: []string{"TAG001", "TAG003"}},
+		{query: "servicer:ABC", want: []string{"TAG002"}},
+		{query: "vintage:2022", want: []string{"TAG003"}},
+		{query: "servicer:NONE", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/loans?tag="+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+
+			var loans []amortization.LoanInfo
+			if err := json.Unmarshal(w.Body.Bytes(), &loans); err != nil {
+				t.Fatalf("response is not valid JSON: %v", err)
+			}
+
+			got := []string{}
+			for _, loan := range loans {
+				if strings.HasPrefix(loan.ID, "TAG") {
+					got = append(got, loan.ID)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected loans %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetLoans_MalformedTag(t *testing.T) {
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodGet, "/loans?tag=servicer", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRequestCashflow_CheckQuery(t *testing.T) {
+	useResultCache(t, 0) // The stubbed calculation must run for every loan
+	originalCalc := calculateTable
+	t.Cleanup(func() { calculateTable = originalCalc })
+
+	// Corrupt the generated table so the consistency check has something to find
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		table := l.GetAmortizationTable()
+		table.Principal[2] += 100
+		return table
+	}
+
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodPost, "/loans?check=true",
+		strings.NewReader(`[{"id": "CHECK001", "wam": 12, "wac": 5.0, "face": 10000}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Results []struct {
+			CheckError string `json:"check_error"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(resp.Results[0].CheckError, "period 3: ending balance") {
+		t.Errorf("expected check_error for period 3, got %q", resp.Results[0].CheckError)
+	}
+}
+
+func TestRequestCashflow_ReportsEncodeErrors(t *testing.T) {
+	useResultCache(t, 0) // The stubbed calculation must run for every loan
+	originalCalc := calculateTable
+	t.Cleanup(func() { calculateTable = originalCalc })
+
+	// A NaN cannot be encoded as JSON
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		table := l.GetAmortizationTable()
+		table.Interest[2] = math.NaN()
+		return table
+	}
+
+	router := newTestRouter()
+	for _, query := range []string{"layout=rows", "fields=interest"} {
+		req := httptest.NewRequest(http.MethodPost, "/loans?"+query,
+			strings.NewReader(`[{"id": "ENCODE001", "wam": 12, "wac": 5.0, "face": 10000}]`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Results []map[string]interface{} `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: response is not valid JSON: %v", query, err)
+		}
+		result := resp.Results[0]
+		if _, ok := result["cashflow"]; ok {
+			t.Errorf("%s: expected no cashflow, got %v", query, result["cashflow"])
+		}
+		if msg, _ := result["cashflow_error"].(string); !strings.Contains(msg, "NaN") {
+			t.Errorf("%s: expected cashflow_error naming the NaN, got %v", query, result)
+		}
+	}
+}
+
+func TestLocationFromConfig(t *testing.T) {
+	loc := locationFromConfig(map[string]interface{}{"TIMEZONE": "Asia/Tokyo"})
+	if loc.String() != "Asia/Tokyo" {
+		t.Fatalf("expected Asia/Tokyo, got %s", loc)
+	}
+
+	stamp := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC).In(loc).Format(time.RFC3339)
+	if stamp != "2024-01-15T21:00:00+09:00" {
+		t.Errorf("expected Tokyo offset +09:00, got %s", stamp)
+	}
+
+	if loc := locationFromConfig(map[string]interface{}{}); loc != time.Local {
+		t.Errorf("expected time.Local when unset, got %s", loc)
+	}
+}
+
+func TestLocationFromConfig_InvalidFallsBack(t *testing.T) {
+	buf := captureLoanLogger(t)
+
+	loc := locationFromConfig(map[string]interface{}{"TIMEZONE": "Mars/Olympus_Mons"})
+	if loc != time.Local {
+		t.Errorf("expected fallback to time.Local, got %s", loc)
+	}
+	if !strings.Contains(buf.String(), `"level":"WARN"`) {
+		t.Error("expected WARN log for invalid timezone")
+	}
+}
+
+func TestLoggerFromConfig_WritesToLogPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+
+	l, err := loggerFromConfig(map[string]interface{}{"LOG_PATH": dir, "LOG_FILE": "andy-warhol.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Info("logger configured", slog.String("loan_id", "LOG001"))
+
+	content, err := os.ReadFile(filepath.Join(dir, "andy-warhol.log"))
+	if err != nil {
+		t.Fatalf("expected log file in LOG_PATH: %v", err)
+	}
+	if !strings.Contains(string(content), `"loan_id":"LOG001"`) {
+		t.Errorf("expected structured record in log file, got %s", content)
+	}
+
+	// Without LOG_FILE the logger keeps a file per day
+	daily, err := loggerFromConfig(map[string]interface{}{"LOG_PATH": dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer daily.Close()
+	if _, err := os.Stat(filepath.Join(dir, time.Now().Format("2006-01-02")+".log")); err != nil {
+		t.Errorf("expected daily log file: %v", err)
+	}
+}
+
+func TestLoggerFromConfig_FormatAndRedaction(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := loggerFromConfig(map[string]interface{}{
+		"LOG_PATH":        dir,
+		"LOG_FILE":        "andy-warhol.log",
+		"LOG_FORMAT":      "text",
+		"LOG_REDACT_KEYS": []interface{}{"loan_id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Info("logger configured", slog.String("loan_id", "SECRET001"), slog.Int("count", 3))
+
+	content, err := os.ReadFile(filepath.Join(dir, "andy-warhol.log"))
+	if err != nil {
+		t.Fatalf("expected log file in LOG_PATH: %v", err)
+	}
+	record := string(content)
+	if !strings.Contains(record, `msg="logger configured"`) || !strings.Contains(record, "count=3") {
+		t.Errorf("expected a text record, got %s", record)
+	}
+	if strings.Contains(record, "SECRET001") || !strings.Contains(record, "loan_id=redacted:") {
+		t.Errorf("expected loan_id to be redacted, got %s", record)
+	}
+}
+
+func TestLoggerFromConfig_Rejects(t *testing.T) {
+	testCases := []struct {
+		name  string
+		key   string
+		value interface{}
+	}{
+		{name: "unknown format", key: "LOG_FORMAT", value: "xml"},
+		{name: "format not a string", key: "LOG_FORMAT", value: float64(1)},
+		{name: "redact keys not a list", key: "LOG_REDACT_KEYS", value: "loan_id"},
+		{name: "empty redact key", key: "LOG_REDACT_KEYS", value: []interface{}{""}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := map[string]interface{}{"LOG_PATH": t.TempDir(), tc.key: tc.value}
+			if l, err := loggerFromConfig(config); err == nil {
+				l.Close()
+				t.Errorf("expected an error for %s %v", tc.key, tc.value)
+			} else if !strings.Contains(err.Error(), tc.key) {
+				t.Errorf("expected the error to name %s, got %v", tc.key, err)
+			}
+		})
+	}
+}
+
+func TestRequestCashflow_LocalDateUsesConfiguredZone(t *testing.T) {
+	original := location
+	location = locationFromConfig(map[string]interface{}{"TIMEZONE": "Asia/Tokyo"})
+	t.Cleanup(func() { location = original })
+
+	router := newTestRouter()
+	w := postLoans(t, router, `[{"id": "TZ001", "wam": 12, "wac": 5.0, "face": 10000}]`)
+
+	var resp struct {
+		LocalDate string `json:"local_date"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if !strings.HasSuffix(resp.LocalDate, "+09:00") {
+		t.Errorf("expected local_date with +09:00 offset, got %q", resp.LocalDate)
+	}
+}
+
+func TestRequestCashflow_RowLayout(t *testing.T) {
+	router := newTestRouter()
+	body := `[{"id": "LAYOUT001", "wam": 12, "wac": 5.0, "face": 10000, "prepay_cpr": 0.1}]`
+
+	post := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/loans"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var rowsResp struct {
+		Results []struct {
+			Cashflow []amortization.PeriodRow `json:"cashflow"`
+		} `json:"results"`
+	}
+	w := post("?layout=rows")
+	if err := json.Unmarshal(w.Body.Bytes(), &rowsResp); err != nil {
+		t.Fatalf("row layout response is not valid JSON: %v", err)
+	}
+
+	var colsResp struct {
+		Results []struct {
+			Cashflow amortization.AmortizationTable `json:"cashflow"`
+		} `json:"results"`
+	}
+	w = post("")
+	if err := json.Unmarshal(w.Body.Bytes(), &colsResp); err != nil {
+		t.Fatalf("column layout response is not valid JSON: %v", err)
+	}
+
+	rows := rowsResp.Results[0].Cashflow
+	table := colsResp.Results[0].Cashflow
+	if len(rows) != len(table.Period) {
+		t.Fatalf("expected %d rows, got %d", len(table.Period), len(rows))
+	}
+	for i, row := range rows {
+		if row.Principal != table.Principal[i] || row.EndBal != table.EndBal[i] || row.PrepayAmount != table.PrepayAmountArr[i] {
+			t.Errorf("period %d: row %+v does not match columns", i+1, row)
+		}
+	}
+
+	if w := post("?layout=diagonal"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown layout, got %d", w.Code)
+	}
+}
+
+func TestRespondJSON_CompactSmallerThanIndented(t *testing.T) {
+	router := newTestRouter()
+	body := `[{"id": "COMPACT001", "wam": 360, "wac": 4.5, "face": 250000, "prepay_cpr": 0.06}]`
+
+	post := func(query string) []byte {
+		req := httptest.NewRequest(http.MethodPost, "/loans"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w.Body.Bytes()
+	}
+
+	compact := post("")
+	indented := post("?pretty=true")
+
+	if len(compact) >= len(indented) {
+		t.Errorf("expected compact output (%d bytes) to be smaller than indented (%d bytes)", len(compact), len(indented))
+	}
+	if bytes.Contains(compact, []byte("\n")) {
+		t.Error("compact output unexpectedly contains newlines")
+	}
+
+	var fromCompact, fromIndented struct {
+		Results []struct {
+			Cashflow amortization.AmortizationTable `json:"cashflow"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(compact, &fromCompact); err != nil {
+		t.Fatalf("compact output is not valid JSON: %v", err)
+	}
+	if err := json.Unmarshal(indented, &fromIndented); err != nil {
+		t.Fatalf("indented output is not valid JSON: %v", err)
+	}
+	a, b := fromCompact.Results[0].Cashflow, fromIndented.Results[0].Cashflow
+	for i := range a.EndBal {
+		if a.EndBal[i] != b.EndBal[i] {
+			t.Fatalf("period %d: compact and indented tables differ", i+1)
+		}
+	}
+}
+
+func TestRequestCashflow_LeanOmitsEmptyFields(t *testing.T) {
+	router := newTestRouter()
+	body := `[{"id": "LEAN001", "wam": 360, "wac": 4.5, "face": 250000, "prepay_cpr": 0.06}]`
+
+	post := func(query string) []byte {
+		req := httptest.NewRequest(http.MethodPost, "/loans"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w.Body.Bytes()
+	}
+
+	lean := post("?lean=true")
+	full := post("")
+	if len(lean) >= len(full) {
+		t.Errorf("expected lean output (%d bytes) to be smaller than full (%d bytes)", len(lean), len(full))
+	}
+	if !bytes.Contains(full, []byte(`"delinq_arrays"`)) || bytes.Contains(lean, []byte(`"delinq_arrays"`)) {
+		t.Error("expected only the full output to carry the empty delinquency arrays")
+	}
+
+	var fromLean struct {
+		Results []struct {
+			Cashflow amortization.AmortizationTable `json:"cashflow"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(lean, &fromLean); err != nil {
+		t.Fatalf("lean output is not valid JSON: %v", err)
+	}
+	if got := len(fromLean.Results[0].Cashflow.EndBal); got != 360 {
+		t.Errorf("expected 360 periods in the lean table, got %d", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/loans?lean=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if bytes.Contains(w.Body.Bytes(), []byte(`"static_dq"`)) || !bytes.Contains(w.Body.Bytes(), []byte(`"id":"LEAN001"`)) {
+		t.Errorf("expected lean stored loans without zero-value fields, got %s", w.Body.String())
+	}
+}
+
+func TestRespondJSON_CompactUnlessPretty(t *testing.T) {
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "PRETTY001", "wam": 12, "wac": 4.5, "face": 1000}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	get := func(query string) string {
+		req := httptest.NewRequest(http.MethodGet, "/loans"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	if strings.Contains(get(""), "\n  ") {
+		t.Error("expected compact list output by default")
+	}
+	if !strings.Contains(get("?pretty=true"), "\n") {
+		t.Error("expected indented list output with pretty=true")
+	}
+}
+
+func TestRequestCashflow_WarnsOnDecimalWac(t *testing.T) {
+	buf := captureLoanLogger(t)
+	router := newTestRouter()
+
+	w := postLoans(t, router, `[{"id": "DECWAC001", "wam": 360, "wac": 0.045, "face": 250000}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(buf.String(), "wac looks like a decimal rate") {
+		t.Errorf("expected decimal WAC warning, got logs: %s", buf.String())
+	}
+}
+
+func TestRequestCashflow_BodyTooLarge(t *testing.T) {
+	original := maxBodyBytes
+	maxBodyBytes = 256
+	t.Cleanup(func() { maxBodyBytes = original })
+
+	router := newTestRouter()
+	loans := make([]string, 20)
+	for i := range loans {
+		loans[i] = `{"id": "BIG", "wam": 360, "wac": 4.5, "face": 250000}`
+	}
+	w := postLoans(t, router, "["+strings.Join(loans, ",")+"]")
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if _, ok := resp["error"]; !ok {
+		t.Error("expected error field in 413 response")
+	}
+
+	// A body under the limit still succeeds
+	if w := postLoans(t, router, `[{"id": "SMALL", "wam": 12, "wac": 4.5, "face": 1000}]`); w.Code != http.StatusOK {
+		t.Errorf("expected status 200 under the limit, got %d", w.Code)
+	}
+}
+
+func TestMaxBodyBytesFromConfig(t *testing.T) {
+	if got, err := maxBodyBytesFromConfig(map[string]interface{}{}); err != nil || got != defaultMaxBodyBytes {
+		t.Errorf("expected default %d, got %d (err %v)", defaultMaxBodyBytes, got, err)
+	}
+	if got, err := maxBodyBytesFromConfig(map[string]interface{}{"MAX_BODY_BYTES": float64(1024)}); err != nil || got != 1024 {
+		t.Errorf("expected 1024, got %d (err %v)", got, err)
+	}
+	if _, err := maxBodyBytesFromConfig(map[string]interface{}{"MAX_BODY_BYTES": float64(-1)}); err == nil {
+		t.Error("expected error for negative limit")
+	}
+}
+
+func TestGetServiceInfo_ReportsRegisteredDayCount(t *testing.T) {
+	amortization.RegisterDayCount("TEST/INFO", func(start, end time.Time) float64 { return 0 })
+
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodGet, "/info", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var info struct {
+		Capabilities amortization.Capabilities `json:"capabilities"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+
+	found := false
+	for _, name := range info.Capabilities.DayCounts {
+		if name == "TEST/INFO" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected registered day count in /info, got %v", info.Capabilities.DayCounts)
+	}
+}
+
+func TestGetLoans_ConcurrentWithPost(t *testing.T) {
+	router := newTestRouter()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`[{"id": "RACE%03d", "wam": 12, "wac": 5.0, "face": 10000, "tags": {"suite": "race"}}]`, i)
+			if w := postLoans(t, router, body); w.Code != http.StatusOK {
+				t.Errorf("expected status 200, got %d", w.Code)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for _, url := range []string{"/loans", "/loans?tag=suite:race"} {
+				req := httptest.NewRequest(http.MethodGet, url, nil)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code != http.StatusOK || !json.Valid(w.Body.Bytes()) {
+					t.Errorf("GET %s: expected valid JSON with status 200, got %d", url, w.Code)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	req := httptest.NewRequest(http.MethodGet, "/loans?tag=suite:race", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var loans []amortization.LoanInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &loans); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if len(loans) != 8 {
+		t.Errorf("expected 8 stored loans, got %d", len(loans))
+	}
+}
+
+func TestRequestCashflow_TrimQuery(t *testing.T) {
+	router := newTestRouter()
+	body := `[{"id": "TRIMQ001", "wam": 360, "wac": 6.0, "face": 100000, "prepay_cpr": 0.5}]`
+
+	periods := func(url string) int {
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			Results []struct {
+				Summary amortization.TableSummary `json:"summary"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("response is not valid JSON: %v", err)
+		}
+		return resp.Results[0].Summary.Periods
+	}
+
+	if full := periods("/loans?summary=true"); full != 360 {
+		t.Errorf("expected 360 periods without trim, got %d", full)
+	}
+	if trimmed := periods("/loans?summary=true&trim=true"); trimmed >= 360 {
+		t.Errorf("expected trimmed table shorter than 360 periods, got %d", trimmed)
+	}
+}
+
+func TestRequestCashflow_FieldsQuery(t *testing.T) {
+	router := newTestRouter()
+	body := `[{"id": "FIELDS001", "wam": 12, "wac": 5.0, "face": 10000}]`
+
+	req := httptest.NewRequest(http.MethodPost, "/loans?fields=period,principal,interest", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Results []struct {
+			Cashflow map[string]json.RawMessage `json:"cashflow"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	cashflow := resp.Results[0].Cashflow
+	if len(cashflow) != 3 {
+		t.Errorf("expected 3 columns, got %d: %v", len(cashflow), cashflow)
+	}
+	for _, name := range []string{"period", "principal", "interest"} {
+		if _, ok := cashflow[name]; !ok {
+			t.Errorf("expected column %s in projected cashflow", name)
+		}
+	}
+}
+
+func TestRequestCashflow_UnknownField(t *testing.T) {
+	router := newTestRouter()
+	body := `[{"id": "FIELDS002", "wam": 12, "wac": 5.0, "face": 10000}]`
+
+	req := httptest.NewRequest(http.MethodPost, "/loans?fields=principal,coupon", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `unknown field \"coupon\"`) {
+		t.Errorf("expected unknown field error, got %s", w.Body.String())
+	}
+}
+
+func TestRequestCashflow_EmptyBatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "empty array", body: `[]`, wantStatus: http.StatusOK},
+		{name: "empty body", body: ``, wantStatus: http.StatusBadRequest},
+		{name: "whitespace body", body: "  \n", wantStatus: http.StatusBadRequest},
+		{name: "null", body: `null`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter()
+			w := postLoans(t, router, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			var resp map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if resp["count"] != float64(0) {
+					t.Errorf("expected count 0, got %v", resp["count"])
+				}
+				if results, _ := resp["results"].([]interface{}); len(results) != 0 {
+					t.Errorf("expected no results, got %v", resp["results"])
+				}
+				return
+			}
+			if resp["code"] != codeInvalidJSON || resp["error"] != noLoansMessage {
+				t.Errorf("expected %s with %q, got %v", codeInvalidJSON, noLoansMessage, resp)
+			}
+		})
+	}
+}
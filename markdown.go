@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// defaultMarkdownRows is how many leading and trailing periods a Markdown
+// schedule shows when max_rows is not given
+const defaultMarkdownRows = 12
+
+// markdownContentType is the media type of format=md responses
+const markdownContentType = "text/markdown; charset=utf-8"
+
+// markdownRequested reads the format and max_rows query parameters of the
+// endpoints returning amortization tables. format=md asks for each schedule
+// as a Markdown table of its first and last max_rows periods; the default
+// "json" format is the usual response. It responds 400 and returns ok false
+// when either parameter is invalid.
+func markdownRequested(c *gin.Context) (markdown bool, maxRows int, ok bool) {
+	switch format := c.DefaultQuery("format", "json"); format {
+	case "json":
+		return false, 0, true
+	case "md":
+	default:
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, fmt.Sprintf("format must be json or md, got %q", format))
+		return false, 0, false
+	}
+
+	maxRows = defaultMarkdownRows
+	if raw, set := c.GetQuery("max_rows"); set {
+		var err error
+		if maxRows, err = strconv.Atoi(raw); err != nil || maxRows < 0 {
+			respondError(c, http.StatusBadRequest, codeInvalidQuery, fmt.Sprintf("max_rows must be a non-negative integer, got %q", raw))
+			return false, 0, false
+		}
+	}
+	return true, maxRows, true
+}
+
+// markdownSection is one loan of a Markdown batch response: its table, and a
+// note such as a failed consistency check. A loan whose calculation failed
+// has only the note.
+type markdownSection struct {
+	loanID string
+	table  *amortization.AmortizationTable
+	note   string
+}
+
+// respondMarkdownTable writes a single schedule as a Markdown table
+func respondMarkdownTable(c *gin.Context, table *amortization.AmortizationTable, maxRows int) {
+	var buf bytes.Buffer
+	if err := table.WriteMarkdown(&buf, maxRows); err != nil {
+		respondError(c, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, markdownContentType, buf.Bytes())
+}
+
+// respondMarkdownTables writes a batch of schedules as Markdown, each under a
+// heading naming its loan and in the order of the batch
+func respondMarkdownTables(c *gin.Context, sections []markdownSection, maxRows int) {
+	var buf bytes.Buffer
+	for i, section := range sections {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "### %s\n\n", section.loanID)
+		if section.note != "" {
+			fmt.Fprintf(&buf, "%s\n", section.note)
+		}
+		if section.table == nil {
+			continue
+		}
+		if section.note != "" {
+			buf.WriteString("\n")
+		}
+		if err := section.table.WriteMarkdown(&buf, maxRows); err != nil {
+			respondError(c, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+	}
+	c.Data(http.StatusOK, markdownContentType, buf.Bytes())
+}
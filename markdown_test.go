@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// markdownHeaderRow is the first row of every Markdown schedule
+const markdownHeaderRow = "| Period | Beg Bal | Interest | Principal | Prepay | End Bal |"
+
+func TestRequestCashflow_Markdown(t *testing.T) {
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodPost, "/loans?format=md&max_rows=5", strings.NewReader(`[
+		{"id": "MD001", "wam": 360, "wac": 4.5, "face": 250000},
+		{"id": "MD002", "wam": 6, "wac": 5.0, "face": 10000}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+		t.Errorf("expected a Markdown content type, got %q", ct)
+	}
+
+	body := w.Body.String()
+	first, second, ok := strings.Cut(body, "### MD002\n\n")
+	if !ok || !strings.HasPrefix(first, "### MD001\n\n"+markdownHeaderRow+"\n") {
+		t.Fatalf("expected a section per loan in batch order, got:\n%s", body)
+	}
+	// The 360-month schedule is elided; the 6-month one is written in full
+	if lines := strings.Split(strings.TrimSpace(first), "\n"); len(lines) != 15 || !strings.Contains(lines[9], "periods omitted") {
+		t.Errorf("expected five rows either side of an elision for MD001, got:\n%s", first)
+	}
+	if lines := strings.Split(strings.TrimSpace(second), "\n"); len(lines) != 8 || lines[0] != markdownHeaderRow {
+		t.Errorf("expected all six periods for MD002, got:\n%s", second)
+	}
+}
+
+func TestCalculateFromQuery_Markdown(t *testing.T) {
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodGet, "/calculate?wam=360&wac=4.5&face=250000&format=md&max_rows=5", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if lines[0] != markdownHeaderRow {
+		t.Errorf("unexpected header row %q", lines[0])
+	}
+	if len(lines) != 13 || !strings.Contains(lines[7], "periods omitted") {
+		t.Errorf("expected five rows either side of an elision, got:\n%s", w.Body.String())
+	}
+}
+
+func TestMarkdown_RejectsBadQueries(t *testing.T) {
+	router := newTestRouter()
+	loans := `[{"id": "MD003", "wam": 12, "wac": 4.5, "face": 1000}]`
+	for _, tc := range []struct{ method, target, body string }{
+		{http.MethodPost, "/loans?format=csv", loans},
+		{http.MethodPost, "/loans?format=md&max_rows=-1", loans},
+		{http.MethodPost, "/loans?format=md&summary=true", loans},
+		{http.MethodPost, "/loans?format=md&layout=rows", loans},
+		{http.MethodPost, "/loans?format=md&fields=interest", loans},
+		{http.MethodGet, "/calculate?wam=12&wac=4.5&face=1000&format=md&max_rows=x", ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), codeInvalidQuery) {
+			t.Errorf("%s %s: expected status 400 %s, got %d: %s", tc.method, tc.target, codeInvalidQuery, w.Code, w.Body.String())
+		}
+	}
+}
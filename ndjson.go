@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// ndjsonContentType selects streaming submission on POST /loans: one loan
+// object per line
+const ndjsonContentType = "application/x-ndjson"
+
+// wacFractionThreshold enables correcting coupons supplied as fractions on
+// NDJSON ingestion: a wac below it is multiplied by 100. Zero leaves coupons
+// as sent. Set from WAC_FRACTION_THRESHOLD.
+var wacFractionThreshold = 0.0
+
+// requestCashflowNDJSON handles a POST /loans body of newline-delimited loans.
+// Each loan is queued on the worker pool as soon as its line is decoded, and
+// reading pauses while the pool is full, so memory stays bounded by the pool
+// size rather than the batch size. Only summaries are returned; full tables
+// are persisted when OUTPUT_PATH is set. A loan that fails validation is
+// reported in its result and not stored; a malformed line aborts the request
+// with nothing stored. Coupons below wacFractionThreshold are read as
+// fractions and corrected before validation. Each loan is admitted to the
+// queue as it is read: a stream whose first loan finds the queue full is
+// answered 429 like any batch, and a later loan that finds it full fails in
+// its result and is not stored. Loans that pass validation
+// publish the same status transitions as a JSON array submission.
+func requestCashflowNDJSON(c *gin.Context) {
+	runID := newRunID()
+	reqLog := requestLogger(c)
+	reqLog.Info("cashflow stream received", slog.String("content_type", ndjsonContentType))
+	decoder := json.NewDecoder(c.Request.Body)
+
+	var (
+		wg      sync.WaitGroup
+		resMu   sync.Mutex // Guards results and accepted
+		results []gin.H
+		// accepted holds the loans that passed validation, stored once the stream ends
+		accepted []amortization.LoanInfo
+		failures int
+		queued   int // Loans admitted to the queue
+	)
+
+	for index := 0; ; index++ {
+		var loan amortization.LoanInfo
+		err := decoder.Decode(&loan)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			wg.Wait()
+			respondBindError(c, fmt.Errorf("record %d: %w", index+1, err))
+			return
+		}
+
+		resMu.Lock()
+		results = append(results, nil)
+		resMu.Unlock()
+
+		if sent := loan.Wac; loan.NormalizeFractionalWac(wacFractionThreshold) {
+			reqLog.Warn("wac read as a fraction and converted to percentage points",
+				slog.Int("record", index+1),
+				slog.String("loan_id", loan.ID),
+				slog.Float64("wac_sent", sent),
+				slog.Float64("wac", loan.Wac),
+			)
+		}
+		if err := loan.Validate(); err != nil {
+			resMu.Lock()
+			results[index] = gin.H{"loan_id": loan.ID, "code": codeValidationFailed, "error": err.Error()}
+			failures++
+			resMu.Unlock()
+			continue
+		}
+
+		if queued == 0 {
+			if !admitBatch(c, 1) {
+				wg.Wait()
+				return
+			}
+		} else if !admitLoans(1) {
+			resMu.Lock()
+			results[index] = gin.H{"loan_id": loan.ID, "status": "failed", "code": codeQueueFull, "error": "worker queue is full; retry later"}
+			failures++
+			resMu.Unlock()
+			continue
+		}
+		queued++
+
+		loanEvents.publish(runID, loan.ID, statusQueued)
+		// Blocks until a worker is free, which pauses reading the stream
+		workerPool <- struct{}{}
+		wg.Add(1)
+		go func(index int, l amortization.LoanInfo) {
+			releaseWorker := sync.OnceFunc(func() { <-workerPool })
+			defer func() {
+				releaseWorker()
+				releaseLoans(1)
+				wg.Done()
+			}()
+
+			loanEvents.publish(runID, l.ID, statusProcessing)
+			stored := l
+			assumptions := assumptionsHash(l)
+			amortTable, err := calculateTableWithin(reqLog, &l)
+			if err != nil {
+				resMu.Lock()
+				results[index] = failedResult(l.ID, err)
+				failures++
+				resMu.Unlock()
+				loanEvents.publish(runID, l.ID, statusFailed)
+				return
+			}
+			logAmortizationResult(reqLog, l, amortTable)
+
+			result := gin.H{"loan_id": l.ID, "summary": amortTable.Summary()}
+			status := statusDone
+			if outputDir != "" {
+				releaseWorker() // The write waits on the write pool instead
+				if persistCashflow(reqLog, result, runID, l.ID, assumptions, amortTable) != nil {
+					status = statusFailed
+				}
+			}
+
+			resMu.Lock()
+			results[index] = result
+			accepted = append(accepted, stored)
+			resMu.Unlock()
+			loanEvents.publish(runID, l.ID, status)
+		}(index, loan)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	mortgages = append(mortgages, accepted...)
+	mu.Unlock()
+
+	reqLog.Info("ndjson batch processed",
+		slog.String("run_id", runID),
+		slog.Int("count", len(results)),
+		slog.Int("failed", failures),
+	)
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"run_id":     runID,
+		"count":      len(results),
+		"succeeded":  len(results) - failures,
+		"failed":     failures,
+		"local_date": formatTimestamp(clock()),
+		"results":    results,
+	})
+}
+
+// wacFractionThresholdFromConfig reads WAC_FRACTION_THRESHOLD from the
+// config, leaving the correction disabled when unset. The value must be a
+// non-negative number.
+func wacFractionThresholdFromConfig(config map[string]interface{}) (float64, error) {
+	raw, ok := config["WAC_FRACTION_THRESHOLD"]
+	if !ok {
+		return 0, nil
+	}
+
+	value, ok := raw.(float64)
+	if !ok || value < 0 {
+		return 0, fmt.Errorf("WAC_FRACTION_THRESHOLD must be a non-negative number, got %v", raw)
+	}
+	return value, nil
+}
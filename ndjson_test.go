@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+func postNDJSON(router http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/loans", strings.NewReader(body))
+	req.Header.Set("Content-Type", ndjsonContentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestCashflow_NDJSON(t *testing.T) {
+	router := newTestRouter()
+	body := `{"id": "NDJSON001", "wam": 120, "wac": 4.0, "face": 100000, "tags": {"suite": "ndjson"}}
+{"id": "NDJSON002", "wam": 240, "wac": 5.0, "face": 200000, "tags": {"suite": "ndjson"}}
+
+{"id": "NDJSON003", "wam": 360, "wac": 6.0, "face": 300000, "tags": {"suite": "ndjson"}}
+{"id": "NDJSON004", "wam": 0, "wac": 6.0, "face": 300000, "tags": {"suite": "ndjson"}}
+`
+	w := postNDJSON(router, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Count     int `json:"count"`
+		Succeeded int `json:"succeeded"`
+		Failed    int `json:"failed"`
+		Results   []struct {
+			LoanID  string                    `json:"loan_id"`
+			Summary amortization.TableSummary `json:"summary"`
+			Code    string                    `json:"code"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.Count != 4 || resp.Succeeded != 3 || resp.Failed != 1 {
+		t.Errorf("expected 4 loans with 3 succeeded and 1 failed, got %d/%d/%d", resp.Count, resp.Succeeded, resp.Failed)
+	}
+	for i, r := range resp.Results[:3] {
+		if r.LoanID != "NDJSON00"+string(rune('1'+i)) || r.Summary.Periods == 0 {
+			t.Errorf("result %d: expected summary for NDJSON00%d in submission order, got %+v", i, i+1, r)
+		}
+	}
+	if resp.Results[3].Code != codeValidationFailed {
+		t.Errorf("expected validation_failed for the invalid loan, got %q", resp.Results[3].Code)
+	}
+
+	// Valid loans are queued and stored
+	req := httptest.NewRequest(http.MethodGet, "/loans?tag=suite:ndjson", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var loans []amortization.LoanInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &loans); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if len(loans) != 3 {
+		t.Errorf("expected 3 stored loans, got %d", len(loans))
+	}
+}
+
+func TestRequestCashflow_NDJSONMalformedLine(t *testing.T) {
+	router := newTestRouter()
+	body := `{"id": "NDJSON005", "wam": 12, "wac": 4.0, "face": 1000}
+{"id": "NDJSON006", "wam": 12,`
+
+	w := postNDJSON(router, body)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "record 2") || !strings.Contains(w.Body.String(), codeInvalidJSON) {
+		t.Errorf("expected invalid_json error naming record 2, got %s", w.Body.String())
+	}
+	if _, ok := findLoan("NDJSON005"); ok {
+		t.Error("expected nothing stored after a malformed line")
+	}
+}
+
+func TestRequestCashflow_NDJSONCorrectsFractionalWac(t *testing.T) {
+	original := wacFractionThreshold
+	wacFractionThreshold = 1.0
+	t.Cleanup(func() { wacFractionThreshold = original })
+	buf := captureLoanLogger(t)
+
+	router := newTestRouter()
+	body := `{"id": "FRAC001", "wam": 360, "wac": 4.5, "face": 250000, "tags": {"suite": "fraction"}}
+{"id": "FRAC002", "wam": 360, "wac": 0.045, "face": 250000, "tags": {"suite": "fraction"}}
+`
+	if w := postNDJSON(router, body); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/loans?tag=suite:fraction", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var loans []amortization.LoanInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &loans); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if len(loans) != 2 {
+		t.Fatalf("expected 2 stored loans, got %d", len(loans))
+	}
+	for _, loan := range loans {
+		if loan.Wac != 4.5 {
+			t.Errorf("%s: expected wac 4.5, got %v", loan.ID, loan.Wac)
+		}
+	}
+
+	// Only the corrected record is logged
+	if n := strings.Count(buf.String(), "wac read as a fraction"); n != 1 {
+		t.Errorf("expected 1 correction logged, got %d: %s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), `"loan_id":"FRAC002"`) || !strings.Contains(buf.String(), `"wac_sent":0.045`) {
+		t.Errorf("expected the correction of FRAC002 to be logged, got %s", buf.String())
+	}
+}
+
+func TestWacFractionThresholdFromConfig(t *testing.T) {
+	if got, err := wacFractionThresholdFromConfig(map[string]interface{}{}); err != nil || got != 0 {
+		t.Errorf("expected the correction disabled when unset, got %v, %v", got, err)
+	}
+	if got, err := wacFractionThresholdFromConfig(map[string]interface{}{"WAC_FRACTION_THRESHOLD": 1.0}); err != nil || got != 1.0 {
+		t.Errorf("expected 1.0, got %v, %v", got, err)
+	}
+	for _, bad := range []interface{}{-1.0, "1.0"} {
+		if _, err := wacFractionThresholdFromConfig(map[string]interface{}{"WAC_FRACTION_THRESHOLD": bad}); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
+
+func TestRequestCashflow_NDJSONQueueFull(t *testing.T) {
+	useEmptyBook(t)
+	originalPool, originalDepth := workerPool, maxQueueDepth
+	workerPool = make(chan struct{}, 1)
+	maxQueueDepth = 1
+	t.Cleanup(func() { workerPool, maxQueueDepth = originalPool, originalDepth })
+
+	// Another request holds the whole queue
+	if !admitLoans(queueCapacity()) {
+		t.Fatal("expected to fill the queue")
+	}
+	w := postNDJSON(newTestRouter(), `{"id": "NDQ1", "wam": 12, "wac": 4.5, "face": 1000}`)
+	releaseLoans(queueCapacity())
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+	if loans := loanSnapshot(); len(loans) != 0 {
+		t.Errorf("expected nothing stored, got %d loans", len(loans))
+	}
+}
+
+func TestRequestCashflow_NDJSONAdmitsEachLoan(t *testing.T) {
+	useEmptyBook(t)
+	useResultCache(t, 0) // The stubbed calculation must run
+	originalPool, originalDepth := workerPool, maxQueueDepth
+	originalCalc, originalTimeout := calculateTable, loanTimeout
+	workerPool = make(chan struct{}, 1)
+	maxQueueDepth = 1
+	loanTimeout = 100 * time.Millisecond
+	hang := make(chan struct{})
+	t.Cleanup(func() {
+		close(hang) // Let the abandoned calculation finish
+		workerPool, maxQueueDepth = originalPool, originalDepth
+		calculateTable, loanTimeout = originalCalc, originalTimeout
+	})
+	// The first loan holds its worker while the second is read
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		<-hang
+		return l.GetAmortizationTable()
+	}
+
+	// Another request holds all but one place in the queue
+	if !admitLoans(queueCapacity() - 1) {
+		t.Fatal("expected to fill the queue")
+	}
+	t.Cleanup(func() { releaseLoans(queueCapacity() - 1) })
+
+	body := `{"id": "NDQ2", "wam": 12, "wac": 4.5, "face": 1000}
+{"id": "NDQ3", "wam": 12, "wac": 4.5, "face": 1000}`
+	w := postNDJSON(newTestRouter(), body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[1]["code"] != codeQueueFull {
+		t.Errorf("expected the second loan to find the queue full, got %s", w.Body.String())
+	}
+	if n := pendingLoans.Load(); n != int64(queueCapacity()-1) {
+		t.Errorf("expected the stream to release its loans, %d still pending", n)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+	"github.com/jiangshenghai57/andy-warhol/logger"
+)
+
+var (
+	// outputDir is where per-loan cashflow files are written; empty disables persistence
+	outputDir = ""
+
+	// writeAttempts and writeBackoff bound the retries around an output file write.
+	// The delay doubles after each failed attempt.
+	writeAttempts = 3
+	writeBackoff  = 100 * time.Millisecond
+
+	// writePool bounds concurrent output file writes. It is separate from
+	// the worker pool so slow disk IO never holds a calculation slot; sized
+	// from MAX_FILE_WRITES.
+	writePool = make(chan struct{}, defaultMaxFileWrites)
+
+	// createOutputFile opens the temporary file an output is written to; swappable in tests
+	createOutputFile = os.Create
+
+	// clock reports the current time for response timestamps, output file
+	// names and the log file; swappable in tests to pin "today"
+	clock = time.Now
+)
+
+// Timestamps in responses and saved files use timestampLayout, in the
+// configured TIMEZONE. File names use fileTimestampLayout instead: it has no
+// colons, sorts chronologically, and keeps millisecond precision.
+const (
+	timestampLayout     = time.RFC3339
+	fileTimestampLayout = "20060102_150405.000"
+)
+
+// localNow returns the current time in the configured TIMEZONE
+func localNow() time.Time {
+	return clock().In(location)
+}
+
+// formatTimestamp formats t for responses and saved files
+func formatTimestamp(t time.Time) string {
+	return t.In(location).Format(timestampLayout)
+}
+
+// outputSeq numbers output file names so files for the same loan and
+// assumptions written within the same millisecond do not overwrite each other
+var outputSeq atomic.Uint64
+
+// outputFileName returns the file name for a loan's cashflow output. The
+// assumptions digest keeps runs of the same loan under different inputs apart;
+// the millisecond timestamp and sequence number keep repeated runs apart.
+func outputFileName(loanID, assumptions string, now time.Time) string {
+	return fmt.Sprintf("cashflow_%s_%s_%s_%d.json", loanID, assumptions, now.Format(fileTimestampLayout), outputSeq.Add(1))
+}
+
+// assumptionsHash returns a short, stable digest of the inputs a loan is run
+// with (coupon, term, balance, prepayment, and so on)
+func assumptionsHash(l amortization.LoanInfo) string {
+	encoded, _ := json.Marshal(l)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:4])
+}
+
+// newRunID returns a random identifier for one calculation request
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// writeOutputFile persists payload as JSON under outputDir, retrying with
+// exponential backoff on failure. It returns the path of the written file.
+func writeOutputFile(reqLog *logger.Logger, loanID, assumptions string, payload interface{}) (string, error) {
+	path := filepath.Join(outputDir, outputFileName(loanID, assumptions, localNow()))
+
+	var err error
+	delay := writeBackoff
+	for attempt := 1; attempt <= writeAttempts; attempt++ {
+		if err = writeJSONAtomic(path, payload); err == nil {
+			return path, nil
+		}
+
+		reqLog.Warn("output file write failed",
+			slog.String("loan_id", loanID),
+			slog.Int("attempt", attempt),
+			slog.Any("error", err),
+		)
+		if attempt < writeAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	return "", fmt.Errorf("writing %s failed after %d attempts: %w", path, writeAttempts, err)
+}
+
+// encodeOutput writes payload as an output file body, indented unless
+// COMPACT_JSON is set
+func encodeOutput(w io.Writer, payload interface{}) error {
+	encoder := json.NewEncoder(w)
+	if !compactJSON {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(payload)
+}
+
+// writeJSONAtomic encodes payload into <path>.tmp, syncs it, and renames it
+// into place, so readers never observe a partially written .json file.
+func writeJSONAtomic(path string, payload interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	f, err := createOutputFile(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if err := encodeOutput(f, payload); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	// Flush to disk before the rename makes the file visible under its final name
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
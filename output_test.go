@@ -0,0 +1,398 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// useOutputDir points outputDir at a temp directory with fast retries
+func useOutputDir(t *testing.T) string {
+	dir := t.TempDir()
+	originalDir, originalBackoff, originalCreate := outputDir, writeBackoff, createOutputFile
+	outputDir, writeBackoff = dir, time.Millisecond
+	t.Cleanup(func() {
+		outputDir, writeBackoff, createOutputFile = originalDir, originalBackoff, originalCreate
+	})
+	return dir
+}
+
+func TestWriteOutputFile_RetriesAfterFailure(t *testing.T) {
+	dir := useOutputDir(t)
+
+	calls := 0
+	createOutputFile = func(name string) (*os.File, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("no space left on device")
+		}
+		return os.Create(name)
+	}
+
+	path, err := writeOutputFile(loanLogger, "RETRY001", "a1b2c3d4", map[string]string{"loan_id": "RETRY001"})
+	if err != nil {
+		t.Fatalf("expected write to succeed on retry, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(content, &payload); err != nil {
+		t.Fatalf("output file is not valid JSON: %v", err)
+	}
+	if payload["loan_id"] != "RETRY001" {
+		t.Errorf("expected loan_id RETRY001, got %q", payload["loan_id"])
+	}
+
+	tmpFiles, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(tmpFiles) != 0 {
+		t.Errorf("expected no temporary files left behind, got %v", tmpFiles)
+	}
+}
+
+func TestWriteOutputFile_GivesUpAfterMaxAttempts(t *testing.T) {
+	useOutputDir(t)
+
+	calls := 0
+	createOutputFile = func(name string) (*os.File, error) {
+		calls++
+		return nil, errors.New("disk unavailable")
+	}
+
+	if _, err := writeOutputFile(loanLogger, "RETRY002", "a1b2c3d4", map[string]string{}); err == nil {
+		t.Fatal("expected error after exhausting retries, got nil")
+	}
+	if calls != writeAttempts {
+		t.Errorf("expected %d attempts, got %d", writeAttempts, calls)
+	}
+}
+
+// slowPayload blocks its JSON encoding until released
+type slowPayload struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p slowPayload) MarshalJSON() ([]byte, error) {
+	close(p.started)
+	<-p.release
+	return []byte(`{"loan_id":"SLOW001","values":[1,2,3]}`), nil
+}
+
+func TestWriteJSONAtomic_NoPartialFileVisible(t *testing.T) {
+	dir := useOutputDir(t)
+	path := filepath.Join(dir, "cashflow_SLOW001.json")
+
+	payload := slowPayload{started: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan error)
+	go func() { done <- writeJSONAtomic(path, payload) }()
+
+	<-payload.started
+	// Observe the directory repeatedly while the write is in progress
+	for i := 0; i < 20; i++ {
+		if _, err := os.Stat(path); err == nil {
+			t.Fatal("final .json file visible before the write completed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(payload.release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("writeJSONAtomic failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	if !json.Valid(content) {
+		t.Errorf("output file is not valid JSON: %s", content)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary file left behind after rename")
+	}
+}
+
+func TestRequestCashflow_ReportsOutputFailure(t *testing.T) {
+	useOutputDir(t)
+	createOutputFile = func(name string) (*os.File, error) {
+		return nil, errors.New("disk unavailable")
+	}
+
+	router := newTestRouter()
+	w := postLoans(t, router, `[{"id": "RETRY003", "wam": 12, "wac": 5.0, "face": 10000}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"output_error"`) {
+		t.Errorf("expected output_error in response, got %s", w.Body.String())
+	}
+}
+
+func TestRequestCashflow_WritesOutputFile(t *testing.T) {
+	dir := useOutputDir(t)
+
+	router := newTestRouter()
+	w := postLoans(t, router, `[{"id": "OUTPUT001", "wam": 12, "wac": 5.0, "face": 10000}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "cashflow_OUTPUT001_*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected one output file, got %v", files)
+	}
+}
+
+func TestRequestCashflow_OutputFileNameReflectsAssumptions(t *testing.T) {
+	dir := useOutputDir(t)
+
+	router := newTestRouter()
+	for _, cpr := range []string{"0.05", "0.20"} {
+		w := postLoans(t, router, `[{"id": "OUTPUT002", "wam": 12, "wac": 5.0, "face": 10000, "prepay_cpr": `+cpr+`}]`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "cashflow_OUTPUT002_*.json"))
+	if len(files) != 2 {
+		t.Fatalf("expected two distinct output files, got %v", files)
+	}
+
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	var payload struct {
+		RunID string `json:"run_id"`
+	}
+	if err := json.Unmarshal(content, &payload); err != nil {
+		t.Fatalf("output file is not valid JSON: %v", err)
+	}
+	if payload.RunID == "" {
+		t.Error("expected run_id in output file")
+	}
+}
+
+func TestOutputFileName_UniqueWithinTheSameInstant(t *testing.T) {
+	now := time.Date(2024, 1, 15, 9, 30, 0, 123456789, time.UTC)
+
+	const n = 10000
+	names := make(map[string]bool, n)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range n / 8 {
+				name := outputFileName("BURST", "deadbeef", now)
+				mu.Lock()
+				names[name] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(names) != n {
+		t.Errorf("expected %d unique names, got %d", n, len(names))
+	}
+	for name := range names {
+		if !strings.HasPrefix(name, "cashflow_BURST_deadbeef_20240115_093000.123_") || !strings.HasSuffix(name, ".json") {
+			t.Fatalf("unexpected file name %q", name)
+		}
+		break
+	}
+}
+
+func TestRequestCashflow_SavedLocalDateIsRFC3339(t *testing.T) {
+	dir := useOutputDir(t)
+	originalLocation := location
+	location = time.FixedZone("UTC+9", 9*60*60)
+	t.Cleanup(func() { location = originalLocation })
+
+	router := newTestRouter()
+	w := postLoans(t, router, `[{"id": "STAMP001", "wam": 12, "wac": 5.0, "face": 10000}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "cashflow_STAMP001_*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected one output file, got %v", files)
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	var payload struct {
+		LocalDate string `json:"local_date"`
+	}
+	if err := json.Unmarshal(content, &payload); err != nil {
+		t.Fatalf("output file is not valid JSON: %v", err)
+	}
+
+	stamp, err := time.Parse(time.RFC3339, payload.LocalDate)
+	if err != nil {
+		t.Fatalf("local_date %q does not parse as RFC3339: %v", payload.LocalDate, err)
+	}
+	if _, offset := stamp.Zone(); offset != 9*60*60 {
+		t.Errorf("expected the configured +09:00 offset, got %q", payload.LocalDate)
+	}
+
+	// The file name carries the same instant in the filesystem-safe layout
+	name := filepath.Base(files[0])
+	fileStamp := strings.Join(strings.Split(name, "_")[3:5], "_")
+	parsed, err := time.ParseInLocation(fileTimestampLayout, fileStamp, location)
+	if err != nil {
+		t.Fatalf("file name %q does not carry a %s timestamp: %v", name, fileTimestampLayout, err)
+	}
+	if diff := parsed.Sub(stamp); diff < -time.Second || diff > time.Second {
+		t.Errorf("expected file name and local_date to agree, got %s and %s", parsed, stamp)
+	}
+}
+
+// useClock pins clock to now for the test
+func useClock(t *testing.T, now time.Time) {
+	original := clock
+	clock = func() time.Time { return now }
+	t.Cleanup(func() { clock = original })
+}
+
+func TestRequestCashflow_FixedClock(t *testing.T) {
+	dir := useOutputDir(t)
+	useClock(t, time.Date(2024, 3, 15, 10, 30, 0, 250_000_000, time.UTC))
+	originalLocation := location
+	location = time.UTC
+	t.Cleanup(func() { location = originalLocation })
+
+	router := newTestRouter()
+	w := postLoans(t, router, `[{"id": "CLOCK001", "wam": 12, "wac": 5.0, "face": 10000}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		LocalDate string `json:"local_date"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.LocalDate != "2024-03-15T10:30:00Z" {
+		t.Errorf("expected local_date 2024-03-15T10:30:00Z, got %s", resp.LocalDate)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "cashflow_CLOCK001_*_20240315_103000.250_*.json"))
+	if len(files) != 1 {
+		entries, _ := os.ReadDir(dir)
+		t.Fatalf("expected one output file stamped with the fixed clock, got %v", entries)
+	}
+}
+
+func TestMaxFileWritesFromConfig(t *testing.T) {
+	if got, err := maxFileWritesFromConfig(map[string]interface{}{}); err != nil || got != defaultMaxFileWrites {
+		t.Errorf("expected default %d, got %d (err %v)", defaultMaxFileWrites, got, err)
+	}
+	if got, err := maxFileWritesFromConfig(map[string]interface{}{"MAX_FILE_WRITES": float64(2)}); err != nil || got != 2 {
+		t.Errorf("expected 2, got %d (err %v)", got, err)
+	}
+	for _, raw := range []interface{}{float64(0), 1.5, "4"} {
+		if _, err := maxFileWritesFromConfig(map[string]interface{}{"MAX_FILE_WRITES": raw}); err == nil {
+			t.Errorf("expected error for %v", raw)
+		}
+	}
+}
+
+func TestRequestCashflow_SlowWritesDoNotHoldWorkers(t *testing.T) {
+	const numLoans = 4
+
+	useOutputDir(t)
+	useResultCache(t, 0) // The stubbed calculation must run for every loan
+
+	originalPool, originalWrites, originalCalc := workerPool, writePool, calculateTable
+	workerPool, writePool = make(chan struct{}, 1), make(chan struct{}, 1)
+	t.Cleanup(func() { workerPool, writePool, calculateTable = originalPool, originalWrites, originalCalc })
+
+	var calculated int32
+	allCalculated := make(chan struct{})
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		if atomic.AddInt32(&calculated, 1) == numLoans {
+			close(allCalculated)
+		}
+		return l.GetAmortizationTable()
+	}
+
+	// The disk stalls until every loan has been calculated. With one worker
+	// and one write slot, the batch only finishes if the stalled write holds
+	// neither the worker nor the calculations behind it.
+	createOutputFile = func(name string) (*os.File, error) {
+		select {
+		case <-allCalculated:
+		case <-time.After(2 * time.Second):
+			t.Error("calculations waited on a slow output write")
+		}
+		return os.Create(name)
+	}
+
+	loans := make([]string, numLoans)
+	for i := range loans {
+		loans[i] = fmt.Sprintf(`{"id": "SLOW%d", "wam": 12, "wac": 4.5, "face": 1000}`, i)
+	}
+	w := postLoans(t, newTestRouter(), "["+strings.Join(loans, ",")+"]")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	for _, result := range body.Results {
+		if result["output_file"] == nil {
+			t.Errorf("expected every loan to be written, got %v", result)
+		}
+	}
+}
+
+func TestRequestCashflow_RejectsPathTraversalID(t *testing.T) {
+	dir := useOutputDir(t)
+	outputDir = filepath.Join(dir, "out")
+
+	w := postLoans(t, newTestRouter(), `[{"id": "/../../escaped/pwn", "wam": 12, "wac": 4.5, "face": 1000}]`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), codeValidationFailed) {
+		t.Errorf("expected %s, got %s", codeValidationFailed, w.Body.String())
+	}
+
+	var written []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			written = append(written, path)
+		}
+		return nil
+	})
+	if len(written) != 0 {
+		t.Errorf("expected no files written, got %v", written)
+	}
+}
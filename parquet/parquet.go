@@ -0,0 +1,330 @@
+// Package parquet writes and reads flat Parquet files: a single row group of
+// required, uncompressed, PLAIN-encoded columns, which is all the service's
+// exports need. It is not a general-purpose Parquet implementation.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const magic = "PAR1"
+
+// Parquet physical types, encodings and enums used in the metadata
+const (
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	repetitionRequired = 0
+	convertedUTF8      = 0
+	codecUncompressed  = 0
+	pageTypeData       = 0
+)
+
+// Column is one named column of a file. Exactly one of the value slices is
+// set, which also selects the column's type.
+type Column struct {
+	Name    string
+	Strings []string  // UTF-8 strings (BYTE_ARRAY)
+	Int64s  []int64   // 64-bit integers (INT64)
+	Doubles []float64 // 64-bit floats (DOUBLE)
+}
+
+// Len returns the number of values in the column
+func (c Column) Len() int {
+	switch {
+	case c.Strings != nil:
+		return len(c.Strings)
+	case c.Int64s != nil:
+		return len(c.Int64s)
+	default:
+		return len(c.Doubles)
+	}
+}
+
+func (c Column) physicalType() int32 {
+	switch {
+	case c.Strings != nil:
+		return typeByteArray
+	case c.Int64s != nil:
+		return typeInt64
+	default:
+		return typeDouble
+	}
+}
+
+// plain encodes the column's values with the PLAIN encoding
+func (c Column) plain() []byte {
+	var buf []byte
+	switch {
+	case c.Strings != nil:
+		for _, s := range c.Strings {
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(len(s)))
+			buf = append(buf, s...)
+		}
+	case c.Int64s != nil:
+		for _, v := range c.Int64s {
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(v))
+		}
+	default:
+		for _, v := range c.Doubles {
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		}
+	}
+	return buf
+}
+
+// countingWriter tracks the file offset as it is written
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Write encodes the columns as a Parquet file with a single row group. Each
+// column is written as one data page as soon as it is encoded, so w may be a
+// stream. All columns must have the same number of values.
+func Write(w io.Writer, columns []Column) error {
+	if len(columns) == 0 {
+		return errors.New("parquet: no columns")
+	}
+	rows := columns[0].Len()
+	for _, c := range columns {
+		if c.Len() != rows {
+			return fmt.Errorf("parquet: column %q has %d values, want %d", c.Name, c.Len(), rows)
+		}
+	}
+
+	cw := &countingWriter{w: w}
+	if _, err := io.WriteString(cw, magic); err != nil {
+		return err
+	}
+
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(columns))
+	for i, c := range columns {
+		data := c.plain()
+
+		var header compactWriter
+		header.beginStruct()
+		header.i32(1, pageTypeData)
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.structField(5)
+		header.i32(1, int32(rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunks[i] = chunk{offset: cw.n, size: int64(len(header.buf) + len(data))}
+		if _, err := cw.Write(header.buf); err != nil {
+			return err
+		}
+		if _, err := cw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	var meta compactWriter
+	meta.beginStruct()
+	meta.i32(1, 1) // version
+	meta.listField(2, tStruct, len(columns)+1)
+	meta.beginStruct()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.endStruct()
+	for _, c := range columns {
+		meta.beginStruct()
+		meta.i32(1, c.physicalType())
+		meta.i32(3, repetitionRequired)
+		meta.binary(4, c.Name)
+		if c.Strings != nil {
+			meta.i32(6, convertedUTF8)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, int64(rows))
+
+	total := int64(0)
+	for _, ch := range chunks {
+		total += ch.size
+	}
+	meta.listField(4, tStruct, 1)
+	meta.beginStruct()
+	meta.listField(1, tStruct, len(columns))
+	for i, c := range columns {
+		meta.beginStruct()
+		meta.i64(2, chunks[i].offset)
+		meta.structField(3)
+		meta.i32(1, c.physicalType())
+		meta.listField(2, tI32, 2)
+		meta.i32Elem(encodingPlain)
+		meta.i32Elem(encodingRLE)
+		meta.listField(3, tBinary, 1)
+		meta.binaryElem(c.Name)
+		meta.i32(4, codecUncompressed)
+		meta.i64(5, int64(rows))
+		meta.i64(6, chunks[i].size)
+		meta.i64(7, chunks[i].size)
+		meta.i64(9, chunks[i].offset)
+		meta.endStruct()
+		meta.endStruct()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(rows))
+	meta.endStruct()
+	meta.binary(6, "andy-warhol")
+	meta.endStruct()
+
+	footer := binary.LittleEndian.AppendUint32(meta.buf, uint32(len(meta.buf)))
+	footer = append(footer, magic...)
+	_, err := cw.Write(footer)
+	return err
+}
+
+// Read decodes a file produced by Write, returning its columns in schema
+// order. Files using features Write does not produce (compression, optional
+// or nested columns, other encodings) are rejected.
+func Read(data []byte) ([]Column, error) {
+	if len(data) < 12 || string(data[:4]) != magic || string(data[len(data)-4:]) != magic {
+		return nil, errors.New("parquet: not a parquet file")
+	}
+	metaLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if metaLen > len(data)-12 {
+		return nil, errors.New("parquet: footer length out of range")
+	}
+	meta, err := (&compactReader{r: bytes.NewReader(data[len(data)-8-metaLen : len(data)-8])}).readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("parquet: reading footer: %w", err)
+	}
+
+	schema, _ := meta[2].([]any)
+	if len(schema) < 1 {
+		return nil, errors.New("parquet: missing schema")
+	}
+	columns := make([]Column, len(schema)-1)
+	types := make([]int64, len(columns))
+	for i, raw := range schema[1:] {
+		elem, _ := raw.(map[int16]any)
+		name, _ := elem[4].([]byte)
+		if rep, _ := elem[3].(int64); rep != repetitionRequired {
+			return nil, fmt.Errorf("parquet: column %q is not required", name)
+		}
+		columns[i].Name = string(name)
+		types[i], _ = elem[1].(int64)
+		switch types[i] {
+		case typeByteArray:
+			columns[i].Strings = []string{}
+		case typeInt64:
+			columns[i].Int64s = []int64{}
+		case typeDouble:
+			columns[i].Doubles = []float64{}
+		default:
+			return nil, fmt.Errorf("parquet: column %q has unsupported type %d", name, types[i])
+		}
+	}
+
+	rowGroups, _ := meta[4].([]any)
+	for _, raw := range rowGroups {
+		group, _ := raw.(map[int16]any)
+		chunks, _ := group[1].([]any)
+		if len(chunks) != len(columns) {
+			return nil, fmt.Errorf("parquet: row group has %d columns, schema has %d", len(chunks), len(columns))
+		}
+		for i, rawChunk := range chunks {
+			chunk, _ := rawChunk.(map[int16]any)
+			colMeta, _ := chunk[3].(map[int16]any)
+			if codec, _ := colMeta[4].(int64); codec != codecUncompressed {
+				return nil, fmt.Errorf("parquet: column %q is compressed", columns[i].Name)
+			}
+			numValues, _ := colMeta[5].(int64)
+			offset, _ := colMeta[9].(int64)
+			if err := readPages(data, offset, numValues, &columns[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return columns, nil
+}
+
+// readPages decodes numValues values of the column from the data pages
+// starting at offset
+func readPages(data []byte, offset, numValues int64, col *Column) error {
+	for int64(col.Len()) < numValues {
+		if offset < 0 || offset >= int64(len(data)) {
+			return fmt.Errorf("parquet: column %q page offset out of range", col.Name)
+		}
+		r := bytes.NewReader(data[offset:])
+		header, err := (&compactReader{r: r}).readStruct()
+		if err != nil {
+			return fmt.Errorf("parquet: column %q page header: %w", col.Name, err)
+		}
+		dataHeader, _ := header[5].(map[int16]any)
+		if typ, _ := header[1].(int64); typ != pageTypeData || dataHeader == nil {
+			return fmt.Errorf("parquet: column %q has an unsupported page type", col.Name)
+		}
+		if enc, _ := dataHeader[2].(int64); enc != encodingPlain {
+			return fmt.Errorf("parquet: column %q has unsupported encoding %d", col.Name, enc)
+		}
+		count, _ := dataHeader[1].(int64)
+		size, _ := header[3].(int64)
+
+		start := offset + int64(r.Size()) - int64(r.Len())
+		if size < 0 || start+size > int64(len(data)) {
+			return fmt.Errorf("parquet: column %q page overruns the file", col.Name)
+		}
+		if err := decodePlain(data[start:start+size], int(count), col); err != nil {
+			return err
+		}
+		offset = start + size
+	}
+	return nil
+}
+
+// decodePlain appends count PLAIN-encoded values to the column
+func decodePlain(page []byte, count int, col *Column) error {
+	for range count {
+		switch {
+		case col.Strings != nil:
+			if len(page) < 4 {
+				return fmt.Errorf("parquet: column %q page truncated", col.Name)
+			}
+			n := int(binary.LittleEndian.Uint32(page))
+			if len(page) < 4+n {
+				return fmt.Errorf("parquet: column %q page truncated", col.Name)
+			}
+			col.Strings = append(col.Strings, string(page[4:4+n]))
+			page = page[4+n:]
+		default:
+			if len(page) < 8 {
+				return fmt.Errorf("parquet: column %q page truncated", col.Name)
+			}
+			bits := binary.LittleEndian.Uint64(page)
+			if col.Int64s != nil {
+				col.Int64s = append(col.Int64s, int64(bits))
+			} else {
+				col.Doubles = append(col.Doubles, math.Float64frombits(bits))
+			}
+			page = page[8:]
+		}
+	}
+	return nil
+}
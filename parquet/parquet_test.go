@@ -0,0 +1,93 @@
+package parquet
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteRead_RoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Strings: []string{"LOAN001", "", "ünïcode"}},
+		{Name: "wam", Int64s: []int64{360, -1, math.MaxInt64}},
+		{Name: "wac", Doubles: []float64{6.5, 0, math.Inf(1)}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, columns); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("Expected PAR1 magic at both ends")
+	}
+
+	got, err := Read(data)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, columns) {
+		t.Errorf("Round trip mismatch:\n got %+v\nwant %+v", got, columns)
+	}
+}
+
+func TestWriteRead_ManyColumnsAndRows(t *testing.T) {
+	// More than 15 columns and list elements exercise the long list headers
+	const numColumns, numRows = 20, 1000
+	columns := make([]Column, numColumns)
+	for i := range columns {
+		values := make([]float64, numRows)
+		for j := range values {
+			values[j] = float64(i*numRows + j)
+		}
+		columns[i] = Column{Name: strings.Repeat("c", i+1), Doubles: values}
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, columns); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	got, err := Read(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, columns) {
+		t.Errorf("Round trip mismatch for %d columns of %d rows", numColumns, numRows)
+	}
+}
+
+func TestWriteRead_Empty(t *testing.T) {
+	columns := []Column{{Name: "id", Strings: []string{}}, {Name: "face", Doubles: []float64{}}}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, columns); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	got, err := Read(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Len() != 0 || got[1].Len() != 0 {
+		t.Errorf("Expected two empty columns, got %+v", got)
+	}
+}
+
+func TestWrite_MismatchedLengths(t *testing.T) {
+	err := Write(&bytes.Buffer{}, []Column{
+		{Name: "id", Strings: []string{"A", "B"}},
+		{Name: "face", Doubles: []float64{1}},
+	})
+	if err == nil || !strings.Contains(err.Error(), `column "face" has 1 values, want 2`) {
+		t.Errorf("Expected a length mismatch error, got %v", err)
+	}
+}
+
+func TestRead_RejectsGarbage(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("PAR1"), []byte("not a parquet file at all")} {
+		if _, err := Read(data); err == nil {
+			t.Errorf("Expected an error reading %q", data)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Thrift compact protocol type codes, as used in field headers and lists
+const (
+	tTrue   = 1
+	tFalse  = 2
+	tI32    = 5
+	tI64    = 6
+	tDouble = 7
+	tBinary = 8
+	tList   = 9
+	tStruct = 12
+)
+
+// compactWriter encodes the subset of the Thrift compact protocol needed for
+// Parquet metadata. Errors are sticky and reported by err.
+type compactWriter struct {
+	buf       []byte
+	lastField []int16 // Last field ID written, per open struct
+}
+
+func (w *compactWriter) varint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *compactWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *compactWriter) fieldHeader(id int16, typ byte) {
+	last := &w.lastField[len(w.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (w *compactWriter) beginStruct() {
+	w.lastField = append(w.lastField, 0)
+}
+
+func (w *compactWriter) endStruct() {
+	w.buf = append(w.buf, 0)
+	w.lastField = w.lastField[:len(w.lastField)-1]
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, tI32)
+	w.zigzag(int64(v))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, tI64)
+	w.zigzag(v)
+}
+
+func (w *compactWriter) binary(id int16, v string) {
+	w.fieldHeader(id, tBinary)
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// structField opens a nested struct field; close it with endStruct
+func (w *compactWriter) structField(id int16) {
+	w.fieldHeader(id, tStruct)
+	w.beginStruct()
+}
+
+// listField writes a list header for size elements of elemType; the caller
+// then writes the elements (structs via beginStruct/endStruct)
+func (w *compactWriter) listField(id int16, elemType byte, size int) {
+	w.fieldHeader(id, tList)
+	if size < 15 {
+		w.buf = append(w.buf, byte(size)<<4|elemType)
+	} else {
+		w.buf = append(w.buf, 0xF0|elemType)
+		w.varint(uint64(size))
+	}
+}
+
+func (w *compactWriter) i32Elem(v int32) {
+	w.zigzag(int64(v))
+}
+
+func (w *compactWriter) binaryElem(v string) {
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// compactReader decodes Thrift compact structs generically: each struct is a
+// map from field ID to its value, where integers are int64, binaries []byte,
+// doubles float64, bools bool, lists []any and structs map[int16]any.
+type compactReader struct {
+	r *bytes.Reader
+}
+
+func (r *compactReader) zigzag() (int64, error) {
+	u, err := binary.ReadUvarint(r.r)
+	return int64(u>>1) ^ -int64(u&1), err
+}
+
+func (r *compactReader) readStruct() (map[int16]any, error) {
+	fields := make(map[int16]any)
+	var last int16
+	for {
+		header, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+
+		typ := header & 0x0F
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+
+		switch typ {
+		case tTrue:
+			fields[id] = true
+		case tFalse:
+			fields[id] = false
+		default:
+			if fields[id], err = r.readValue(typ); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (r *compactReader) readValue(typ byte) (any, error) {
+	switch typ {
+	case tTrue, tFalse:
+		// Bools inside lists are encoded as a byte
+		b, err := r.r.ReadByte()
+		return b == tTrue, err
+	case 3, 4, tI32, tI64: // byte, i16, i32, i64
+		if typ == 3 {
+			b, err := r.r.ReadByte()
+			return int64(int8(b)), err
+		}
+		return r.zigzag()
+	case tDouble:
+		var b [8]byte
+		if _, err := io.ReadFull(r.r, b[:]); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+	case tBinary:
+		n, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r.r, b)
+		return b, err
+	case tList:
+		header, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = binary.ReadUvarint(r.r); err != nil {
+				return nil, err
+			}
+		}
+		elems := make([]any, size)
+		for i := range elems {
+			if elems[i], err = r.readValue(header & 0x0F); err != nil {
+				return nil, err
+			}
+		}
+		return elems, nil
+	case tStruct:
+		return r.readStruct()
+	default:
+		return nil, fmt.Errorf("unsupported thrift type %d", typ)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// paymentRequest describes a loan amortized by a known fixed payment rather
+// than by its term
+type paymentRequest struct {
+	Face    float64 `json:"face"`    // Principal amount
+	Wac     float64 `json:"wac"`     // Coupon per annum in percentage points
+	Payment float64 `json:"payment"` // Fixed monthly payment
+}
+
+// amortizeFromPayment serves POST /loans/from-payment, returning the schedule
+// and payoff month implied by a fixed payment. The schedule is built on a
+// worker slot once the request is admitted to the queue, as stored loans
+// are. With format=md the schedule is returned as a Markdown table of the
+// first and last max_rows periods.
+func amortizeFromPayment(c *gin.Context) {
+	markdown, maxRows, ok := markdownRequested(c)
+	if !ok {
+		return
+	}
+
+	var req paymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	var amortTable amortization.AmortizationTable
+	var err error
+	if !runAdmitted(c, func() {
+		amortTable, err = amortization.GetAmortizationFromPayment(req.Face, req.Wac, req.Payment)
+	}) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeValidationFailed, err.Error())
+		return
+	}
+
+	if markdown {
+		respondMarkdownTable(c, &amortTable, maxRows)
+		return
+	}
+
+	result := gin.H{"payoff_period": len(amortTable.Period)}
+	if c.Query("summary") == "true" {
+		result["summary"] = amortTable.Summary()
+	} else {
+		result["cashflow"] = amortTable
+	}
+	respondJSON(c, http.StatusOK, result)
+}
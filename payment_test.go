@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postPayment(router http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/loans/from-payment", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAmortizeFromPayment_ReturnsPayoffPeriod(t *testing.T) {
+	router := newTestRouter()
+	w := postPayment(router, `{"face": 250000, "wac": 6.0, "payment": 2000}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		PayoffPeriod int `json:"payoff_period"`
+		Cashflow     struct {
+			EndBal []float64 `json:"end_bal"`
+		} `json:"cashflow"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.PayoffPeriod <= 0 || resp.PayoffPeriod >= 360 {
+		t.Errorf("expected payoff before 360 months, got %d", resp.PayoffPeriod)
+	}
+	if len(resp.Cashflow.EndBal) != resp.PayoffPeriod {
+		t.Errorf("expected %d periods in cashflow, got %d", resp.PayoffPeriod, len(resp.Cashflow.EndBal))
+	}
+}
+
+func TestAmortizeFromPayment_RejectsPaymentBelowInterest(t *testing.T) {
+	router := newTestRouter()
+	w := postPayment(router, `{"face": 250000, "wac": 6.0, "payment": 1000}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "does not cover first-period interest") {
+		t.Errorf("expected interest coverage error, got %s", w.Body.String())
+	}
+}
+
+func TestAmortizeFromPayment_Markdown(t *testing.T) {
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodPost, "/loans/from-payment?format=md&max_rows=5", strings.NewReader(`{"face": 200000, "wac": 6.0, "payment": 1199.10}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+		t.Errorf("expected a Markdown content type, got %q", ct)
+	}
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if lines[0] != "| Period | Beg Bal | Interest | Principal | Prepay | End Bal |" {
+		t.Errorf("unexpected header row %q", lines[0])
+	}
+	if len(lines) != 13 || !strings.Contains(lines[7], "periods omitted") {
+		t.Errorf("expected five rows either side of an elision, got:\n%s", w.Body.String())
+	}
+}
+
+func TestAmortizeFromPayment_RejectsBadFormat(t *testing.T) {
+	router := newTestRouter()
+	for _, query := range []string{"format=csv", "format=md&max_rows=-1", "format=md&max_rows=x"} {
+		req := httptest.NewRequest(http.MethodPost, "/loans/from-payment?"+query, strings.NewReader(`{"face": 200000, "wac": 6.0, "payment": 1500}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
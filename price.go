@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// priceRequest selects how a stored loan's cashflows are discounted. Exactly
+// one of Yield and ZeroCurve must be given.
+type priceRequest struct {
+	Yield      *float64  `json:"yield,omitempty"`      // Flat annual yield in percentage points
+	ZeroCurve  []float64 `json:"zero_curve,omitempty"` // Annual zero rates in percentage points, one per period
+	SettleDate time.Time `json:"settle_date,omitzero"` // When set, accrued interest is reported
+}
+
+// priceLoan serves POST /loans/:id/price, valuing the most recent stored loan
+// with the given ID at a flat yield or along a zero curve
+func priceLoan(c *gin.Context) {
+	var req priceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if (req.Yield == nil) == (req.ZeroCurve == nil) {
+		respondError(c, http.StatusBadRequest, codeValidationFailed, "exactly one of yield and zero_curve is required")
+		return
+	}
+
+	loan, ok := findLoan(c.Param("id"))
+	if !ok {
+		respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("loan %s not found", c.Param("id")))
+		return
+	}
+
+	amortTable, ok := calculateAdmitted(c, &loan)
+	if !ok {
+		return
+	}
+
+	var discountFactors []float64
+	if req.Yield != nil {
+		discountFactors = amortTable.YieldDiscountFactors(*req.Yield)
+	} else {
+		var err error
+		if discountFactors, err = amortTable.CurveDiscountFactors(req.ZeroCurve); err != nil {
+			respondError(c, http.StatusBadRequest, codeValidationFailed, err.Error())
+			return
+		}
+	}
+
+	result := gin.H{
+		"loan_id": loan.ID,
+		"pricing": amortTable.Price(discountFactors),
+	}
+	if !req.SettleDate.IsZero() {
+		accrued, err := loan.AccruedInterest(amortTable, req.SettleDate)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeValidationFailed, err.Error())
+			return
+		}
+		result["accrued_interest"] = accrued
+	}
+	respondJSON(c, http.StatusOK, result)
+}
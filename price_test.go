@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+func postPrice(router http.Handler, id, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/loans/"+id+"/price", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPriceLoan_YieldAndCurve(t *testing.T) {
+	router := newTestRouter()
+	body := `[{"id": "PRICE001", "wam": 120, "wac": 5.0, "face": 100000, "prepay_cpr": 0.05, "origination_date": "2024-01-01T00:00:00Z"}]`
+	if w := postLoans(t, router, body); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	loan := amortization.LoanInfo{ID: "PRICE001", Wam: 120, Wac: 5.0, Face: 100000, PrepayInfo: amortization.PrepayInfo{PrepayCPR: 0.05}}
+	table := loan.GetAmortizationTable()
+	expectedYield := table.Price(table.YieldDiscountFactors(6.0))
+	curveFactors, _ := table.CurveDiscountFactors([]float64{4.0, 4.5, 5.0})
+	expectedCurve := table.Price(curveFactors)
+
+	var resp struct {
+		Pricing         amortization.Pricing `json:"pricing"`
+		AccruedInterest *float64             `json:"accrued_interest"`
+	}
+
+	w := postPrice(router, "PRICE001", `{"yield": 6.0, "settle_date": "2024-01-16T00:00:00Z"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if math.Abs(resp.Pricing.PV-expectedYield.PV) > 1e-6 || math.Abs(resp.Pricing.Duration-expectedYield.Duration) > 1e-9 {
+		t.Errorf("expected yield pricing %+v, got %+v", expectedYield, resp.Pricing)
+	}
+	// 15 days of 30/360 accrual on the opening balance
+	if resp.AccruedInterest == nil || *resp.AccruedInterest != 208.33 {
+		t.Errorf("expected accrued interest 208.33, got %v", resp.AccruedInterest)
+	}
+
+	resp.AccruedInterest = nil
+	w = postPrice(router, "PRICE001", `{"zero_curve": [4.0, 4.5, 5.0]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if math.Abs(resp.Pricing.PV-expectedCurve.PV) > 1e-6 {
+		t.Errorf("expected curve PV %.6f, got %.6f", expectedCurve.PV, resp.Pricing.PV)
+	}
+	if resp.AccruedInterest != nil {
+		t.Errorf("expected no accrued interest without a settle date, got %v", *resp.AccruedInterest)
+	}
+}
+
+func TestPriceLoan_Errors(t *testing.T) {
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "PRICE002", "wam": 12, "wac": 5.0, "face": 1000}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	testCases := []struct {
+		name   string
+		id     string
+		body   string
+		status int
+	}{
+		{name: "both inputs", id: "PRICE002", body: `{"yield": 5.0, "zero_curve": [5.0]}`, status: http.StatusBadRequest},
+		{name: "neither input", id: "PRICE002", body: `{}`, status: http.StatusBadRequest},
+		{name: "undated settle", id: "PRICE002", body: `{"yield": 5.0, "settle_date": "2024-01-16T00:00:00Z"}`, status: http.StatusBadRequest},
+		{name: "unknown loan", id: "PRICE404", body: `{"yield": 5.0}`, status: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := postPrice(router, tc.id, tc.body); w.Code != tc.status {
+				t.Errorf("expected status %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPriceLoan_Timeout(t *testing.T) {
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "PRICE003", "wam": 360, "wac": 5.0, "face": 100000}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	useResultCache(t, 0) // The stubbed calculation must run
+	originalCalc, originalTimeout := calculateTable, loanTimeout
+	loanTimeout = 20 * time.Millisecond
+	hang := make(chan struct{})
+	t.Cleanup(func() {
+		close(hang) // Let the abandoned calculation finish
+		calculateTable, loanTimeout = originalCalc, originalTimeout
+	})
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		<-hang
+		return l.GetAmortizationTable()
+	}
+
+	w := postPrice(router, "PRICE003", `{"yield": 5.0}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), codeTimeout) {
+		t.Errorf("expected code %s, got %s", codeTimeout, w.Body.String())
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMaxQueueDepth is the number of loans that may wait for a worker
+// when MAX_QUEUE_DEPTH is not configured
+const defaultMaxQueueDepth = 1000
+
+// queueRetryAfterSeconds is the Retry-After sent with a 429 when the queue is full
+const queueRetryAfterSeconds = 1
+
+var (
+	// maxQueueDepth bounds the loans waiting for a worker beyond those
+	// running; set from MAX_QUEUE_DEPTH
+	maxQueueDepth = defaultMaxQueueDepth
+
+	// pendingLoans counts the admitted loans that are running or waiting
+	pendingLoans atomic.Int64
+)
+
+// queueCapacity is the most loans that may be running or waiting at once
+func queueCapacity() int {
+	return cap(workerPool) + maxQueueDepth
+}
+
+// admitLoans reserves room for n loans in the worker pool and its queue,
+// reporting false when they would overfill it. A batch larger than the whole
+// queue is never admitted. Admitted loans must be released with releaseLoans.
+func admitLoans(n int) bool {
+	limit := int64(queueCapacity())
+	for {
+		pending := pendingLoans.Load()
+		if pending+int64(n) > limit {
+			return false
+		}
+		if pendingLoans.CompareAndSwap(pending, pending+int64(n)) {
+			return true
+		}
+	}
+}
+
+// releaseLoans returns the room reserved by admitLoans
+func releaseLoans(n int) {
+	pendingLoans.Add(-int64(n))
+}
+
+// admitBatch reserves room for n loans, answering 429 with a Retry-After
+// header when the queue is full, or 413 when the batch could never fit
+func admitBatch(c *gin.Context, n int) bool {
+	if n > queueCapacity() {
+		respondError(c, http.StatusRequestEntityTooLarge, codeBatchTooLarge,
+			fmt.Sprintf("batch of %d loans exceeds the queue capacity of %d (%d running, %d queued at most)",
+				n, queueCapacity(), cap(workerPool), maxQueueDepth))
+		return false
+	}
+	if admitLoans(n) {
+		return true
+	}
+	c.Header("Retry-After", strconv.Itoa(queueRetryAfterSeconds))
+	respondError(c, http.StatusTooManyRequests, codeQueueFull,
+		fmt.Sprintf("worker queue is full (%d running, %d queued at most); retry later", cap(workerPool), maxQueueDepth))
+	return false
+}
+
+// maxQueueDepthFromConfig reads MAX_QUEUE_DEPTH from the config, falling back
+// to defaultMaxQueueDepth when unset. The value must be a non-negative integer.
+func maxQueueDepthFromConfig(config map[string]interface{}) (int, error) {
+	raw, ok := config["MAX_QUEUE_DEPTH"]
+	if !ok {
+		return defaultMaxQueueDepth, nil
+	}
+
+	value, ok := raw.(float64)
+	if !ok || value != math.Trunc(value) || value < 0 {
+		return 0, fmt.Errorf("MAX_QUEUE_DEPTH must be a non-negative integer, got %v", raw)
+	}
+
+	return int(value), nil
+}
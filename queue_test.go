@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+func TestRequestCashflow_QueueFullReturns429(t *testing.T) {
+	useResultCache(t, 0) // The stubbed calculation must run for every loan
+
+	originalPool, originalDepth, originalCalc := workerPool, maxQueueDepth, calculateTable
+	workerPool = make(chan struct{}, 1)
+	maxQueueDepth = 1
+	release := make(chan struct{})
+	t.Cleanup(func() { workerPool, maxQueueDepth, calculateTable = originalPool, originalDepth, originalCalc })
+
+	var running int32
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		atomic.AddInt32(&running, 1)
+		<-release // Hold the worker until the test lets go
+		return l.GetAmortizationTable()
+	}
+
+	router := newTestRouter()
+	loan := `{"id": "QUEUED", "wam": 12, "wac": 4.5, "face": 1000}`
+
+	// One loan running and one queued fill the pool and its queue
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postLoans(t, router, "["+loan+","+loan+"]") }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&running) < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the pool to fill")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	w := postLoans(t, router, "["+loan+"]")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got %q", w.Header().Get("Retry-After"))
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if body["code"] != codeQueueFull {
+		t.Errorf("expected code %q, got %v", codeQueueFull, body["code"])
+	}
+
+	close(release)
+	if first := <-done; first.Code != http.StatusOK {
+		t.Fatalf("expected the admitted batch to succeed, got %d", first.Code)
+	}
+
+	// Once the batch finishes its room is released
+	if n := pendingLoans.Load(); n != 0 {
+		t.Errorf("expected no pending loans after the batch, got %d", n)
+	}
+	if w := postLoans(t, router, "["+loan+"]"); w.Code != http.StatusOK {
+		t.Errorf("expected a submit after the queue drained to succeed, got %d", w.Code)
+	}
+}
+
+func TestAdmitLoans(t *testing.T) {
+	originalPool, originalDepth := workerPool, maxQueueDepth
+	workerPool = make(chan struct{}, 2)
+	maxQueueDepth = 3
+	t.Cleanup(func() { workerPool, maxQueueDepth = originalPool, originalDepth })
+
+	if !admitLoans(4) {
+		t.Fatal("expected 4 loans to fit a pool of 2 with a queue of 3")
+	}
+	if admitLoans(2) {
+		t.Error("expected 2 more loans to overfill the queue")
+	}
+	if !admitLoans(1) {
+		t.Error("expected 1 more loan to fill the queue exactly")
+	}
+	releaseLoans(5)
+
+	// A batch larger than the whole queue is rejected even when it is empty
+	if admitLoans(6) {
+		t.Error("expected an oversized batch to be rejected")
+	}
+	if n := pendingLoans.Load(); n != 0 {
+		t.Errorf("expected a rejected batch to reserve nothing, got %d pending", n)
+	}
+}
+
+func TestRequestCashflow_OversizedBatchReturns413(t *testing.T) {
+	originalPool, originalDepth := workerPool, maxQueueDepth
+	workerPool = make(chan struct{}, 1)
+	maxQueueDepth = 1
+	t.Cleanup(func() { workerPool, maxQueueDepth = originalPool, originalDepth })
+
+	loan := `{"id": "BIG", "wam": 12, "wac": 4.5, "face": 1000}`
+	w := postLoans(t, newTestRouter(), "["+loan+","+loan+","+loan+"]")
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") != "" {
+		t.Errorf("expected no Retry-After for a batch that can never fit, got %q", w.Header().Get("Retry-After"))
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if body["code"] != codeBatchTooLarge {
+		t.Errorf("expected code %q, got %v", codeBatchTooLarge, body["code"])
+	}
+}
+
+func TestBatchEndpoints_QueueFullReturns429(t *testing.T) {
+	mu.Lock()
+	original := mortgages
+	mortgages = []amortization.LoanInfo{{ID: "QF1", Wam: 12, Wac: 4.5, Face: 1000}}
+	mu.Unlock()
+	originalPool, originalDepth := workerPool, maxQueueDepth
+	workerPool = make(chan struct{}, 1)
+	maxQueueDepth = 1
+	t.Cleanup(func() {
+		mu.Lock()
+		mortgages = original
+		mu.Unlock()
+		workerPool, maxQueueDepth = originalPool, originalDepth
+	})
+
+	// Another request holds the whole queue
+	if !admitLoans(queueCapacity()) {
+		t.Fatal("expected to fill the queue")
+	}
+	t.Cleanup(func() { releaseLoans(queueCapacity()) })
+
+	router := newTestRouter()
+	loan := `{"id": "QF2", "wam": 12, "wac": 4.5, "face": 1000}`
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodPost, "/loans/recalculate", "{}"},
+		{http.MethodGet, "/loans/export.parquet", ""},
+		{http.MethodPost, "/loans/compare", `{"base": ` + loan + `, "compare": ` + loan + `}`},
+		{http.MethodPost, "/loans/from-payment", `{"face": 1000, "wac": 4.5, "payment": 100}`},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("%s %s: expected status 429, got %d: %s", tc.method, tc.path, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s %s: expected a Retry-After header", tc.method, tc.path)
+		}
+	}
+}
+
+func TestMaxQueueDepthFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    int
+		wantErr bool
+	}{
+		{name: "unset uses default", config: map[string]interface{}{}, want: defaultMaxQueueDepth},
+		{name: "configured", config: map[string]interface{}{"MAX_QUEUE_DEPTH": float64(50)}, want: 50},
+		{name: "zero queues nothing", config: map[string]interface{}{"MAX_QUEUE_DEPTH": float64(0)}, want: 0},
+		{name: "negative", config: map[string]interface{}{"MAX_QUEUE_DEPTH": float64(-1)}, wantErr: true},
+		{name: "fractional", config: map[string]interface{}{"MAX_QUEUE_DEPTH": 2.5}, wantErr: true},
+		{name: "not a number", config: map[string]interface{}{"MAX_QUEUE_DEPTH": "50"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := maxQueueDepthFromConfig(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// assumptionOverrides are global assumptions applied to every stored loan
+// when the book is recalculated, e.g. for stress testing.
+type assumptionOverrides struct {
+	PrepayCPR *float64 `json:"prepay_cpr,omitempty"` // Replaces each loan's CPR when set
+	CPRShock  float64  `json:"cpr_shock"`            // Relative CPR shock (e.g., 0.10 for +10%)
+	WacShift  float64  `json:"wac_shift"`            // Coupon shift in percentage points
+}
+
+// apply returns a copy of the loan with the overrides applied
+func (o assumptionOverrides) apply(l amortization.LoanInfo) amortization.LoanInfo {
+	if o.PrepayCPR != nil {
+		l.PrepayCPR = *o.PrepayCPR
+	}
+	l.PrepayCPR *= 1 + o.CPRShock
+
+	if l.WacIsDecimal {
+		l.Wac += o.WacShift / 100.0
+	} else {
+		l.Wac += o.WacShift
+	}
+
+	l.SMMArr = nil // Re-derived from the shocked CPR
+	return l
+}
+
+func recalculateLoans(c *gin.Context) {
+	var overrides assumptionOverrides
+	if err := c.ShouldBindJSON(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+
+	summaryOnly := c.Query("summary") == "true"
+	fields, err := fieldsRequested(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return
+	}
+	if fields != nil && summaryOnly {
+		respondError(c, http.StatusBadRequest, codeInvalidQuery, "fields requires the columns layout without summary")
+		return
+	}
+
+	// Snapshot the book so the lock isn't held while calculating
+	mu.RLock()
+	loans := make([]amortization.LoanInfo, len(mortgages))
+	copy(loans, mortgages)
+	mu.RUnlock()
+
+	if !admitBatch(c, len(loans)) {
+		return
+	}
+	defer releaseLoans(len(loans))
+
+	reqLog := requestLogger(c)
+	results := make([]gin.H, len(loans))
+	failed := make([]bool, len(loans))
+	calculateBatch(loans, func(index int, l amortization.LoanInfo) {
+		shocked := overrides.apply(l)
+		if err := shocked.Validate(); err != nil {
+			results[index] = gin.H{"loan_id": l.ID, "code": codeValidationFailed, "error": err.Error()}
+			failed[index] = true
+			return
+		}
+
+		amortTable, err := calculateTableWithin(reqLog, &shocked)
+		if err != nil {
+			results[index] = failedResult(l.ID, err)
+			failed[index] = true
+			return
+		}
+		result := gin.H{"loan_id": l.ID, "prepay_cpr": shocked.PrepayCPR}
+		switch {
+		case summaryOnly:
+			result["summary"] = amortTable.Summary()
+		case fields != nil:
+			result["cashflow"], _ = amortTable.Project(fields)
+		default:
+			result["cashflow"] = amortTable
+		}
+		results[index] = result
+	}, nil)
+
+	failures := 0
+	for _, f := range failed {
+		if f {
+			failures++
+		}
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"count":     len(loans),
+		"succeeded": len(loans) - failures,
+		"failed":    failures,
+		"results":   results,
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+func TestRecalculateLoans_AppliesCPRShock(t *testing.T) {
+	router := newTestRouter()
+	body := `[
+		{"id": "RECALC001", "wam": 120, "wac": 4.0, "face": 100000, "prepay_cpr": 0.05},
+		{"id": "RECALC002", "wam": 240, "wac": 5.0, "face": 200000, "prepay_cpr": 0.10},
+		{"id": "RECALC003", "wam": 360, "wac": 6.0, "face": 300000, "prepay_cpr": 0.20}
+	]`
+	if w := postLoans(t, router, body); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/loans/recalculate", strings.NewReader(`{"cpr_shock": 0.10}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Count   int `json:"count"`
+		Failed  int `json:"failed"`
+		Results []struct {
+			LoanID    string                         `json:"loan_id"`
+			PrepayCPR float64                        `json:"prepay_cpr"`
+			Cashflow  amortization.AmortizationTable `json:"cashflow"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.Failed != 0 {
+		t.Errorf("expected no failures, got %d", resp.Failed)
+	}
+
+	original := map[string]amortization.LoanInfo{
+		"RECALC001": {ID: "RECALC001", Wam: 120, Wac: 4.0, Face: 100000},
+		"RECALC002": {ID: "RECALC002", Wam: 240, Wac: 5.0, Face: 200000},
+		"RECALC003": {ID: "RECALC003", Wam: 360, Wac: 6.0, Face: 300000},
+	}
+	baseCPR := map[string]float64{"RECALC001": 0.05, "RECALC002": 0.10, "RECALC003": 0.20}
+
+	seen := 0
+	for _, result := range resp.Results {
+		loan, ok := original[result.LoanID]
+		if !ok {
+			continue
+		}
+		seen++
+
+		shockedCPR := baseCPR[result.LoanID] * 1.10
+		if math.Abs(result.PrepayCPR-shockedCPR) > 1e-12 {
+			t.Errorf("%s: expected shocked CPR %.4f, got %.4f", result.LoanID, shockedCPR, result.PrepayCPR)
+		}
+
+		loan.PrepayCPR = shockedCPR
+		expected := loan.GetAmortizationTable()
+		for i := range expected.EndBal {
+			if result.Cashflow.EndBal[i] != expected.EndBal[i] {
+				t.Errorf("%s period %d: expected end balance %.2f under shocked CPR, got %.2f",
+					result.LoanID, i+1, expected.EndBal[i], result.Cashflow.EndBal[i])
+				break
+			}
+		}
+	}
+	if seen != len(original) {
+		t.Errorf("expected %d recalculated loans, found %d", len(original), seen)
+	}
+}
+
+func TestRecalculateLoans_ReportsFailures(t *testing.T) {
+	router := newTestRouter()
+	if w := postLoans(t, router, `[{"id": "RECALC004", "wam": 120, "wac": 4.0, "face": 100000, "prepay_cpr": 0.5}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Doubling a 50% CPR pushes it out of the valid range
+	req := httptest.NewRequest(http.MethodPost, "/loans/recalculate?summary=true", strings.NewReader(`{"cpr_shock": 1.0}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp struct {
+		Failed  int `json:"failed"`
+		Results []struct {
+			LoanID string `json:"loan_id"`
+			Error  string `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.Failed == 0 {
+		t.Fatal("expected at least one failure")
+	}
+	for _, result := range resp.Results {
+		if result.LoanID == "RECALC004" && !strings.Contains(result.Error, "CPR") {
+			t.Errorf("expected CPR validation error for RECALC004, got %q", result.Error)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// replayLoans serves POST /loans/replay. Every stored loan is recalculated
+// with the current engine and its cashflow rewritten under OUTPUT_PATH in the
+// current envelope, so a lost output directory or a changed file format can
+// be repaired without clients resubmitting. Existing files are left in place;
+// the replayed files are new runs alongside them. The response counts the
+// files written and lists each loan that failed to calculate or write.
+func replayLoans(c *gin.Context) {
+	if outputDir == "" {
+		respondError(c, http.StatusNotFound, codeNotFound, "output files are not enabled")
+		return
+	}
+
+	loans := loanSnapshot()
+	if !admitBatch(c, len(loans)) {
+		return
+	}
+	defer releaseLoans(len(loans))
+
+	reqLog := requestLogger(c)
+	runID := newRunID()
+	results := make([]gin.H, len(loans))
+	var writes sync.WaitGroup
+	calculateBatch(loans, func(index int, l amortization.LoanInfo) {
+		assumptions := assumptionsHash(l)
+		amortTable, err := calculateTableWithin(reqLog, &l)
+		if err != nil {
+			results[index] = failedResult(l.ID, err)
+			return
+		}
+
+		result := gin.H{"loan_id": l.ID}
+		results[index] = result
+		// As in POST /loans, write after the worker is released
+		writes.Add(1)
+		go func() {
+			defer writes.Done()
+			persistCashflow(reqLog, result, runID, l.ID, assumptions, amortTable)
+		}()
+	}, nil)
+	writes.Wait()
+
+	failures := []gin.H{}
+	for _, result := range results {
+		if _, ok := result["output_file"]; !ok {
+			failures = append(failures, result)
+		}
+	}
+
+	reqLog.Info("stored loans replayed",
+		slog.String("run_id", runID),
+		slog.Int("count", len(loans)),
+		slog.Int("failed", len(failures)),
+	)
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"run_id":   runID,
+		"count":    len(loans),
+		"written":  len(loans) - len(failures),
+		"failed":   len(failures),
+		"failures": failures,
+	})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+// useEmptyBook clears the stored loans for the duration of the test
+func useEmptyBook(t *testing.T) {
+	mu.Lock()
+	original := mortgages
+	mortgages = []amortization.LoanInfo{}
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		mortgages = original
+		mu.Unlock()
+	})
+}
+
+func postReplay(t *testing.T, router *gin.Engine) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/loans/replay", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestReplayLoans_RewritesOutputFiles(t *testing.T) {
+	useEmptyBook(t)
+	dir := useOutputDir(t)
+	router := newTestRouter()
+
+	body := `[
+		{"id": "REPLAY1", "wam": 360, "wac": 4.5, "face": 250000},
+		{"id": "REPLAY2", "wam": 120, "wac": 6.0, "face": 50000, "prepay_cpr": 0.1}
+	]`
+	if w := postLoans(t, router, body); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 storing loans, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Lose the output directory
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	w := postReplay(t, router)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Count    int              `json:"count"`
+		Written  int              `json:"written"`
+		Failed   int              `json:"failed"`
+		Failures []map[string]any `json:"failures"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.Count != 2 || resp.Written != 2 || resp.Failed != 0 || len(resp.Failures) != 0 {
+		t.Errorf("expected 2 of 2 written with no failures, got %+v", resp)
+	}
+
+	for _, id := range []string{"REPLAY1", "REPLAY2"} {
+		files, _ := filepath.Glob(filepath.Join(dir, "cashflow_"+id+"_*.json"))
+		if len(files) != 1 {
+			t.Fatalf("expected 1 replayed file for %s, got %v", id, files)
+		}
+		content, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var envelope map[string]json.RawMessage
+		if err := json.Unmarshal(content, &envelope); err != nil {
+			t.Fatalf("%s: output file is not valid JSON: %v", id, err)
+		}
+		for _, key := range []string{"run_id", "loan_id", "local_date", "cashflow"} {
+			if _, ok := envelope[key]; !ok {
+				t.Errorf("%s: expected %q in the output envelope", id, key)
+			}
+		}
+	}
+}
+
+func TestReplayLoans_ReportsWriteFailures(t *testing.T) {
+	useEmptyBook(t)
+	useOutputDir(t)
+	router := newTestRouter()
+
+	if w := postLoans(t, router, `[{"id": "REPLAY3", "wam": 12, "wac": 4.5, "face": 1000}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 storing loans, got %d: %s", w.Code, w.Body.String())
+	}
+	createOutputFile = func(name string) (*os.File, error) {
+		return nil, os.ErrPermission
+	}
+
+	w := postReplay(t, router)
+	var resp struct {
+		Written  int              `json:"written"`
+		Failures []map[string]any `json:"failures"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+	if resp.Written != 0 || len(resp.Failures) != 1 {
+		t.Fatalf("expected 1 failure and nothing written, got %s", w.Body.String())
+	}
+	if resp.Failures[0]["loan_id"] != "REPLAY3" || resp.Failures[0]["output_error"] == nil {
+		t.Errorf("expected the write failure for REPLAY3, got %v", resp.Failures[0])
+	}
+}
+
+func TestReplayLoans_OutputDisabled(t *testing.T) {
+	originalDir := outputDir
+	outputDir = ""
+	t.Cleanup(func() { outputDir = originalDir })
+
+	if w := postReplay(t, newTestRouter()); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/logger"
+)
+
+const (
+	// requestIDHeader carries the request ID in and out of the service
+	requestIDHeader = "X-Request-ID"
+	// requestIDKey is the gin context key and log field holding the request ID
+	requestIDKey = "request_id"
+	// maxRequestIDLength bounds a client-supplied ID; longer ones are replaced
+	maxRequestIDLength = 128
+)
+
+// requestID tags each request with the caller's X-Request-ID, or a generated
+// UUID when none (or an oversized one) is sent, and echoes it in the response
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newUUID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// requestLogger returns loanLogger annotated with the request ID, for every
+// log line written while handling the request, including its worker goroutines
+func requestLogger(c *gin.Context) *logger.Logger {
+	id := c.GetString(requestIDKey)
+	if id == "" {
+		return loanLogger
+	}
+	return &logger.Logger{Logger: loanLogger.With(slog.String(requestIDKey, id))}
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
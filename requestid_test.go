@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRequestID_CorrelatesLogLines(t *testing.T) {
+	buf := captureLoanLogger(t)
+	router := newTestRouter()
+
+	body := `[{"id": "REQID001", "wam": 12, "wac": 5.0, "face": 10000}, {"id": "REQID002", "wam": 24, "wac": 5.0, "face": 20000}]`
+	req := httptest.NewRequest(http.MethodPost, "/loans", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(requestIDHeader, "req-abc-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(requestIDHeader); got != "req-abc-123" {
+		t.Errorf("expected request ID echoed in response header, got %q", got)
+	}
+
+	ids := map[string][]string{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("log line is not valid JSON: %v", err)
+		}
+		msg, _ := entry["msg"].(string)
+		id, _ := entry[requestIDKey].(string)
+		ids[msg] = append(ids[msg], id)
+	}
+
+	if got := ids["cashflow request received"]; len(got) != 1 || got[0] != "req-abc-123" {
+		t.Errorf("expected submit log with request ID, got %v", got)
+	}
+	// One completion line per loan, each written from a worker goroutine
+	if got := ids["amortization calculated"]; len(got) != 2 || got[0] != "req-abc-123" || got[1] != "req-abc-123" {
+		t.Errorf("expected per-loan completion logs with request ID, got %v", got)
+	}
+}
+
+func TestRequestID_GeneratedWhenMissing(t *testing.T) {
+	router := newTestRouter()
+	req := httptest.NewRequest(http.MethodGet, "/info", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if got := w.Header().Get(requestIDHeader); !uuid.MatchString(got) {
+		t.Errorf("expected a generated UUID request ID, got %q", got)
+	}
+}
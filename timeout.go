@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+	"github.com/jiangshenghai57/andy-warhol/logger"
+)
+
+// errLoanTimeout reports a loan whose calculation outlived loanTimeout
+var errLoanTimeout = errors.New("loan calculation timed out")
+
+// calculateTableWithin runs calculateTable on a copy of the loan and waits at
+// most loanTimeout for it. On success the calculated loan (with its resolved
+// SMMs and face) is copied back and the table is cached; a loan whose
+// assumptions are already cached is answered without calculating. On timeout
+// the calculation is abandoned so the caller can release its worker slot; it
+// finishes in the background and its result is discarded.
+func calculateTableWithin(reqLog *logger.Logger, l *amortization.LoanInfo) (amortization.AmortizationTable, error) {
+	type outcome struct {
+		loan  amortization.LoanInfo
+		table amortization.AmortizationTable
+	}
+
+	key, cacheable := cacheKey(*l)
+	if cacheable {
+		if table, ok := resultCache.get(key, l); ok {
+			return table, nil
+		}
+	}
+
+	start := time.Now()
+	calculate := calculateTable
+	done := make(chan outcome, 1) // Buffered so an abandoned calculation can still finish
+	go func(loan amortization.LoanInfo) {
+		table := calculate(&loan)
+		done <- outcome{loan, table}
+	}(*l)
+
+	timer := time.NewTimer(loanTimeout)
+	defer timer.Stop()
+
+	select {
+	case out := <-done:
+		*l = out.loan
+		if cacheable {
+			resultCache.put(key, out.loan, out.table)
+		}
+		return out.table, nil
+	case <-timer.C:
+		reqLog.Error("loan calculation timed out",
+			slog.String("loan_id", l.ID),
+			slog.Duration("elapsed", time.Since(start)),
+			slog.Duration("timeout", loanTimeout),
+		)
+		return amortization.AmortizationTable{}, errLoanTimeout
+	}
+}
+
+// failedResult is the per-loan result for a loan whose calculation failed
+func failedResult(loanID string, err error) gin.H {
+	return gin.H{"loan_id": loanID, "status": "failed", "code": failureCode(err), "error": err.Error()}
+}
+
+// runAdmitted runs fn as one loan of a batch: admitted to the queue and on a
+// worker slot. It responds 429 or 413 and returns false, without running fn,
+// when the request is not admitted.
+func runAdmitted(c *gin.Context, fn func()) bool {
+	if !admitBatch(c, 1) {
+		return false
+	}
+	defer releaseLoans(1)
+
+	calculateBatch(make([]amortization.LoanInfo, 1), func(int, amortization.LoanInfo) { fn() }, nil)
+	return true
+}
+
+// calculateAdmitted amortizes a single loan the way batches do: admitted to
+// the queue, run on a worker slot under LOAN_TIMEOUT, and answered from the
+// cache when it can be. It responds with the error and returns false when
+// the queue is full or the calculation fails.
+func calculateAdmitted(c *gin.Context, l *amortization.LoanInfo) (amortization.AmortizationTable, bool) {
+	reqLog := requestLogger(c)
+	var table amortization.AmortizationTable
+	var err error
+	if !runAdmitted(c, func() {
+		loan := *l
+		if table, err = calculateTableWithin(reqLog, &loan); err == nil {
+			*l = loan
+		}
+	}) {
+		return amortization.AmortizationTable{}, false
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, failureCode(err), fmt.Sprintf("loan %s: %s", l.ID, err.Error()))
+		return amortization.AmortizationTable{}, false
+	}
+	return table, true
+}
+
+// failureCode returns the error code for a failed calculation
+func failureCode(err error) string {
+	if errors.Is(err, errLoanTimeout) {
+		return codeTimeout
+	}
+	return codeInternal
+}
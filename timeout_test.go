@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiangshenghai57/andy-warhol/amortization"
+)
+
+func TestRequestCashflow_LoanTimeoutFreesWorker(t *testing.T) {
+	useResultCache(t, 0) // The stubbed calculation must run for every loan
+	buf := captureLoanLogger(t)
+
+	originalPool, originalCalc, originalTimeout := workerPool, calculateTable, loanTimeout
+	workerPool = make(chan struct{}, 1)
+	loanTimeout = 20 * time.Millisecond
+	hang := make(chan struct{})
+	t.Cleanup(func() {
+		close(hang) // Let the abandoned calculation finish
+		workerPool, calculateTable, loanTimeout = originalPool, originalCalc, originalTimeout
+	})
+
+	calculateTable = func(l *amortization.LoanInfo) amortization.AmortizationTable {
+		if l.ID == "SLOW" {
+			<-hang
+		}
+		return l.GetAmortizationTable()
+	}
+
+	router := newTestRouter()
+	w := postLoans(t, router, `[
+		{"id": "SLOW", "wam": 12, "wac": 4.5, "face": 1000},
+		{"id": "FAST", "wam": 12, "wac": 4.5, "face": 1000}
+	]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if n := len(workerPool); n != 0 {
+		t.Errorf("expected every worker slot to be released, %d still held", n)
+	}
+
+	var resp struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	byID := map[string]map[string]interface{}{}
+	for _, r := range resp.Results {
+		byID[r["loan_id"].(string)] = r
+	}
+	if got := byID["SLOW"]["status"]; got != "failed" {
+		t.Errorf("expected SLOW to be failed, got %v", got)
+	}
+	if got := byID["SLOW"]["code"]; got != codeTimeout {
+		t.Errorf("expected code %q, got %v", codeTimeout, got)
+	}
+	if _, ok := byID["FAST"]["cashflow"]; !ok {
+		t.Errorf("expected FAST to be calculated once the slot was freed, got %v", byID["FAST"])
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, `"msg":"loan calculation timed out"`) || !strings.Contains(logs, `"loan_id":"SLOW"`) {
+		t.Errorf("expected an ERROR log naming the loan, got %s", logs)
+	}
+	if !strings.Contains(logs, `"elapsed"`) {
+		t.Errorf("expected the elapsed time to be logged, got %s", logs)
+	}
+}
+
+func TestLoanTimeoutFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "unset uses default", config: map[string]interface{}{}, want: defaultLoanTimeout},
+		{name: "configured", config: map[string]interface{}{"LOAN_TIMEOUT_SECONDS": 1.5}, want: 1500 * time.Millisecond},
+		{name: "zero", config: map[string]interface{}{"LOAN_TIMEOUT_SECONDS": float64(0)}, wantErr: true},
+		{name: "string", config: map[string]interface{}{"LOAN_TIMEOUT_SECONDS": "5"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loanTimeoutFromConfig(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}